@@ -0,0 +1,169 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const VerticaAutoscalerKind = "VerticaAutoscaler"
+
+// VerticaAutoscalerSpec defines the desired state of VerticaAutoscaler
+type VerticaAutoscalerSpec struct {
+	// +kubebuilder:validation:required
+	// The name of the VerticaDB to scale.  The VerticaDB must be in the same
+	// namespace as the VerticaAutoscaler.
+	VerticaDBName string `json:"verticaDBName"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=Pod
+	// +kubebuilder:validation:Enum:=Pod;Subcluster
+	// Controls how scaling is done.  With Pod, the size of a single
+	// subcluster is changed.  With Subcluster, whole secondary subclusters
+	// are added or removed.  Each new subcluster is built from the template.
+	ScalingGranularity ScalingGranularityType `json:"scalingGranularity"`
+
+	// +kubebuilder:validation:Optional
+	// The name of the subcluster to scale when the scalingGranularity is Pod.
+	// The subcluster must already exist in the VerticaDB and be a secondary.
+	SubclusterName string `json:"subclusterName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The template of the subclusters that are added when the
+	// scalingGranularity is Subcluster.  Subclusters are named
+	// <template.name>-<n>, and the group that is scaled is every subcluster
+	// with that name prefix.  The size of each subcluster is template.size.
+	// The subclusters are always secondary.
+	Template Subcluster `json:"template,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=0
+	// The total number of pods wanted in the targeted subclusters.  This is
+	// the value changed through the scale subresource, so it is normally set
+	// by a HorizontalPodAutoscaler.  When the scalingGranularity is
+	// Subcluster, this is rounded down to a multiple of template.size.  If
+	// it isn't set, the current size is kept.  A value of 0 scales the
+	// targeted subclusters down to nothing.
+	TargetSize *int32 `json:"targetSize,omitempty"`
+}
+
+type ScalingGranularityType string
+
+const (
+	// Scale by changing the size of a single subcluster
+	PodScalingGranularity ScalingGranularityType = "Pod"
+	// Scale by adding or removing secondary subclusters built from a template
+	SubclusterScalingGranularity ScalingGranularityType = "Subcluster"
+)
+
+// VerticaAutoscalerStatus defines the observed state of VerticaAutoscaler
+type VerticaAutoscalerStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The total number of pods in the targeted subclusters of the VerticaDB
+	Size int32 `json:"size"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The label selector of the pods in the targeted subclusters.  A
+	// HorizontalPodAutoscaler uses this to find the pods to gather metrics
+	// from.
+	Selector string `json:"selector"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The number of times the VerticaDB was changed to scale it
+	ScalingCount int `json:"scalingCount"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.targetSize,statuspath=.status.size,selectorpath=.status.selector
+//+kubebuilder:resource:categories=all;verticaautoscalers,shortName=vas
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:printcolumn:name="VerticaDB",type="string",JSONPath=".spec.verticaDBName"
+//+kubebuilder:printcolumn:name="Granularity",type="string",JSONPath=".spec.scalingGranularity"
+//+kubebuilder:printcolumn:name="Target",type="integer",JSONPath=".spec.targetSize"
+//+kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".status.size"
+
+// VerticaAutoscaler is the Schema for the verticaautoscalers API
+type VerticaAutoscaler struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VerticaAutoscalerSpec   `json:"spec,omitempty"`
+	Status VerticaAutoscalerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VerticaAutoscalerList contains a list of VerticaAutoscaler
+type VerticaAutoscalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VerticaAutoscaler `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VerticaAutoscaler{}, &VerticaAutoscalerList{})
+}
+
+// MakeVASName is a helper that creates a sample name for test purposes
+func MakeVASName() types.NamespacedName {
+	return types.NamespacedName{Name: "vas-sample", Namespace: "default"}
+}
+
+// MakeVAS is a helper that constructs a fully formed VerticaAutoscaler struct
+// that scales the first subcluster of the sample VerticaDB.  This is intended
+// for test purposes.
+func MakeVAS() *VerticaAutoscaler {
+	nm := MakeVASName()
+	vdbNm := MakeVDBName()
+	return &VerticaAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: VerticaDBAPIVersion,
+			Kind:       VerticaAutoscalerKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nm.Name,
+			Namespace: nm.Namespace,
+			UID:       "zyxwvut-srq",
+		},
+		Spec: VerticaAutoscalerSpec{
+			VerticaDBName:      vdbNm.Name,
+			ScalingGranularity: PodScalingGranularity,
+			SubclusterName:     MakeVDB().Spec.Subclusters[0].Name,
+		},
+	}
+}
+
+// GetTargetSize returns the target size and true, or false if the target size
+// has not been set.
+func (v *VerticaAutoscaler) GetTargetSize() (int32, bool) {
+	if v.Spec.TargetSize == nil {
+		return 0, false
+	}
+	return *v.Spec.TargetSize, true
+}
+
+// IsSubclusterInGroup returns true if the subcluster is one of the
+// subclusters that this VerticaAutoscaler scales.
+func (v *VerticaAutoscaler) IsSubclusterInGroup(scName string) bool {
+	if v.Spec.ScalingGranularity == SubclusterScalingGranularity {
+		return len(scName) > len(v.Spec.Template.Name)+1 &&
+			scName[:len(v.Spec.Template.Name)+1] == v.Spec.Template.Name+"-"
+	}
+	return scName == v.Spec.SubclusterName
+}
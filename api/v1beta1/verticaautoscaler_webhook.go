@@ -0,0 +1,162 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//nolint:lll
+package v1beta1
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var verticaautoscalerlog = logf.Log.WithName("verticaautoscaler-resource")
+
+// verticaautoscalerReader is used to read the VerticaDB that is targeted by
+// the VerticaAutoscaler.  Checks that need the VerticaDB are skipped if it is
+// not set.
+var verticaautoscalerReader client.Reader
+
+func (v *VerticaAutoscaler) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// The API reader is used so that the webhook doesn't need to cache every
+	// VerticaDB in the cluster.
+	verticaautoscalerReader = mgr.GetAPIReader()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(v).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-vertica-com-v1beta1-verticaautoscaler,mutating=false,failurePolicy=fail,sideEffects=None,groups=vertica.com,resources=verticaautoscalers,verbs=create;update,versions=v1beta1,name=vverticaautoscaler.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VerticaAutoscaler{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (v *VerticaAutoscaler) ValidateCreate() error {
+	verticaautoscalerlog.Info("validate create", "name", v.Name)
+
+	allErrs := v.validateSpec()
+	if allErrs == nil {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: "vertica.com", Kind: VerticaAutoscalerKind}, v.Name, allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (v *VerticaAutoscaler) ValidateUpdate(old runtime.Object) error {
+	verticaautoscalerlog.Info("validate update", "name", v.Name)
+
+	allErrs := v.validateSpec()
+	if allErrs == nil {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: "vertica.com", Kind: VerticaAutoscalerKind}, v.Name, allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (v *VerticaAutoscaler) ValidateDelete() error {
+	verticaautoscalerlog.Info("validate delete", "name", v.Name)
+
+	return nil
+}
+
+// validateSpec will validate the current VerticaAutoscaler to see if it is valid
+func (v *VerticaAutoscaler) validateSpec() field.ErrorList {
+	allErrs := v.validateSubclusterName(field.ErrorList{})
+	allErrs = v.validateSubclusterIsSecondary(allErrs)
+	allErrs = v.validateTemplate(allErrs)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return allErrs
+}
+
+// validateSubclusterName checks that the subcluster to scale is named when
+// scaling by pod
+func (v *VerticaAutoscaler) validateSubclusterName(allErrs field.ErrorList) field.ErrorList {
+	if v.Spec.ScalingGranularity == PodScalingGranularity && v.Spec.SubclusterName == "" {
+		err := field.Invalid(field.NewPath("spec").Child("subclusterName"),
+			v.Spec.SubclusterName,
+			"subclusterName must be set when scalingGranularity is Pod")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
+// validateSubclusterIsSecondary checks that the subcluster scaled by pod isn't
+// a primary.  Primary subclusters can't be scaled, as that would change the
+// number of nodes that have a vote in the cluster.  Nothing is checked if
+// the VerticaDB doesn't exist yet.
+func (v *VerticaAutoscaler) validateSubclusterIsSecondary(allErrs field.ErrorList) field.ErrorList {
+	if v.Spec.ScalingGranularity != PodScalingGranularity || v.Spec.SubclusterName == "" ||
+		verticaautoscalerReader == nil {
+		return allErrs
+	}
+	vdb := &VerticaDB{}
+	nm := types.NamespacedName{Namespace: v.Namespace, Name: v.Spec.VerticaDBName}
+	if err := verticaautoscalerReader.Get(context.TODO(), nm, vdb); err != nil {
+		if apierrors.IsNotFound(err) {
+			return allErrs
+		}
+		return append(allErrs, field.InternalError(field.NewPath("spec").Child("verticaDBName"), err))
+	}
+	for i := range vdb.Spec.Subclusters {
+		sc := &vdb.Spec.Subclusters[i]
+		if sc.Name == v.Spec.SubclusterName && sc.IsPrimary {
+			err := field.Invalid(field.NewPath("spec").Child("subclusterName"),
+				v.Spec.SubclusterName,
+				"subclusterName must refer to a secondary subcluster when scalingGranularity is Pod")
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+// validateTemplate checks that the template can be used to build new
+// subclusters when scaling by subcluster
+func (v *VerticaAutoscaler) validateTemplate(allErrs field.ErrorList) field.ErrorList {
+	if v.Spec.ScalingGranularity != SubclusterScalingGranularity {
+		return allErrs
+	}
+	pathPrefix := field.NewPath("spec").Child("template")
+	if v.Spec.Template.Name == "" {
+		err := field.Invalid(pathPrefix.Child("name"),
+			v.Spec.Template.Name,
+			"template.name must be set when scalingGranularity is Subcluster")
+		allErrs = append(allErrs, err)
+	}
+	if v.Spec.Template.Size <= 0 {
+		err := field.Invalid(pathPrefix.Child("size"),
+			v.Spec.Template.Size,
+			"template.size must be greater than 0 when scalingGranularity is Subcluster")
+		allErrs = append(allErrs, err)
+	}
+	if v.Spec.Template.IsPrimary {
+		err := field.Invalid(pathPrefix.Child("isPrimary"),
+			v.Spec.Template.IsPrimary,
+			"the subclusters built from the template are always secondary")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
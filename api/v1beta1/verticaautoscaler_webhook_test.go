@@ -0,0 +1,76 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("verticaautoscaler_webhook", func() {
+	It("should succeed with all valid fields", func() {
+		vas := MakeVAS()
+		Expect(vas.ValidateCreate()).Should(Succeed())
+		Expect(vas.ValidateUpdate(MakeVAS())).Should(Succeed())
+	})
+
+	It("should require a subclusterName when scaling by pod", func() {
+		vas := MakeVAS()
+		vas.Spec.SubclusterName = ""
+		Expect(vas.ValidateCreate()).ShouldNot(Succeed())
+	})
+
+	It("should only allow scaling by pod for a secondary subcluster", func() {
+		vdb := MakeVDB()
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, Subcluster{Name: "sec", Size: 3})
+		vdb.Spec.Subclusters[0].IsPrimary = true
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).Should(Succeed())
+		origReader := verticaautoscalerReader
+		verticaautoscalerReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(vdb).Build()
+		defer func() { verticaautoscalerReader = origReader }()
+
+		vas := MakeVAS()
+		Expect(vas.ValidateCreate()).ShouldNot(Succeed())
+		vas.Spec.SubclusterName = "sec"
+		Expect(vas.ValidateCreate()).Should(Succeed())
+		Expect(vas.ValidateUpdate(MakeVAS())).Should(Succeed())
+
+		// Nothing can be checked until the VerticaDB exists
+		vas.Spec.SubclusterName = vdb.Spec.Subclusters[0].Name
+		vas.Spec.VerticaDBName = "not-created-yet"
+		Expect(vas.ValidateCreate()).Should(Succeed())
+	})
+
+	It("should require a valid template when scaling by subcluster", func() {
+		vas := MakeVAS()
+		vas.Spec.ScalingGranularity = SubclusterScalingGranularity
+		vas.Spec.SubclusterName = ""
+		vas.Spec.Template = Subcluster{Name: "as", Size: 3}
+		Expect(vas.ValidateCreate()).Should(Succeed())
+
+		vas.Spec.Template.Name = ""
+		Expect(vas.ValidateCreate()).ShouldNot(Succeed())
+		vas.Spec.Template.Name = "as"
+		vas.Spec.Template.Size = 0
+		Expect(vas.ValidateCreate()).ShouldNot(Succeed())
+		vas.Spec.Template.Size = 3
+		vas.Spec.Template.IsPrimary = true
+		Expect(vas.ValidateUpdate(MakeVAS())).ShouldNot(Succeed())
+	})
+})
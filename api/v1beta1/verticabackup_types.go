@@ -0,0 +1,184 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const VerticaBackupKind = "VerticaBackup"
+
+// VerticaBackupSpec defines the desired state of VerticaBackup
+type VerticaBackupSpec struct {
+	// +kubebuilder:validation:required
+	// The name of the VerticaDB to backup.  The VerticaDB must be in the same
+	// namespace as the VerticaBackup.  The backup is taken from a pod that has
+	// an up vertica node, so it will wait until the database is running.
+	VerticaDBName string `json:"verticaDBName"`
+
+	// +kubebuilder:validation:required
+	// Where the backup will be stored.
+	Location BackupLocation `json:"location"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=1
+	// The number of restore points that vbr will keep in the backup location
+	// for the snapshot.  When this limit is reached, vbr will remove the
+	// oldest restore point after a successful backup.
+	RestorePointLimit int `json:"restorePointLimit,omitempty"`
+}
+
+// BackupLocation describes where vbr stores the restore points of a backup
+type BackupLocation struct {
+	// +kubebuilder:validation:required
+	// The path to store the backups in.  This must be an s3 bucket, and is
+	// specified using the s3:// bucket notation.  For example:
+	// s3://bucket-name/backups.  The bucket must be created prior to taking
+	// the backup.  This path must differ from the communal path of the
+	// VerticaDB.
+	Path string `json:"path"`
+
+	// +kubebuilder:validation:Optional
+	// The URL to the s3 endpoint that holds the backups.  The endpoint must be
+	// prefaced with http:// or https:// to know what protocol to connect
+	// with.  If omitted, the endpoint of the VerticaDB's communal storage is
+	// used.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The name of a secret that contains the credentials to connect to the
+	// backup endpoint.  The secret must have the following keys set: accesskey
+	// and secretkey.  If omitted, the communal credential secret of the
+	// VerticaDB is used.  If neither is set, vbr uses the IAM role of the pod.
+	CredentialSecret string `json:"credentialSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=backup
+	// The name of the vbr snapshot.  Restore points are named after this, and
+	// backups with the same snapshot name and path share the restore point
+	// limit.
+	SnapshotName string `json:"snapshotName,omitempty"`
+}
+
+type BackupPhase string
+
+const (
+	// The backup has been accepted but vbr hasn't been run yet.  We stay in
+	// this phase until there is an up vertica node to run vbr from.
+	BackupPending BackupPhase = "Pending"
+	// vbr is currently taking the backup
+	BackupRunning BackupPhase = "Running"
+	// vbr finished and the restore point was created
+	BackupSucceeded BackupPhase = "Succeeded"
+	// vbr failed.  The backup is not retried; create a new VerticaBackup to
+	// try again.
+	BackupFailed BackupPhase = "Failed"
+)
+
+// VerticaBackupStatus defines the observed state of VerticaBackup
+type VerticaBackupStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The phase the backup is in.  One of: Pending, Running, Succeeded or
+	// Failed.
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The time when vbr was started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The time when the backup finished, regardless of whether it succeeded
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The ID of the restore point that the backup created.  This is the
+	// archive name that vbr uses for the restore point (e.g.
+	// 20210725_170547), and is what gets passed to vbr --archive.
+	RestorePointID string `json:"restorePointID,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// A human readable message indicating details about the current phase
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=all;verticabackups,shortName=vbk
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:printcolumn:name="VerticaDB",type="string",JSONPath=".spec.verticaDBName"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="RestorePoint",type="string",JSONPath=".status.restorePointID"
+
+// VerticaBackup is the Schema for the verticabackups API
+type VerticaBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VerticaBackupSpec   `json:"spec,omitempty"`
+	Status VerticaBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VerticaBackupList contains a list of VerticaBackup
+type VerticaBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VerticaBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VerticaBackup{}, &VerticaBackupList{})
+}
+
+// IsComplete returns true if the backup has finished, regardless of whether
+// it succeeded or failed.
+func (v *VerticaBackup) IsComplete() bool {
+	return v.Status.Phase == BackupSucceeded || v.Status.Phase == BackupFailed
+}
+
+// MakeVBackupName is a helper that creates a sample name for test purposes
+func MakeVBackupName() types.NamespacedName {
+	return types.NamespacedName{Name: "vbackup-sample", Namespace: "default"}
+}
+
+// MakeVBackup is a helper that constructs a fully formed VerticaBackup struct
+// that refers to the sample VerticaDB.  This is intended for test purposes.
+func MakeVBackup() *VerticaBackup {
+	nm := MakeVBackupName()
+	return &VerticaBackup{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: VerticaDBAPIVersion,
+			Kind:       VerticaBackupKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nm.Name,
+			Namespace: nm.Namespace,
+			UID:       "zyxwvu-tsr",
+		},
+		Spec: VerticaBackupSpec{
+			VerticaDBName: MakeVDBName().Name,
+			Location: BackupLocation{
+				Path:         "s3://nimbusdb/backups",
+				SnapshotName: "backup",
+			},
+			RestorePointLimit: 1,
+		},
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const VerticaBackupScheduleKind = "VerticaBackupSchedule"
+
+// VerticaBackupScheduleSpec defines the desired state of VerticaBackupSchedule
+type VerticaBackupScheduleSpec struct {
+	// +kubebuilder:validation:required
+	// The name of the VerticaDB to backup.  The VerticaDB must be in the same
+	// namespace as the VerticaBackupSchedule.
+	VerticaDBName string `json:"verticaDBName"`
+
+	// +kubebuilder:validation:required
+	// The schedule in cron format (minute hour day-of-month month
+	// day-of-week).  For example, "0 2 * * *" takes a backup every day at
+	// 2am.  The descriptors @hourly, @daily, @weekly, @monthly and
+	// @every <duration> are also accepted.  Times are in UTC.
+	Schedule string `json:"schedule"`
+
+	// +kubebuilder:validation:required
+	// Where the backups will be stored.
+	Location BackupLocation `json:"location"`
+
+	// +kubebuilder:validation:Optional
+	// Controls how long restore points are kept.  After each successful
+	// backup, the operator removes the restore points that fall outside of
+	// either limit of the retention.  The newest restore point is always
+	// kept.  If nothing is set, restore points are never removed.
+	Retention BackupRetention `json:"retention,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=600
+	// If a scheduled backup cannot be started within this many seconds of its
+	// scheduled time, it is counted as missed.  This can happen if the
+	// operator was down, or if the prior backup was still running.
+	StartingDeadlineSeconds int `json:"startingDeadlineSeconds,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// If true, no new backups will be created.  Backups that are already
+	// running will continue.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// BackupRetention defines the retention policy of a backup schedule
+type BackupRetention struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=0
+	// The number of restore points to keep.  Older restore points are
+	// removed.  A value of 0 means restore points are not removed based on
+	// their count.
+	KeepLast int `json:"keepLast,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Restore points older than this duration are removed.  This is given as
+	// a duration string, such as 168h for a week.  If omitted, restore points
+	// are not removed based on their age.
+	KeepFor metav1.Duration `json:"keepFor,omitempty"`
+}
+
+// VerticaBackupScheduleStatus defines the observed state of VerticaBackupSchedule
+type VerticaBackupScheduleStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The scheduled time of the last backup that the operator acted on.
+	// This is updated for missed backups too.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The time when the last successful backup completed
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The name of the VerticaBackup that was created for the last backup
+	LastBackupName string `json:"lastBackupName,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The phase of the last backup.  This is only set once the last backup
+	// has completed.
+	LastBackupPhase BackupPhase `json:"lastBackupPhase,omitempty"`
+
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// The number of scheduled backups that were missed
+	MissedCount int `json:"missedCount"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=all;verticabackupschedules,shortName=vbks
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:printcolumn:name="VerticaDB",type="string",JSONPath=".spec.verticaDBName"
+//+kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
+//+kubebuilder:printcolumn:name="Suspend",type="boolean",JSONPath=".spec.suspend"
+//+kubebuilder:printcolumn:name="LastSchedule",type="date",JSONPath=".status.lastScheduleTime"
+//+kubebuilder:printcolumn:name="LastBackup",type="string",JSONPath=".status.lastBackupName"
+
+// VerticaBackupSchedule is the Schema for the verticabackupschedules API
+type VerticaBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VerticaBackupScheduleSpec   `json:"spec,omitempty"`
+	Status VerticaBackupScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VerticaBackupScheduleList contains a list of VerticaBackupSchedule
+type VerticaBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VerticaBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VerticaBackupSchedule{}, &VerticaBackupScheduleList{})
+}
+
+// MakeVBackupScheduleName is a helper that creates a sample name for test purposes
+func MakeVBackupScheduleName() types.NamespacedName {
+	return types.NamespacedName{Name: "vbackupschedule-sample", Namespace: "default"}
+}
+
+// MakeVBackupSchedule is a helper that constructs a fully formed
+// VerticaBackupSchedule struct that refers to the sample VerticaDB.  This is
+// intended for test purposes.
+func MakeVBackupSchedule() *VerticaBackupSchedule {
+	nm := MakeVBackupScheduleName()
+	return &VerticaBackupSchedule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: VerticaDBAPIVersion,
+			Kind:       VerticaBackupScheduleKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nm.Name,
+			Namespace: nm.Namespace,
+			UID:       "onmlkj-ihg",
+		},
+		Spec: VerticaBackupScheduleSpec{
+			VerticaDBName: MakeVDBName().Name,
+			Schedule:      "0 2 * * *",
+			Location: BackupLocation{
+				Path:         "s3://nimbusdb/backups",
+				SnapshotName: "scheduled",
+			},
+			StartingDeadlineSeconds: 600,
+		},
+	}
+}
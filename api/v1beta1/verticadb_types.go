@@ -17,7 +17,10 @@ limitations under the License.
 package v1beta1
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -49,11 +52,33 @@ type VerticaDBSpec struct {
 	// More info: https://kubernetes.io/docs/concepts/containers/images#specifying-imagepullsecrets-on-a-pod
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	// The name of the service account that the pods run as.  The service
+	// account must exist in the same namespace as the VerticaDB.  Use this to
+	// give the pods access to s3 through IAM roles for service accounts, in
+	// which case communal.credentialSecret can be omitted.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:="verticadocker/vertica-k8s:10.1.1-0"
-	// The docker image name that contains Vertica.
+	// The docker image name that contains Vertica.  Changing this will cause
+	// the operator to upgrade the database.  If autoRestartVertica is true,
+	// the operator will drive the upgrade according to the upgradePolicy.
 	Image string `json:"image,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=Online
+	// This setting defines how the operator upgrades the database when the
+	// image changes.  Available options are Online or Offline.  An Online
+	// upgrade rolls the new image out one subcluster at a time, keeping the
+	// primaries up so that clients can keep running.  An Offline upgrade
+	// stops the entire cluster, moves every pod to the new image, then starts
+	// the cluster again.  Use Offline for upgrades, such as major version
+	// jumps, that cannot be done while the database is running.  An Online
+	// upgrade isn't possible with a kSafety of 0, so Offline is always used
+	// in that case.
+	UpgradePolicy UpgradePolicyType `json:"upgradePolicy,omitempty"`
+
 	// Custom labels that will be added to all of the objects that the operator
 	// will create.
 	Labels map[string]string `json:"labels,omitempty"`
@@ -95,8 +120,8 @@ type VerticaDBSpec struct {
 	// is set prior to installing of hosts the call to update_vertica will
 	// include one of the licenses from the secret -- if there are multiple
 	// licenses it will pick one by selecting the first one alphabetically.
-	// The user is responsible for installing any additional licenses or if the
-	// license was added to the secret after install.
+	// The licenses in the secret are installed in the database again whenever
+	// the content of the secret changes.
 	LicenseSecret string `json:"licenseSecret,omitempty"`
 
 	// +kubebuilder:validation:Optional
@@ -109,9 +134,15 @@ type VerticaDBSpec struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:=Create
 	// The initialization policy defines how to setup the database.  Available
-	// options are to create a new database or revive an existing one.
+	// options are to create a new database, revive an existing one, or
+	// restore one from a vbr restore point.
 	InitPolicy CommunalInitPolicy `json:"initPolicy"`
 
+	// +kubebuilder:validation:Optional
+	// The restore point to initialize the database from.  This must be set
+	// when the initPolicy is Restore, and is ignored otherwise.
+	RestorePoint RestorePointPolicy `json:"restorePoint,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// This specifies the order of nodes when doing a revive.  Each entry
 	// contains an index to a subcluster, which is an index in Subclusters[],
@@ -164,6 +195,67 @@ type VerticaDBSpec struct {
 	// This should be reserved for test environments as an error scenario could
 	// easily consume the logs.
 	RequeueTime int `json:"requeueTime,omitempty"`
+
+	// +kubebuilder:default:=60
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=0
+	// Before a node is removed from the database, its pod is taken out of the
+	// subcluster's service so that it gets no new client connections.  This
+	// is the number of seconds to wait for the existing client sessions on
+	// the node to end before it is removed anyway.  If this is 0, then we
+	// don't wait for the sessions.
+	DrainTimeoutSeconds int `json:"drainTimeoutSeconds,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Settings to let clients authenticate to the database with Kerberos.
+	// Kerberos is enabled when keytabSecret is set.
+	Kerberos KerberosSpec `json:"kerberos,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Settings for TLS on the client connections to the server.  TLS is
+	// enabled when serverSecret is set.
+	TLS TLSSpec `json:"tls,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Configuration parameters to set in the database.  The key is the name
+	// of the parameter and the value is what it is set to.  They are applied
+	// with ALTER DATABASE once the database is initialized, and again whenever
+	// they change or drift from what is in the database.  A parameter that is
+	// removed from the map is cleared in the database.
+	ConfigParameters map[string]string `json:"configParameters,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Additional containers to run in each pod next to the server container,
+	// such as log shippers or monitoring agents.  Each sidecar has the same
+	// volume mounts as the server container appended to its own, so it can
+	// read the vertica logs.  The name 'server' is reserved.
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Additional volumes to add to the pods.  They can be mounted in the
+	// server container with volumeMounts, or in a sidecar through its own
+	// volume mounts.
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Additional volume mounts for the server container.  Each must refer to
+	// one of the volumes in the volumes field.
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Additional environment variables to set in the server container
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Sources of additional environment variables for the server container,
+	// such as config maps or secrets
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// SQL scripts to run against the database once it has been initialized.
+	// Each one runs exactly once at the point given by its when field.  The
+	// scripts run in the order they are listed.
+	InitScripts []InitScript `json:"initScripts,omitempty"`
 }
 
 type CommunalInitPolicy string
@@ -175,8 +267,96 @@ const (
 	// The database in the communal path will be initialized in the VerticaDB
 	// through a revive_db.  The communal path must have a preexisting database.
 	CommunalInitPolicyRevive = "Revive"
+	// The database will be initialized from a vbr restore point.  An empty
+	// database is created with create_db, then the restore point is restored
+	// into it.  There must not already be a database in the communal path.
+	CommunalInitPolicyRestore = "Restore"
+)
+
+// RestorePointPolicy identifies a restore point to initialize a database from
+type RestorePointPolicy struct {
+	// +kubebuilder:validation:Optional
+	// Where the restore point is stored.  This is the same location that was
+	// used when the backup was taken.
+	Location BackupLocation `json:"location"`
+
+	// +kubebuilder:validation:Optional
+	// The ID of the restore point to restore, as reported in the status of a
+	// VerticaBackup (e.g. 20210725_170547).  If omitted, the newest restore
+	// point in the location is used.
+	Archive string `json:"archive,omitempty"`
+}
+
+type UpgradePolicyType string
+
+const (
+	// Upgrade the database by rolling the new image out to one subcluster at
+	// a time, while the database stays up.
+	OnlineUpgrade UpgradePolicyType = "Online"
+	// Upgrade the database by stopping the cluster, moving all pods to the new
+	// image, then starting the cluster again.
+	OfflineUpgrade UpgradePolicyType = "Offline"
+)
+
+// +kubebuilder:validation:Enum:=vertica;agent
+type ServicePortName string
+
+const (
+	// The port that clients use to connect to Vertica
+	ServicePortVertica ServicePortName = "vertica"
+	// The port of the Vertica agent
+	ServicePortAgent ServicePortName = "agent"
 )
 
+type InitScriptPhase string
+
+const (
+	// Run the script after the database has been created with create_db
+	InitScriptPostCreate InitScriptPhase = "PostCreate"
+	// Run the script after the database has been revived with revive_db
+	InitScriptPostRevive InitScriptPhase = "PostRevive"
+	// Run the script once for each subcluster that is added to the database
+	// after the script was added to the spec
+	InitScriptPostSubclusterAdd InitScriptPhase = "PostSubclusterAdd"
+)
+
+// InitScript is a SQL script, held in a config map, that is run against the
+// database
+type InitScript struct {
+	// The name of a config map that has the SQL.  Each key in the config map
+	// is a SQL file.  They are run as a single script, with the keys in
+	// alphabetical order.
+	ConfigMap string `json:"configMap"`
+
+	// +kubebuilder:validation:Enum:=PostCreate;PostRevive;PostSubclusterAdd
+	// When to run the script.  PostCreate runs it after create_db and
+	// PostRevive after revive_db.  PostSubclusterAdd runs it once for each
+	// subcluster that is added after the script is first seen by the
+	// operator.  The subclusters that exist at that point, such as the ones
+	// the database was initialized with, are skipped.  The name of the
+	// subcluster is passed to the script in the vsql variable :subcluster.
+	When InitScriptPhase `json:"when"`
+}
+
+// InitScriptStatus records an init script that has been run
+type InitScriptStatus struct {
+	// The name of the config map that has the script
+	ConfigMap string `json:"configMap"`
+
+	// The phase the script was run for
+	When InitScriptPhase `json:"when"`
+
+	// +optional
+	// The subcluster the script was run for.  This is only set for
+	// PostSubclusterAdd scripts.
+	Subcluster string `json:"subcluster,omitempty"`
+
+	// +optional
+	// True if the script was not run for the subcluster because the
+	// subcluster already existed when the script was added.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
 type KSafetyType string
 
 const (
@@ -196,13 +376,24 @@ type SubclusterPodCount struct {
 	PodCount int `json:"podCount,omitempty"`
 }
 
+type ServerSideEncryptionType string
+
+const (
+	SSES3  ServerSideEncryptionType = "SSE-S3"
+	SSEKMS ServerSideEncryptionType = "SSE-KMS"
+)
+
 // Holds details about the communal storage
 type CommunalStorage struct {
 	// +kubebuilder:validation:required
-	// The path to the communal storage. This must be the s3 bucket. You specify
-	// this using the s3:// bucket notation. For example:
-	// s3://bucket-name/key-name. The bucket must be created prior to creating
-	// the VerticaDB.  This field is required and cannot change after creation.
+	// The path to the communal storage. This must be an s3 or Google Cloud
+	// Storage bucket, an Azure Blob Storage container, or an HDFS path. You
+	// specify this using the s3://, gs://, azb://, webhdfs:// or swebhdfs://
+	// notation. For example: s3://bucket-name/key-name,
+	// azb://account-name/container-name/key-name or
+	// webhdfs://namenode:50070/path.
+	// The bucket must be created prior to creating the VerticaDB.  This field
+	// is required and cannot change after creation.
 	Path string `json:"path"`
 
 	// +kubebuilder:validation:Optional
@@ -212,17 +403,94 @@ type CommunalStorage struct {
 	// forces each database path to be unique.
 	IncludeUIDInPath bool `json:"includeUIDInPath,omitempty"`
 
-	// +kubebuilder:validation:required
-	// The URL to the s3 endpoint. The endpoint must be prefaced with http:// or
-	// https:// to know what protocol to connect with. This field is required
-	// and cannot change after creation.
+	// +kubebuilder:validation:Optional
+	// The URL to the communal endpoint. The endpoint must be prefaced with
+	// http:// or https:// to know what protocol to connect with. This field is
+	// required for s3 and cannot change after creation.  For Google Cloud
+	// Storage, it defaults to https://storage.googleapis.com.  For Azure Blob
+	// Storage, it can be omitted to use the default endpoint of the account.
+	// It isn't used for HDFS.
 	Endpoint string `json:"endpoint"`
 
-	// +kubebuilder:validation:required
+	// +kubebuilder:validation:Optional
 	// The name of a secret that contains the credentials to connect to the
-	// communal S3 endpoint. The secret must have the following keys set:
-	// accessey and secretkey.
-	CredentialSecret string `json:"credentialSecret"`
+	// communal endpoint.  This can be omitted for s3 if the pods get their
+	// access through an IAM role, such as with an instance profile or with IAM
+	// roles for service accounts (see serviceAccountName). The secret must have the following keys set:
+	// accessey and secretkey.  For Google Cloud Storage, these are the access
+	// ID and secret of an HMAC key.  For Azure Blob Storage, the secret must
+	// have one of accountKey or sharedAccessSignature.  The account name is
+	// taken from the path.  This isn't used for HDFS.
+	CredentialSecret string `json:"credentialSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:="";SSE-S3;SSE-KMS
+	// The server-side encryption that s3 applies to the objects the database
+	// writes to the communal bucket.  Valid values are SSE-S3 and SSE-KMS.  If
+	// omitted, no server-side encryption is requested.  This is only valid for
+	// s3 and cannot change after creation.
+	S3ServerSideEncryption ServerSideEncryptionType `json:"s3ServerSideEncryption,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The id of the KMS key to encrypt the objects with.  This is required,
+	// and only allowed, when s3ServerSideEncryption is SSE-KMS.  It cannot
+	// change after creation.
+	S3SSEKMSKeyID string `json:"s3SseKmsKeyId,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The name of a secret that has the CA bundle to verify the certificate of
+	// the s3 endpoint.  Use this when the endpoint has a certificate signed by
+	// an internal CA.  The secret must have a key named ca.crt.  It is mounted
+	// in the pods and set as AWSCAFile in the database.  This is only valid
+	// for an s3 endpoint that uses https.
+	CaFile string `json:"caFile,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The name of a config map that contains the Hadoop configuration files,
+	// such as core-site.xml and hdfs-site.xml.  This is only used for HDFS
+	// communal storage.  The files are mounted in the pods and HadoopConfDir is
+	// set to the mount point.  If the Hadoop cluster uses Kerberos, the
+	// kerberos settings in the spec are used to authenticate with it.
+	HadoopConfig string `json:"hadoopConfig,omitempty"`
+}
+
+// Holds the details needed to authenticate clients with Kerberos
+type KerberosSpec struct {
+	// +kubebuilder:validation:Optional
+	// The name of a secret that has the keytab file for the Vertica service
+	// principal.  The secret must have a key named krb5.keytab.  It is mounted
+	// in each pod at /etc/krb5/krb5.keytab.
+	KeytabSecret string `json:"keytabSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The name of a config map that has the Kerberos configuration.  The
+	// config map must have a key named krb5.conf.  It is mounted in each pod
+	// at /etc/krb5.conf.  This must be set when keytabSecret is set.
+	Krb5ConfigMap string `json:"krb5ConfigMap,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The Kerberos realm of the service principal.  This is set in the
+	// database as the KerberosRealm parameter.  This must be set when
+	// keytabSecret is set.
+	Realm string `json:"realm,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=vertica
+	// The service name part of the Vertica service principal.  The principal
+	// for each node is <serviceName>/<host>@<realm>.  This is set in the
+	// database as the KerberosServiceName parameter.
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+// Holds the details needed to enable TLS for client connections
+type TLSSpec struct {
+	// +kubebuilder:validation:Optional
+	// The name of a secret that has the server certificate and its private
+	// key.  The secret must have the keys tls.crt and tls.key, which is what a
+	// secret of type kubernetes.io/tls has.  It is mounted in each pod at
+	// /certs/server.  When the contents of the secret change, the new
+	// certificate is installed in the database.
+	ServerSecret string `json:"serverSecret,omitempty"`
 }
 
 type LocalStorage struct {
@@ -236,7 +504,10 @@ type LocalStorage struct {
 
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:="500Gi"
-	// The minimum size of the local data volume when picking a PV.
+	// The minimum size of the local data volume when picking a PV.  This can
+	// be increased after creation if the storage class allows volume
+	// expansion.  The operator will then expand the PVC of each pod.  It
+	// cannot be decreased.
 	RequestSize resource.Quantity `json:"requestSize,omitempty"`
 
 	// +kubebuilder:validation:Optional
@@ -252,6 +523,20 @@ type LocalStorage struct {
 	// revive, this path must match the depot path used when the database was
 	// first created.
 	DepotPath string `json:"depotPath"`
+
+	// +kubebuilder:validation:Optional
+	// The minimum size of a separate volume for the depot.  By default, this
+	// is not set and the depot is stored in the local data volume.  When it is
+	// set, each pod gets a second PVC that is mounted at the depotPath.  It
+	// must be set when the VerticaDB is created.  After that, it can only be
+	// increased, which expands the depot PVC of each pod.
+	DepotRequestSize resource.Quantity `json:"depotRequestSize,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The name of the storageClass to use for the depot volume.  This only
+	// applies if depotRequestSize is set.  If it is not set, the PVC for the
+	// depot will use the default storage class set in Kubernetes.
+	DepotStorageClass string `json:"depotStorageClass,omitempty"`
 }
 
 type Subcluster struct {
@@ -283,6 +568,15 @@ type Subcluster struct {
 	// at least one primary subcluster in the database.
 	IsPrimary bool `json:"isPrimary"`
 
+	// +kubebuilder:default:=false
+	// +kubebuilder:validation:Optional
+	// When set to true, the subcluster is hibernated.  The operator stops its
+	// Vertica nodes with shutdown_subcluster and scales its statefulset to
+	// zero.  The nodes stay in the catalog and the PVCs are kept, so clearing
+	// this flag brings the subcluster back quickly.  Only secondary
+	// subclusters can be shut down.
+	Shutdown bool `json:"shutdown,omitempty"`
+
 	// A map of label keys and values to restrict Vertica node scheduling to workers
 	// with matchiing labels.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node/#nodeselector
@@ -332,6 +626,44 @@ type Subcluster struct {
 	// specify. If not set, the external IP list is left empty in the service object.
 	// More info: https://kubernetes.io/docs/concepts/services-networking/service/#external-ips
 	ExternalIPs []string `json:"externalIPs,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Annotations to add to the service object of this subcluster.  This is
+	// typically used to pass options to the load balancer of a cloud provider.
+	// These are added on top of the annotations in spec.annotations.
+	ServiceAnnotations map[string]string `json:"serviceAnnotations,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// When serviceType is LoadBalancer, this is the IP to request for the
+	// load balancer.  It is only honored by cloud providers that support it.
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// When serviceType is LoadBalancer, this restricts the client IPs that can
+	// connect through the load balancer to the given CIDR ranges.
+	// More info: https://kubernetes.io/docs/tasks/access-application-cluster/configure-cloud-provider-firewall/
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:="";Cluster;Local
+	// When serviceType is NodePort or LoadBalancer, this controls whether
+	// external traffic is routed to pods on any node (Cluster) or only to pods
+	// on the node that received it (Local).  Local preserves the client IP.  If
+	// not set, Kubernetes uses Cluster.
+	// More info: https://kubernetes.io/docs/tasks/access-application-cluster/create-external-load-balancer/#preserving-the-client-source-ip
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// The ports to expose in the service object.  Valid values are vertica,
+	// for client connections on port 5433, and agent, for the Vertica agent
+	// on port 5444.  If this is empty, both ports are exposed.
+	ServicePorts []ServicePortName `json:"servicePorts,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Configuration parameters to set for each Vertica node in the
+	// subcluster.  They are applied with ALTER NODE and override any value
+	// set at the database level.
+	ConfigParameters map[string]string `json:"configParameters,omitempty"`
 }
 
 // VerticaDBStatus defines the observed state of VerticaDB
@@ -353,6 +685,142 @@ type VerticaDBStatus struct {
 
 	// Conditions for VerticaDB
 	Conditions []VerticaDBCondition `json:"conditions,omitempty"`
+
+	// +optional
+	// Status message for the current running upgrade.   If no upgrade
+	// is occurring, this message remains blank.
+	UpgradeStatus string `json:"upgradeStatus"`
+
+	// +optional
+	// Progress of draining the client connections from nodes that are about
+	// to be removed from the database.  This is only set while a drain is in
+	// progress.
+	Drain *DrainStatus `json:"drain,omitempty"`
+
+	// +optional
+	// A hash of the server certificate and key that were last installed in
+	// the database.  The operator compares this with the tls.serverSecret to
+	// know when the certificate needs to be rotated.
+	TLSCertHash string `json:"tlsCertHash,omitempty"`
+
+	// +optional
+	// True if the server certificate was installed with the SSL parameters
+	// but the cluster hasn't been restarted for it to take effect.  This is
+	// only used with versions older than 11.0.0 when autoRestartVertica is
+	// false.  The operator restarts the cluster once autoRestartVertica is set
+	// to true.
+	TLSRestartPending bool `json:"tlsRestartPending,omitempty"`
+
+	// +optional
+	// A hash of the licenses in the licenseSecret that were last installed in
+	// the database.  The operator compares this with the secret to know when
+	// the licenses need to be installed again.
+	LicenseHash string `json:"licenseHash,omitempty"`
+
+	// +optional
+	// Details of the license that is installed in the database, as reported
+	// by GET_COMPLIANCE_STATUS.
+	License *LicenseStatus `json:"license,omitempty"`
+
+	// +optional
+	// The init scripts that have completed, or were skipped.  A script is not
+	// run again once it is in this list.
+	InitScripts []InitScriptStatus `json:"initScripts,omitempty"`
+
+	// +optional
+	// The PostCreate or PostRevive init scripts that have yet to run.  They
+	// are taken from the spec when create_db or revive_db completes, so a
+	// script added after the database was initialized is never run.
+	PendingInitScripts []InitScriptStatus `json:"pendingInitScripts,omitempty"`
+
+	// +optional
+	// The database configuration parameters that the operator last applied.
+	// This is used to know which parameters to clear when they are removed
+	// from the spec.
+	ConfigParameters map[string]string `json:"configParameters,omitempty"`
+
+	// +optional
+	// The node configuration parameters that the operator last applied,
+	// keyed by the subcluster name.
+	SubclusterConfigParameters map[string]map[string]string `json:"subclusterConfigParameters,omitempty"`
+
+	// +optional
+	// The PVCs that are being expanded after local.requestSize or
+	// local.depotRequestSize was increased.  A PVC is removed from this list
+	// once its volume has the new size.
+	VolumeResizes []VolumeResizeStatus `json:"volumeResizes,omitempty"`
+}
+
+type VolumeResizeState string
+
+const (
+	// The storage provider is expanding the volume
+	VolumeResizeExpanding VolumeResizeState = "Expanding"
+	// The volume was expanded and is waiting for the file system on it to be
+	// resized.  Depending on the storage driver, this may only happen when the
+	// pod is restarted.
+	VolumeResizeFileSystemPending VolumeResizeState = "FileSystemResizePending"
+	// The storage class of the PVC doesn't allow it to be expanded
+	VolumeResizeNotSupported VolumeResizeState = "NotSupported"
+)
+
+// VolumeResizeStatus is the progress of expanding the PVC of one pod
+type VolumeResizeStatus struct {
+	// The name of the pod that uses the PVC
+	PodName string `json:"podName"`
+
+	// The name of the PVC that is being expanded
+	ClaimName string `json:"claimName"`
+
+	// The size that the PVC is being expanded to
+	RequestedSize string `json:"requestedSize"`
+
+	// +optional
+	// The current size of the volume, as reported in the status of the PVC
+	Capacity string `json:"capacity,omitempty"`
+
+	// Where the resize is at
+	State VolumeResizeState `json:"state"`
+}
+
+// DrainStatus tracks the progress of draining client connections from a set
+// of pods
+type DrainStatus struct {
+	// The names of the pods that are being drained
+	Pods []string `json:"pods"`
+
+	// The time the drain started
+	StartTime metav1.Time `json:"startTime"`
+
+	// The number of client sessions that were still connected to the nodes
+	// the last time they were checked
+	ActiveSessions int `json:"activeSessions"`
+}
+
+// LicenseStatus has the limits of the license installed in the database
+type LicenseStatus struct {
+	// +optional
+	// The date the license expires.  This is empty for a license that
+	// doesn't expire.
+	Expiration string `json:"expiration,omitempty"`
+
+	// +optional
+	// The number of days left before the license expires.  This is only set
+	// if the license has an expiration.
+	DaysRemaining int `json:"daysRemaining,omitempty"`
+
+	// +optional
+	// The raw data size that the license allows
+	SizeLimit string `json:"sizeLimit,omitempty"`
+
+	// +optional
+	// The number of nodes that the license allows.  This is zero if the
+	// license doesn't limit the number of nodes.
+	NodeLimit int `json:"nodeLimit,omitempty"`
+
+	// +optional
+	// The last time the license was checked in the database
+	LastChecked metav1.Time `json:"lastChecked,omitempty"`
 }
 
 // VerticaDBConditionType defines type for VerticaDBCondition
@@ -363,19 +831,30 @@ const (
 	AutoRestartVertica VerticaDBConditionType = "AutoRestartVertica"
 	// DBInitialized indicateds the database has been created or revived
 	DBInitialized VerticaDBConditionType = "DBInitialized"
+	// ImageChangeInProgress indicates if the vertica server is in the process
+	// of having its image change.  We have additional conditions to
+	// distinguish between the different types of upgrade it is doing.
+	ImageChangeInProgress VerticaDBConditionType = "ImageChangeInProgress"
+	// Paused indicates that the operator has stopped reconciling the vdb
+	// because of the pause annotation.  Only the status is kept up to date.
+	Paused VerticaDBConditionType = "Paused"
 )
 
 // Fixed index entries for each condition.
 const (
 	AutoRestartVerticaIndex = iota
 	DBInitializedIndex
+	ImageChangeInProgressIndex
+	PausedIndex
 )
 
 // VerticaDBConditionIndexMap is a map of the VerticaDBConditionType to its
 // index in the condition array
 var VerticaDBConditionIndexMap = map[VerticaDBConditionType]int{
-	AutoRestartVertica: AutoRestartVerticaIndex,
-	DBInitialized:      DBInitializedIndex,
+	AutoRestartVertica:    AutoRestartVerticaIndex,
+	DBInitialized:         DBInitializedIndex,
+	ImageChangeInProgress: ImageChangeInProgressIndex,
+	Paused:                PausedIndex,
 }
 
 // VerticaDBCondition defines condition for VerticaDB
@@ -454,11 +933,28 @@ func init() {
 	SchemeBuilder.Register(&VerticaDB{}, &VerticaDBList{})
 }
 
+const (
+	// The prefixes of the communal paths for each type of communal storage
+	S3Prefix     = "s3://"
+	GCloudPrefix = "gs://"
+	AzurePrefix  = "azb://"
+	// HDFS has two prefixes depending on whether webhdfs is over http or https
+	WebHDFSPrefix  = "webhdfs://"
+	SWebHDFSPrefix = "swebhdfs://"
+
+	// The endpoint used for Google Cloud Storage if one isn't set
+	DefaultGCloudEndpoint = "https://storage.googleapis.com"
+)
+
 const (
 	// Annotations that we add by parsing vertica --version output
 	VersionAnnotation   = "vertica.com/version"
 	BuildDateAnnotation = "vertica.com/buildDate"
 	BuildRefAnnotation  = "vertica.com/buildRef"
+
+	// Annotation that a user can set to pause reconciliation of the vdb.  When
+	// set to true, the operator will only update the status.
+	PauseAnnotation = "vertica.com/pause"
 )
 
 // ExtractNamespacedName gets the name and returns it as a NamespacedName
@@ -495,6 +991,7 @@ func MakeVDB() *VerticaDB {
 			Annotations:        make(map[string]string),
 			Image:              "vertica-k8s:latest",
 			InitPolicy:         CommunalInitPolicyCreate,
+			UpgradePolicy:      OnlineUpgrade,
 			Communal: CommunalStorage{
 				Path:             "s3://nimbusdb/mspilchen",
 				Endpoint:         "http://minio",
@@ -531,7 +1028,87 @@ func IsValidSubclusterName(scName string) bool {
 	return r.MatchString(scName)
 }
 
+// IsValidConfigParameterName validates the name of a configuration parameter.
+// The name is included as-is in the SQL that sets it, so we only allow the
+// characters that Vertica uses in its parameter names.
+func IsValidConfigParameterName(name string) bool {
+	r := regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	return r.MatchString(name)
+}
+
 func (v *VerticaDB) GetVerticaVersion() (string, bool) {
 	ver, ok := v.ObjectMeta.Annotations[VersionAnnotation]
 	return ver, ok
 }
+
+// IsPaused returns true if the user has paused reconciliation of the vdb
+func (v *VerticaDB) IsPaused() bool {
+	paused, err := strconv.ParseBool(v.ObjectMeta.Annotations[PauseAnnotation])
+	return err == nil && paused
+}
+
+// IsS3 returns true if the communal storage is in s3
+func (v *VerticaDB) IsS3() bool {
+	return strings.HasPrefix(v.Spec.Communal.Path, S3Prefix)
+}
+
+// IsGCloud returns true if the communal storage is in Google Cloud Storage
+func (v *VerticaDB) IsGCloud() bool {
+	return strings.HasPrefix(v.Spec.Communal.Path, GCloudPrefix)
+}
+
+// IsAzure returns true if the communal storage is in Azure Blob Storage
+func (v *VerticaDB) IsAzure() bool {
+	return strings.HasPrefix(v.Spec.Communal.Path, AzurePrefix)
+}
+
+// IsHDFS returns true if the communal storage is in HDFS
+func (v *VerticaDB) IsHDFS() bool {
+	return strings.HasPrefix(v.Spec.Communal.Path, WebHDFSPrefix) ||
+		strings.HasPrefix(v.Spec.Communal.Path, SWebHDFSPrefix)
+}
+
+// HasSeparateDepotVolume returns true if the depot is stored in its own PVC
+// rather than in the local data PVC
+func (v *VerticaDB) HasSeparateDepotVolume() bool {
+	return !v.Spec.Local.DepotRequestSize.IsZero()
+}
+
+// IsServicePortExposed returns true if the given port is included in the
+// service object of the subcluster
+func (s *Subcluster) IsServicePortExposed(port ServicePortName) bool {
+	if len(s.ServicePorts) == 0 {
+		return true
+	}
+	for _, p := range s.ServicePorts {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKerberosEnabled returns true if clients can authenticate with Kerberos
+func (v *VerticaDB) IsKerberosEnabled() bool {
+	return v.Spec.Kerberos.KeytabSecret != ""
+}
+
+// IsTLSEnabled returns true if the server has a certificate for TLS
+func (v *VerticaDB) IsTLSEnabled() bool {
+	return v.Spec.TLS.ServerSecret != ""
+}
+
+// IsConditionSet will return true if the status condition is set to true.
+// If the condition is not in the array then this implies the condition is
+// false.
+func (v *VerticaDB) IsConditionSet(statusCondition VerticaDBConditionType) (bool, error) {
+	inx, ok := VerticaDBConditionIndexMap[statusCondition]
+	if !ok {
+		return false, fmt.Errorf("verticaDB condition '%s' missing from VerticaDBConditionType", statusCondition)
+	}
+	if inx < len(v.Status.Conditions) {
+		return v.Status.Conditions[inx].Status == corev1.ConditionTrue, nil
+	}
+	// Since the condition is missing, we can return false
+	return false, nil
+}
@@ -19,6 +19,7 @@ package v1beta1
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
@@ -31,6 +32,17 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
+// The names of the volumes that the operator adds to the pods.  These must be
+// kept in sync with the ones in pkg/controllers/builder.go.
+var reservedVolumeNames = []string{
+	"local-data", "local-depot", "podinfo", "licensing", "krb5-keytab", "krb5-conf",
+	"server-cert", "hadoop-conf", "communal-ca",
+}
+
+// The name of the container that runs the vertica server.  The operator runs
+// its commands in this container.
+const serverContainerName = "server"
+
 const (
 	invalidDBNameChars = "$=<>`" + `'^\".@*?#&/-:;{}()[] \~!%+|,`
 	dbNameLengthLimit  = 30
@@ -65,6 +77,11 @@ func (v *VerticaDB) Default() {
 	if strings.HasSuffix(v.Spec.Image, ":latest") {
 		v.Spec.ImagePullPolicy = v1.PullAlways
 	}
+	// communal.endpoint: Google Cloud Storage has a well known endpoint, so
+	// we set it if it was omitted
+	if v.IsGCloud() && v.Spec.Communal.Endpoint == "" {
+		v.Spec.Communal.Endpoint = DefaultGCloudEndpoint
+	}
 }
 
 //+kubebuilder:webhook:path=/validate-vertica-com-v1beta1-verticadb,mutating=false,failurePolicy=fail,sideEffects=None,groups=vertica.com,resources=verticadbs,verbs=create;update,versions=v1beta1,name=vverticadb.kb.io,admissionReviewVersions={v1,v1beta1}
@@ -146,12 +163,21 @@ func (v *VerticaDB) validateImmutableFields(old runtime.Object) field.ErrorList
 			"shardCount cannot change after creation.")
 		allErrs = append(allErrs, err)
 	}
-	// image will only be allowed to change if autoRestartVertica is disabled
+	// image cannot change while a prior image change is still in progress
 	if v.Spec.Image != oldObj.Spec.Image {
-		if v.Spec.AutoRestartVertica {
+		if inProgress, _ := oldObj.IsConditionSet(ImageChangeInProgress); inProgress {
 			err := field.Invalid(field.NewPath("spec").Child("image"),
 				v.Spec.Image,
-				"image will only be allowed to change if autoRestartVertica is disabled.")
+				"image cannot change while an upgrade to a prior image is in progress.")
+			allErrs = append(allErrs, err)
+		}
+	}
+	// upgradePolicy cannot change while an upgrade is in progress
+	if v.Spec.UpgradePolicy != oldObj.Spec.UpgradePolicy {
+		if inProgress, _ := oldObj.IsConditionSet(ImageChangeInProgress); inProgress {
+			err := field.Invalid(field.NewPath("spec").Child("upgradePolicy"),
+				v.Spec.UpgradePolicy,
+				"upgradePolicy cannot change while an upgrade is in progress.")
 			allErrs = append(allErrs, err)
 		}
 	}
@@ -169,11 +195,26 @@ func (v *VerticaDB) validateImmutableFields(old runtime.Object) field.ErrorList
 			"communal.endpoint cannot change after creation")
 		allErrs = append(allErrs, err)
 	}
-	// local.requestSize cannot change after creation
-	if v.Spec.Local.RequestSize.Cmp(oldObj.Spec.Local.RequestSize) != 0 {
+	// communal.s3ServerSideEncryption cannot change after creation
+	if v.Spec.Communal.S3ServerSideEncryption != oldObj.Spec.Communal.S3ServerSideEncryption {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("s3ServerSideEncryption"),
+			v.Spec.Communal.S3ServerSideEncryption,
+			"communal.s3ServerSideEncryption cannot change after creation")
+		allErrs = append(allErrs, err)
+	}
+	// communal.s3SseKmsKeyId cannot change after creation
+	if v.Spec.Communal.S3SSEKMSKeyID != oldObj.Spec.Communal.S3SSEKMSKeyID {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("s3SseKmsKeyId"),
+			v.Spec.Communal.S3SSEKMSKeyID,
+			"communal.s3SseKmsKeyId cannot change after creation")
+		allErrs = append(allErrs, err)
+	}
+	// local.requestSize can be increased, which expands the PVCs, but it
+	// cannot be decreased
+	if v.Spec.Local.RequestSize.Cmp(oldObj.Spec.Local.RequestSize) < 0 {
 		err := field.Invalid(field.NewPath("spec").Child("local").Child("requestSize"),
 			v.Spec.Local.RequestSize,
-			"local.requestSize cannot change after creation")
+			"local.requestSize cannot be decreased")
 		allErrs = append(allErrs, err)
 	}
 	// local.storageClass cannot change after creation
@@ -183,6 +224,26 @@ func (v *VerticaDB) validateImmutableFields(old runtime.Object) field.ErrorList
 			"local.storageClass cannot change after creation")
 		allErrs = append(allErrs, err)
 	}
+	// local.depotRequestSize cannot be added or removed after creation, as
+	// that changes the PVCs of the pods.  It can only be increased.
+	if v.HasSeparateDepotVolume() != oldObj.HasSeparateDepotVolume() {
+		err := field.Invalid(field.NewPath("spec").Child("local").Child("depotRequestSize"),
+			v.Spec.Local.DepotRequestSize,
+			"local.depotRequestSize cannot be added or removed after creation")
+		allErrs = append(allErrs, err)
+	} else if v.Spec.Local.DepotRequestSize.Cmp(oldObj.Spec.Local.DepotRequestSize) < 0 {
+		err := field.Invalid(field.NewPath("spec").Child("local").Child("depotRequestSize"),
+			v.Spec.Local.DepotRequestSize,
+			"local.depotRequestSize cannot be decreased")
+		allErrs = append(allErrs, err)
+	}
+	// local.depotStorageClass cannot change after creation
+	if v.Spec.Local.DepotStorageClass != oldObj.Spec.Local.DepotStorageClass {
+		err := field.Invalid(field.NewPath("spec").Child("local").Child("depotStorageClass"),
+			v.Spec.Local.DepotStorageClass,
+			"local.depotStorageClass cannot change after creation")
+		allErrs = append(allErrs, err)
+	}
 	// when update subcluster names, there should be at least one sc's name match its old name
 	if !v.canUpdateScName(oldObj) {
 		err := field.Invalid(field.NewPath("spec").Child("subclusters"),
@@ -196,6 +257,8 @@ func (v *VerticaDB) validateImmutableFields(old runtime.Object) field.ErrorList
 func (v *VerticaDB) validateVerticaDBSpec() field.ErrorList {
 	allErrs := v.hasAtLeastOneSC(field.ErrorList{})
 	allErrs = v.hasValidInitPolicy(allErrs)
+	allErrs = v.validateRestorePoint(allErrs)
+	allErrs = v.hasValidUpgradePolicy(allErrs)
 	allErrs = v.hasValidDBName(allErrs)
 	allErrs = v.hasPrimarySubcluster(allErrs)
 	allErrs = v.validateKsafety(allErrs)
@@ -204,16 +267,43 @@ func (v *VerticaDB) validateVerticaDBSpec() field.ErrorList {
 	allErrs = v.validateEndpoint(allErrs)
 	allErrs = v.hasValidDomainName(allErrs)
 	allErrs = v.credentialSecretExists(allErrs)
+	allErrs = v.validateServerSideEncryption(allErrs)
+	allErrs = v.validateCaFile(allErrs)
+	allErrs = v.validateDepotVolume(allErrs)
 	allErrs = v.hasValidNodePort(allErrs)
 	allErrs = v.isNodePortProperlySpecified(allErrs)
 	allErrs = v.isServiceTypeValid(allErrs)
+	allErrs = v.validateServiceOptions(allErrs)
 	allErrs = v.hasDuplicateScName(allErrs)
+	allErrs = v.canShutdownSubclusters(allErrs)
+	allErrs = v.validateKerberos(allErrs)
+	allErrs = v.hasValidConfigParameterNames(allErrs)
+	allErrs = v.validateSidecars(allErrs)
+	allErrs = v.validateVolumes(allErrs)
+	allErrs = v.validateInitScripts(allErrs)
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return allErrs
 }
 
+// validateDepotVolume checks the settings for a separate depot volume
+func (v *VerticaDB) validateDepotVolume(allErrs field.ErrorList) field.ErrorList {
+	if v.Spec.Local.DepotRequestSize.Sign() < 0 {
+		err := field.Invalid(field.NewPath("spec").Child("local").Child("depotRequestSize"),
+			v.Spec.Local.DepotRequestSize,
+			"local.depotRequestSize cannot be negative")
+		allErrs = append(allErrs, err)
+	}
+	if v.Spec.Local.DepotStorageClass != "" && !v.HasSeparateDepotVolume() {
+		err := field.Invalid(field.NewPath("spec").Child("local").Child("depotStorageClass"),
+			v.Spec.Local.DepotStorageClass,
+			"local.depotStorageClass can only be set if local.depotRequestSize is set")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
 func (v *VerticaDB) hasAtLeastOneSC(allErrs field.ErrorList) field.ErrorList {
 	// there should be at least one subcluster defined
 	if len(v.Spec.Subclusters) == 0 {
@@ -226,39 +316,92 @@ func (v *VerticaDB) hasAtLeastOneSC(allErrs field.ErrorList) field.ErrorList {
 }
 
 func (v *VerticaDB) hasValidInitPolicy(allErrs field.ErrorList) field.ErrorList {
-	// initPolicy should either be Create or Revive.
-	if v.Spec.InitPolicy != CommunalInitPolicyCreate && v.Spec.InitPolicy != CommunalInitPolicyRevive {
+	// initPolicy should either be Create, Revive or Restore.
+	if v.Spec.InitPolicy != CommunalInitPolicyCreate && v.Spec.InitPolicy != CommunalInitPolicyRevive &&
+		v.Spec.InitPolicy != CommunalInitPolicyRestore {
 		err := field.Invalid(field.NewPath("spec").Child("initPolicy"),
 			v.Spec.InitPolicy,
-			"initPolicy should either be Create or Revive.")
+			"initPolicy should either be Create, Revive or Restore.")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) validateRestorePoint(allErrs field.ErrorList) field.ErrorList {
+	if v.Spec.InitPolicy != CommunalInitPolicyRestore {
+		return allErrs
+	}
+	// the location of the restore point must be an s3 path
+	if !strings.HasPrefix(v.Spec.RestorePoint.Location.Path, "s3://") {
+		err := field.Invalid(field.NewPath("spec").Child("restorePoint").Child("location").Child("path"),
+			v.Spec.RestorePoint.Location.Path,
+			"restorePoint.location.path must be set to an s3 path when initPolicy is Restore")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) hasValidUpgradePolicy(allErrs field.ErrorList) field.ErrorList {
+	// upgradePolicy should either be Online or Offline.
+	if v.Spec.UpgradePolicy != OnlineUpgrade && v.Spec.UpgradePolicy != OfflineUpgrade {
+		err := field.Invalid(field.NewPath("spec").Child("upgradePolicy"),
+			v.Spec.UpgradePolicy,
+			"upgradePolicy should either be Online or Offline.")
 		allErrs = append(allErrs, err)
 	}
 	return allErrs
 }
 
 func (v *VerticaDB) validateCommunalPath(allErrs field.ErrorList) field.ErrorList {
-	// communal.Path must be an S3 bucket, prefaced with s3://
-	if !strings.HasPrefix(v.Spec.Communal.Path, "s3://") {
-		err := field.Invalid(field.NewPath("spec").Child("communal").Child("endpoint"),
+	// communal.Path must be an S3 bucket, prefaced with s3://, a Google
+	// Cloud Storage bucket, prefaced with gs://, or an Azure Blob Storage
+	// container, prefaced with azb://, or an HDFS path, prefaced with
+	// webhdfs:// or swebhdfs://
+	if !v.IsS3() && !v.IsGCloud() && !v.IsAzure() && !v.IsHDFS() {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("path"),
 			v.Spec.Communal.Path,
-			"communal.Path must be an S3 bucket, prefaced with s3://")
+			"communal.Path must be an S3 bucket, prefaced with s3://, a Google Cloud Storage bucket, prefaced with gs://, "+
+				"an Azure Blob Storage container, prefaced with azb://, or an HDFS path, prefaced with webhdfs:// or swebhdfs://")
 		allErrs = append(allErrs, err)
 	}
 	return allErrs
 }
 
 func (v *VerticaDB) validateS3Bucket(allErrs field.ErrorList) field.ErrorList {
-	// communal.Path must be an S3 bucket, prefaced with s3://
-	if !strings.HasPrefix(v.Spec.Communal.Path, "s3://") {
-		err := field.Invalid(field.NewPath("spec").Child("communal").Child("endpoint"),
-			v.Spec.Communal.Path,
-			"communal.Path must be an S3 bucket, prefaced with s3://")
-		allErrs = append(allErrs, err)
+	// communal.Path must have a bucket name after the s3:// or gs:// prefix
+	for _, prefix := range []string{S3Prefix, GCloudPrefix, AzurePrefix, WebHDFSPrefix, SWebHDFSPrefix} {
+		if strings.HasPrefix(v.Spec.Communal.Path, prefix) &&
+			strings.TrimPrefix(v.Spec.Communal.Path, prefix) == "" {
+			err := field.Invalid(field.NewPath("spec").Child("communal").Child("path"),
+				v.Spec.Communal.Path,
+				"communal.Path must include the name of the bucket")
+			allErrs = append(allErrs, err)
+		}
+	}
+	// An Azure path must have both the account and container name:
+	// azb://account/container
+	if v.IsAzure() {
+		parts := strings.SplitN(strings.TrimPrefix(v.Spec.Communal.Path, AzurePrefix), "/", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			err := field.Invalid(field.NewPath("spec").Child("communal").Child("path"),
+				v.Spec.Communal.Path,
+				"communal.Path for Azure must include the account and container name: azb://account/container")
+			allErrs = append(allErrs, err)
+		}
 	}
 	return allErrs
 }
 
 func (v *VerticaDB) validateEndpoint(allErrs field.ErrorList) field.ErrorList {
+	// communal.endpoint is optional for Azure.  The default endpoint for the
+	// account is used if it is omitted.
+	if v.IsAzure() && v.Spec.Communal.Endpoint == "" {
+		return allErrs
+	}
+	// HDFS gets the name node from the path, so communal.endpoint isn't used
+	if v.IsHDFS() {
+		return allErrs
+	}
 	// communal.endpoint must be prefaced with http:// or https:// to know what protocol to connect with.
 	if !(strings.HasPrefix(v.Spec.Communal.Endpoint, "http://") ||
 		strings.HasPrefix(v.Spec.Communal.Endpoint, "https://")) {
@@ -270,9 +413,46 @@ func (v *VerticaDB) validateEndpoint(allErrs field.ErrorList) field.ErrorList {
 	return allErrs
 }
 
+func (v *VerticaDB) validateServerSideEncryption(allErrs field.ErrorList) field.ErrorList {
+	// communal.s3ServerSideEncryption is only valid for s3
+	if v.Spec.Communal.S3ServerSideEncryption != "" && !v.IsS3() {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("s3ServerSideEncryption"),
+			v.Spec.Communal.S3ServerSideEncryption,
+			"communal.s3ServerSideEncryption can only be set when the communal path is in s3")
+		allErrs = append(allErrs, err)
+	}
+	// communal.s3SseKmsKeyId is required with SSE-KMS and not allowed otherwise
+	if v.Spec.Communal.S3ServerSideEncryption == SSEKMS && v.Spec.Communal.S3SSEKMSKeyID == "" {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("s3SseKmsKeyId"),
+			v.Spec.Communal.S3SSEKMSKeyID,
+			"communal.s3SseKmsKeyId must be set when communal.s3ServerSideEncryption is SSE-KMS")
+		allErrs = append(allErrs, err)
+	}
+	if v.Spec.Communal.S3ServerSideEncryption != SSEKMS && v.Spec.Communal.S3SSEKMSKeyID != "" {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("s3SseKmsKeyId"),
+			v.Spec.Communal.S3SSEKMSKeyID,
+			"communal.s3SseKmsKeyId can only be set when communal.s3ServerSideEncryption is SSE-KMS")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) validateCaFile(allErrs field.ErrorList) field.ErrorList {
+	// communal.caFile is only used to verify an s3 endpoint over https
+	if v.Spec.Communal.CaFile != "" &&
+		(!v.IsS3() || !strings.HasPrefix(v.Spec.Communal.Endpoint, "https://")) {
+		err := field.Invalid(field.NewPath("spec").Child("communal").Child("caFile"),
+			v.Spec.Communal.CaFile,
+			"communal.caFile can only be set for an s3 communal path with an https:// endpoint")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
 func (v *VerticaDB) credentialSecretExists(allErrs field.ErrorList) field.ErrorList {
-	// communal.credentialSecret must exist
-	if v.Spec.Communal.CredentialSecret == "" {
+	// communal.credentialSecret must exist.  HDFS doesn't use it, and s3 can
+	// get its access through an IAM role.
+	if v.Spec.Communal.CredentialSecret == "" && !v.IsHDFS() && !v.IsS3() {
 		err := field.Invalid(field.NewPath("spec").Child("communal").Child("credentialSecret"),
 			v.Spec.Communal.CredentialSecret,
 			"communal.credentialSecret must exist")
@@ -363,6 +543,99 @@ func (v *VerticaDB) hasValidDomainName(allErrs field.ErrorList) field.ErrorList
 	return allErrs
 }
 
+func (v *VerticaDB) hasValidConfigParameterNames(allErrs field.ErrorList) field.ErrorList {
+	for name := range v.Spec.ConfigParameters {
+		if !IsValidConfigParameterName(name) {
+			err := field.Invalid(field.NewPath("spec").Child("configParameters").Key(name),
+				name,
+				"is not a valid configuration parameter name")
+			allErrs = append(allErrs, err)
+		}
+	}
+	for i := range v.Spec.Subclusters {
+		for name := range v.Spec.Subclusters[i].ConfigParameters {
+			if !IsValidConfigParameterName(name) {
+				err := field.Invalid(field.NewPath("spec").Child("subclusters").Index(i).Child("configParameters").Key(name),
+					name,
+					"is not a valid configuration parameter name")
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) validateSidecars(allErrs field.ErrorList) field.ErrorList {
+	// The server container is the one the operator runs its commands in, so
+	// a sidecar can't take its name.
+	names := map[string]int{serverContainerName: -1}
+	for i := range v.Spec.Sidecars {
+		name := v.Spec.Sidecars[i].Name
+		if j, ok := names[name]; ok {
+			msg := fmt.Sprintf("duplicates the name of sidecars[%d]", j)
+			if j == -1 {
+				msg = fmt.Sprintf("the name '%s' is reserved for the server container", serverContainerName)
+			}
+			err := field.Invalid(field.NewPath("spec").Child("sidecars").Index(i).Child("name"), name, msg)
+			allErrs = append(allErrs, err)
+			continue
+		}
+		names[name] = i
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) validateVolumes(allErrs field.ErrorList) field.ErrorList {
+	names := map[string]int{}
+	for _, name := range reservedVolumeNames {
+		names[name] = -1
+	}
+	for i := range v.Spec.Volumes {
+		name := v.Spec.Volumes[i].Name
+		if j, ok := names[name]; ok {
+			msg := fmt.Sprintf("duplicates the name of volumes[%d]", j)
+			if j == -1 {
+				msg = fmt.Sprintf("the name '%s' is reserved for a volume the operator adds", name)
+			}
+			err := field.Invalid(field.NewPath("spec").Child("volumes").Index(i).Child("name"), name, msg)
+			allErrs = append(allErrs, err)
+			continue
+		}
+		names[name] = i
+	}
+	// The server container can only mount the volumes from the spec
+	for i := range v.Spec.VolumeMounts {
+		name := v.Spec.VolumeMounts[i].Name
+		if j, ok := names[name]; !ok || j == -1 {
+			err := field.Invalid(field.NewPath("spec").Child("volumeMounts").Index(i).Child("name"),
+				name,
+				"must be the name of a volume in spec.volumes")
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) validateInitScripts(allErrs field.ErrorList) field.ErrorList {
+	for i := range v.Spec.InitScripts {
+		script := &v.Spec.InitScripts[i]
+		if script.ConfigMap == "" {
+			err := field.Invalid(field.NewPath("spec").Child("initScripts").Index(i).Child("configMap"),
+				script.ConfigMap,
+				"configMap must be set to the name of the config map that has the SQL")
+			allErrs = append(allErrs, err)
+		}
+		if script.When != InitScriptPostCreate && script.When != InitScriptPostRevive &&
+			script.When != InitScriptPostSubclusterAdd {
+			err := field.Invalid(field.NewPath("spec").Child("initScripts").Index(i).Child("when"),
+				script.When,
+				"when should either be PostCreate, PostRevive or PostSubclusterAdd")
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
 func (v *VerticaDB) hasValidNodePort(allErrs field.ErrorList) field.ErrorList {
 	for i := range v.Spec.Subclusters {
 		sc := &v.Spec.Subclusters[i]
@@ -411,6 +684,71 @@ func (v *VerticaDB) isServiceTypeValid(allErrs field.ErrorList) field.ErrorList
 	return allErrs
 }
 
+// validateServiceOptions checks the settings of the service object of each
+// subcluster.  The load balancer settings are only allowed with the service
+// types that Kubernetes accepts them for.
+func (v *VerticaDB) validateServiceOptions(allErrs field.ErrorList) field.ErrorList {
+	for i := range v.Spec.Subclusters {
+		sc := &v.Spec.Subclusters[i]
+		path := field.NewPath("spec").Child("subclusters").Index(i)
+		if sc.ServiceType != v1.ServiceTypeLoadBalancer {
+			if sc.LoadBalancerIP != "" {
+				err := field.Invalid(path.Child("loadBalancerIP"), sc.LoadBalancerIP,
+					fmt.Sprintf("loadBalancerIP can only be specified for service type %s", v1.ServiceTypeLoadBalancer))
+				allErrs = append(allErrs, err)
+			}
+			if len(sc.LoadBalancerSourceRanges) > 0 {
+				err := field.Invalid(path.Child("loadBalancerSourceRanges"), sc.LoadBalancerSourceRanges,
+					fmt.Sprintf("loadBalancerSourceRanges can only be specified for service type %s", v1.ServiceTypeLoadBalancer))
+				allErrs = append(allErrs, err)
+			}
+		}
+		if sc.LoadBalancerIP != "" && net.ParseIP(sc.LoadBalancerIP) == nil {
+			err := field.Invalid(path.Child("loadBalancerIP"), sc.LoadBalancerIP, "not a valid IP address")
+			allErrs = append(allErrs, err)
+		}
+		for j, cidr := range sc.LoadBalancerSourceRanges {
+			if _, _, perr := net.ParseCIDR(cidr); perr != nil {
+				err := field.Invalid(path.Child("loadBalancerSourceRanges").Index(j), cidr, "not a valid CIDR range")
+				allErrs = append(allErrs, err)
+			}
+		}
+		if sc.ExternalTrafficPolicy != "" && sc.ServiceType != v1.ServiceTypeLoadBalancer && sc.ServiceType != v1.ServiceTypeNodePort {
+			err := field.Invalid(path.Child("externalTrafficPolicy"), sc.ExternalTrafficPolicy,
+				fmt.Sprintf("externalTrafficPolicy can only be specified for service types %s and %s",
+					v1.ServiceTypeLoadBalancer, v1.ServiceTypeNodePort))
+			allErrs = append(allErrs, err)
+		}
+		allErrs = v.validateServicePorts(allErrs, sc, path)
+	}
+	return allErrs
+}
+
+// validateServicePorts checks the ports that a subcluster exposes in its
+// service object
+func (v *VerticaDB) validateServicePorts(allErrs field.ErrorList, sc *Subcluster, path *field.Path) field.ErrorList {
+	seen := map[ServicePortName]bool{}
+	for j, port := range sc.ServicePorts {
+		if port != ServicePortVertica && port != ServicePortAgent {
+			err := field.Invalid(path.Child("servicePorts").Index(j), port,
+				fmt.Sprintf("must be either %s or %s", ServicePortVertica, ServicePortAgent))
+			allErrs = append(allErrs, err)
+		}
+		if seen[port] {
+			err := field.Invalid(path.Child("servicePorts").Index(j), port, "duplicates a port earlier in the list")
+			allErrs = append(allErrs, err)
+		}
+		seen[port] = true
+	}
+	// The nodePort is for the vertica port, so that port must be exposed
+	if sc.NodePort != 0 && !sc.IsServicePortExposed(ServicePortVertica) {
+		err := field.Invalid(path.Child("nodePort"), sc.NodePort,
+			fmt.Sprintf("nodePort requires the %s port to be in servicePorts", ServicePortVertica))
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
 func (v *VerticaDB) hasDuplicateScName(allErrs field.ErrorList) field.ErrorList {
 	countSc := len(v.Spec.Subclusters)
 	for i := 0; i < countSc-1; i++ {
@@ -428,6 +766,40 @@ func (v *VerticaDB) hasDuplicateScName(allErrs field.ErrorList) field.ErrorList
 	return allErrs
 }
 
+func (v *VerticaDB) canShutdownSubclusters(allErrs field.ErrorList) field.ErrorList {
+	// only secondary subclusters can be shutdown
+	for i := range v.Spec.Subclusters {
+		sc := &v.Spec.Subclusters[i]
+		if sc.Shutdown && sc.IsPrimary {
+			err := field.Invalid(field.NewPath("spec").Child("subclusters").Index(i).Child("shutdown"),
+				sc.Shutdown,
+				"a primary subcluster cannot be shutdown")
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+func (v *VerticaDB) validateKerberos(allErrs field.ErrorList) field.ErrorList {
+	// kerberos.realm and kerberos.krb5ConfigMap must be set if kerberos is enabled
+	if !v.IsKerberosEnabled() {
+		return allErrs
+	}
+	if v.Spec.Kerberos.Realm == "" {
+		err := field.Invalid(field.NewPath("spec").Child("kerberos").Child("realm"),
+			v.Spec.Kerberos.Realm,
+			"kerberos.realm must be set when kerberos.keytabSecret is set")
+		allErrs = append(allErrs, err)
+	}
+	if v.Spec.Kerberos.Krb5ConfigMap == "" {
+		err := field.Invalid(field.NewPath("spec").Child("kerberos").Child("krb5ConfigMap"),
+			v.Spec.Kerberos.Krb5ConfigMap,
+			"kerberos.krb5ConfigMap must be set when kerberos.keytabSecret is set")
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
 func (v *VerticaDB) canUpdateScName(oldObj *VerticaDB) bool {
 	scMap := map[string]*Subcluster{}
 	for i := range oldObj.Spec.Subclusters {
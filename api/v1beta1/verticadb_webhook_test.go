@@ -28,6 +28,13 @@ var _ = Describe("verticadb_webhook", func() {
 		vdb := createVDBHelper()
 		validateSpecValuesHaveErr(vdb, false)
 	})
+	It("should have a valid upgradePolicy", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.UpgradePolicy = OfflineUpgrade
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.UpgradePolicy = "Sideways"
+		validateSpecValuesHaveErr(vdb, true)
+	})
 	It("should not have DB name more than 30 characters", func() {
 		vdb := createVDBHelper()
 		vdb.Spec.DBName = "VeryLongLongLongLongVerticaDBName"
@@ -74,6 +81,121 @@ var _ = Describe("verticadb_webhook", func() {
 		vdb.Spec.Communal.Path = "http://nimbusdb/mspilchen"
 		validateSpecValuesHaveErr(vdb, true)
 	})
+	It("should allow a Google Cloud Storage communal path", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.Path = "gs://nimbusdb/mspilchen"
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.Path = "gs://"
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should default the endpoint for Google Cloud Storage", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.Path = "gs://nimbusdb/mspilchen"
+		vdb.Spec.Communal.Endpoint = ""
+		vdb.Default()
+		Expect(vdb.Spec.Communal.Endpoint).Should(Equal(DefaultGCloudEndpoint))
+		validateSpecValuesHaveErr(vdb, false)
+	})
+	It("should allow an Azure Blob Storage communal path", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.Path = "azb://account/container/db"
+		vdb.Spec.Communal.Endpoint = ""
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.Endpoint = "https://account.blob.core.windows.net"
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.Path = "azb://account"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Communal.Path = "azb://account/"
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should allow an HDFS communal path without an endpoint or credential secret", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.Path = "webhdfs://namenode:50070/db"
+		vdb.Spec.Communal.Endpoint = ""
+		vdb.Spec.Communal.CredentialSecret = ""
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.Path = "swebhdfs://namenode:50470/db"
+		vdb.Spec.Communal.HadoopConfig = "hadoop-conf"
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.Path = "swebhdfs://"
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should allow an s3 communal path without a credential secret", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.CredentialSecret = ""
+		validateSpecValuesHaveErr(vdb, false)
+	})
+	It("should require the kms key id only with SSE-KMS", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.S3ServerSideEncryption = SSES3
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.S3SSEKMSKeyID = "key-id"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Communal.S3ServerSideEncryption = SSEKMS
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.S3SSEKMSKeyID = ""
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should not allow server-side encryption outside of s3", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.Path = "gs://nimbusdb/mspilchen"
+		vdb.Spec.Communal.S3ServerSideEncryption = SSES3
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should not change the server-side encryption after creation", func() {
+		vdbUpdate := createVDBHelper()
+		vdbUpdate.Spec.Communal.S3ServerSideEncryption = SSES3
+		validateImmutableFields(vdbUpdate)
+	})
+	It("should only allow a caFile for an s3 endpoint with https", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Communal.CaFile = "s3-ca"
+		vdb.Spec.Communal.Endpoint = "https://minio"
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Communal.Endpoint = "http://minio"
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should only allow valid configuration parameter names", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.ConfigParameters = map[string]string{"MaxClientSessions": "100"}
+		vdb.Spec.Subclusters[0].ConfigParameters = map[string]string{"DepotOperationsForQuery": "NONE"}
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.ConfigParameters["x = 1; drop table t"] = "1"
+		validateSpecValuesHaveErr(vdb, true)
+		delete(vdb.Spec.ConfigParameters, "x = 1; drop table t")
+		vdb.Spec.Subclusters[0].ConfigParameters["1abc"] = "1"
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should not allow a sidecar to use the name of the server container", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Sidecars = []v1.Container{{Name: "vlogger", Image: "vertica/vertica-logger"}}
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Sidecars = append(vdb.Spec.Sidecars, v1.Container{Name: "server", Image: "busybox"})
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Sidecars[1].Name = "vlogger"
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should only mount volumes that are in the spec", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Volumes = []v1.Volume{{Name: "udx-libs", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}}
+		vdb.Spec.VolumeMounts = []v1.VolumeMount{{Name: "udx-libs", MountPath: "/opt/udx"}}
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.VolumeMounts[0].Name = "local-data"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.VolumeMounts[0].Name = "udx-libs"
+		vdb.Spec.Volumes = append(vdb.Spec.Volumes, v1.Volume{Name: "podinfo"})
+		validateSpecValuesHaveErr(vdb, true)
+	})
+	It("should only allow init scripts with a config map and a valid phase", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.InitScripts = []InitScript{{ConfigMap: "bootstrap", When: InitScriptPostCreate}}
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.InitScripts[0].When = "PostUpgrade"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.InitScripts[0].When = InitScriptPostSubclusterAdd
+		vdb.Spec.InitScripts[0].ConfigMap = ""
+		validateSpecValuesHaveErr(vdb, true)
+	})
 	It("should not have invalid communal endpoint", func() {
 		vdb := createVDBHelper()
 		vdb.Spec.Communal.Endpoint = "s3://minio"
@@ -93,6 +215,7 @@ var _ = Describe("verticadb_webhook", func() {
 	})
 	It("should not have empty credentialsecret", func() {
 		vdb := createVDBHelper()
+		vdb.Spec.Communal.Path = "gs://nimbusdb/mspilchen"
 		vdb.Spec.Communal.CredentialSecret = ""
 		validateSpecValuesHaveErr(vdb, true)
 	})
@@ -164,9 +287,21 @@ var _ = Describe("verticadb_webhook", func() {
 		vdbUpdate.Spec.ShardCount = 10
 		validateImmutableFields(vdbUpdate)
 	})
-	It("should not change image after creation if autoRestartVertica is enabled", func() {
+	It("should allow image change if autoRestartVertica is enabled", func() {
+		vdb := createVDBHelper()
 		vdbUpdate := createVDBHelper()
 		vdbUpdate.Spec.Image = "vertica-k8s:v11"
+		allErrs := vdb.validateImmutableFields(vdbUpdate)
+		Expect(allErrs).Should(BeNil())
+	})
+	It("should not change image while an image change is in progress", func() {
+		vdbUpdate := createVDBHelper()
+		vdbUpdate.Spec.Image = "vertica-k8s:v11"
+		vdbUpdate.Status.Conditions = make([]VerticaDBCondition, ImageChangeInProgressIndex+1)
+		vdbUpdate.Status.Conditions[ImageChangeInProgressIndex] = VerticaDBCondition{
+			Type:   ImageChangeInProgress,
+			Status: v1.ConditionTrue,
+		}
 		validateImmutableFields(vdbUpdate)
 	})
 	It("should allow image change if autoRestartVertica is disabled", func() {
@@ -178,6 +313,16 @@ var _ = Describe("verticadb_webhook", func() {
 		allErrs := vdb.validateImmutableFields(vdbUpdate)
 		Expect(allErrs).Should(BeNil())
 	})
+	It("should not change upgradePolicy while an image change is in progress", func() {
+		vdbUpdate := createVDBHelper()
+		vdbUpdate.Spec.UpgradePolicy = OfflineUpgrade
+		vdbUpdate.Status.Conditions = make([]VerticaDBCondition, ImageChangeInProgressIndex+1)
+		vdbUpdate.Status.Conditions[ImageChangeInProgressIndex] = VerticaDBCondition{
+			Type:   ImageChangeInProgress,
+			Status: v1.ConditionTrue,
+		}
+		validateImmutableFields(vdbUpdate)
+	})
 	It("should not change communal.path after creation", func() {
 		vdbUpdate := createVDBHelper()
 		vdbUpdate.Spec.Communal.Path = "s3://nimbusdb/spilchen"
@@ -188,11 +333,73 @@ var _ = Describe("verticadb_webhook", func() {
 		vdbUpdate.Spec.Communal.Endpoint = "https://minio"
 		validateImmutableFields(vdbUpdate)
 	})
-	It("should not change local.requestSize after creation", func() {
+	It("should only allow local.requestSize to be increased", func() {
+		vdb := createVDBHelper()
 		vdbUpdate := createVDBHelper()
 		vdbUpdate.Spec.Local.RequestSize = resource.MustParse("600Gi")
+		Expect(vdbUpdate.validateImmutableFields(vdb)).Should(BeNil())
+		vdbUpdate.Spec.Local.RequestSize = resource.MustParse("400Gi")
+		Expect(vdbUpdate.validateImmutableFields(vdb)).ShouldNot(BeNil())
+	})
+	It("should only allow local.depotRequestSize to be increased", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Local.DepotRequestSize = resource.MustParse("100Gi")
+		vdbUpdate := createVDBHelper()
+		vdbUpdate.Spec.Local.DepotRequestSize = resource.MustParse("200Gi")
+		Expect(vdbUpdate.validateImmutableFields(vdb)).Should(BeNil())
+		vdbUpdate.Spec.Local.DepotRequestSize = resource.MustParse("50Gi")
+		Expect(vdbUpdate.validateImmutableFields(vdb)).ShouldNot(BeNil())
+		vdbUpdate.Spec.Local.DepotRequestSize = resource.Quantity{}
+		Expect(vdbUpdate.validateImmutableFields(vdb)).ShouldNot(BeNil())
+	})
+	It("should not change the depot volume after creation", func() {
+		vdbUpdate := createVDBHelper()
+		vdbUpdate.Spec.Local.DepotRequestSize = resource.MustParse("100Gi")
+		validateImmutableFields(vdbUpdate)
+		vdbUpdate = createVDBHelper()
+		vdbUpdate.Spec.Local.DepotStorageClass = "nvme"
 		validateImmutableFields(vdbUpdate)
 	})
+	It("should only allow depotStorageClass with a depotRequestSize", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Local.DepotStorageClass = "nvme"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Local.DepotRequestSize = resource.MustParse("100Gi")
+		validateSpecValuesHaveErr(vdb, false)
+	})
+	It("should only allow the load balancer options with a LoadBalancer service", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Subclusters[0].LoadBalancerIP = "10.20.30.40"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].ServiceType = v1.ServiceTypeLoadBalancer
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Subclusters[0].LoadBalancerIP = "not-an-ip"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].LoadBalancerIP = ""
+		vdb.Spec.Subclusters[0].LoadBalancerSourceRanges = []string{"10.0.0.0/8", "10.1.2.3"}
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+		validateSpecValuesHaveErr(vdb, false)
+	})
+	It("should only allow externalTrafficPolicy with a NodePort or LoadBalancer service", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Subclusters[0].ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].ServiceType = v1.ServiceTypeNodePort
+		validateSpecValuesHaveErr(vdb, false)
+	})
+	It("should validate the service ports", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Subclusters[0].ServicePorts = []ServicePortName{ServicePortVertica, ServicePortVertica}
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].ServicePorts = []ServicePortName{"ssh"}
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].ServicePorts = []ServicePortName{ServicePortAgent}
+		validateSpecValuesHaveErr(vdb, false)
+		vdb.Spec.Subclusters[0].ServiceType = v1.ServiceTypeNodePort
+		vdb.Spec.Subclusters[0].NodePort = 30046
+		validateSpecValuesHaveErr(vdb, true)
+	})
 	It("should not change local.storageClass after creation", func() {
 		vdbUpdate := createVDBHelper()
 		vdbUpdate.Spec.Local.StorageClass = "MyStorageClass"
@@ -222,6 +429,26 @@ var _ = Describe("verticadb_webhook", func() {
 		validateSpecValuesHaveErr(vdb, true)
 	})
 
+	It("should only allow secondary subclusters to be shutdown", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Subclusters[0].Shutdown = true
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Subclusters[0].Shutdown = false
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, Subcluster{Name: "sc2", Size: 3, Shutdown: true,
+			ServiceType: v1.ServiceTypeClusterIP})
+		validateSpecValuesHaveErr(vdb, false)
+	})
+
+	It("should require a restore point location if initPolicy is Restore", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.InitPolicy = CommunalInitPolicyRestore
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.RestorePoint.Location.Path = "/backups"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.RestorePoint.Location.Path = "s3://nimbusdb/backups"
+		validateSpecValuesHaveErr(vdb, false)
+	})
+
 	It("should only allow nodePort if serviceType allows for it", func() {
 		vdb := createVDBHelper()
 		vdb.Spec.Subclusters[0].ServiceType = v1.ServiceTypeNodePort
@@ -230,6 +457,16 @@ var _ = Describe("verticadb_webhook", func() {
 		vdb.Spec.Subclusters[0].ServiceType = v1.ServiceTypeClusterIP
 		validateSpecValuesHaveErr(vdb, true)
 	})
+
+	It("should require a realm and krb5.conf if kerberos is enabled", func() {
+		vdb := createVDBHelper()
+		vdb.Spec.Kerberos.KeytabSecret = "keytab"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Kerberos.Realm = "EXAMPLE.COM"
+		validateSpecValuesHaveErr(vdb, true)
+		vdb.Spec.Kerberos.Krb5ConfigMap = "krb5-conf"
+		validateSpecValuesHaveErr(vdb, false)
+	})
 })
 
 func createVDBHelper() *VerticaDB {
@@ -129,6 +129,38 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = (&controllers.VerticaBackupReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("VerticaBackup"),
+		Scheme: mgr.GetScheme(),
+		Cfg:    restCfg,
+		EVRec:  mgr.GetEventRecorderFor(controllers.OperatorName),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VerticaBackup")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.VerticaBackupScheduleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("VerticaBackupSchedule"),
+		Scheme: mgr.GetScheme(),
+		Cfg:    restCfg,
+		EVRec:  mgr.GetEventRecorderFor(controllers.OperatorName),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VerticaBackupSchedule")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.VerticaAutoscalerReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("VerticaAutoscaler"),
+		Scheme: mgr.GetScheme(),
+		EVRec:  mgr.GetEventRecorderFor(controllers.OperatorName),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VerticaAutoscaler")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
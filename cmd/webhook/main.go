@@ -92,6 +92,10 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "VerticaDB")
 		os.Exit(1)
 	}
+	if err = (&verticacomv1beta1.VerticaAutoscaler{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "VerticaAutoscaler")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
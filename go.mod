@@ -8,6 +8,7 @@ require (
 	github.com/go-logr/logr v0.3.0
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/vertica/vertica-sql-go v1.1.1
 	k8s.io/api v0.19.2
 	k8s.io/apimachinery v0.19.2
@@ -81,4 +81,16 @@ var _ = Describe("k8s/cmds", func() {
 		lastCall := fpr.FindCommands("/opt/vertica/bin/admintools", "-t", "db_add_node")
 		Expect(len(lastCall)).Should(Equal(1))
 	})
+	It("should hide the passwords when logging a command", func() {
+		cmd := obfuscateCmd("vsql", "-w", "old", "-c", "alter user dbadmin identified by 'n''ew'")
+		Expect(cmd).ShouldNot(ContainSubstring("old"))
+		Expect(cmd).ShouldNot(ContainSubstring("ew"))
+		Expect(cmd).Should(ContainSubstring("alter user dbadmin identified by '*******'"))
+	})
+
+	It("should hide base64 encoded data when logging a command", func() {
+		cmd := obfuscateCmd("bash", "-c", "echo 'c2VjcmV0Cg==' | base64 -d > /tmp/f")
+		Expect(cmd).ShouldNot(ContainSubstring("c2VjcmV0Cg=="))
+		Expect(cmd).Should(ContainSubstring("echo '*******' | base64 -d > /tmp/f"))
+	})
 })
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -34,6 +35,7 @@ type PodRunner interface {
 	ExecInPod(ctx context.Context, podName types.NamespacedName, contName string, command ...string) (string, string, error)
 	ExecVSQL(ctx context.Context, podName types.NamespacedName, contName string, command ...string) (string, string, error)
 	ExecAdmintools(ctx context.Context, podName types.NamespacedName, contName string, command ...string) (string, string, error)
+	SetSUPassword(passwd string)
 }
 
 type ClusterPodRunner struct {
@@ -47,8 +49,27 @@ func MakeClusterPodRunner(log logr.Logger, cfg *rest.Config, passwd string) *Clu
 	return &ClusterPodRunner{Log: log, Cfg: cfg, SUPassword: passwd}
 }
 
+// identifiedByRegexp matches the password of an 'identified by' clause so that
+// it can be hidden when logging a command that changes a password
+var identifiedByRegexp = regexp.MustCompile(`(?i)(identified\s+by\s+)'(?:[^']|'')*'`)
+
+// base64PayloadRegexp matches data that is decoded with base64 in the pod.  It
+// is hidden when logging as it can be SQL that has passwords in it.
+var base64PayloadRegexp = regexp.MustCompile(`(echo\s+)'[A-Za-z0-9+/=]*'(\s*\|\s*base64\s+-d)`)
+
+// SetSUPassword changes the superuser password used for vsql and admintools
+func (c *ClusterPodRunner) SetSUPassword(passwd string) {
+	c.SUPassword = passwd
+}
+
 // logInfoCmd calls log function after obfuscating the password
 func (c *ClusterPodRunner) logInfoCmd(podName types.NamespacedName, command ...string) {
+	c.Log.Info("ExecInPod entry", "pod", podName, "command", obfuscateCmd(command...))
+}
+
+// obfuscateCmd returns the command as a single string with any password in it
+// replaced by asterisks
+func obfuscateCmd(command ...string) string {
 	var sb strings.Builder
 	for i := 0; i < len(command); i++ {
 		switch command[i] {
@@ -58,11 +79,12 @@ func (c *ClusterPodRunner) logInfoCmd(podName types.NamespacedName, command ...s
 			sb.WriteString("*******")
 			i++
 		default:
-			sb.WriteString(command[i])
+			arg := identifiedByRegexp.ReplaceAllString(command[i], "$1'*******'")
+			sb.WriteString(base64PayloadRegexp.ReplaceAllString(arg, "$1'*******'$2"))
 		}
 		sb.WriteString(" ")
 	}
-	c.Log.Info("ExecInPod entry", "pod", podName, "command", sb.String())
+	return sb.String()
 }
 
 // ExecInPod executes arbitrary command inside of a pod and returns the output.
@@ -169,5 +191,6 @@ func getSupportingPasswdSlice() []string {
 	return []string{
 		"db_add_node", "db_add_subcluster", "db_remove_node",
 		"db_remove_subcluster", "create_db", "restart_node", "start_db",
+		"stop_db",
 	}
 }
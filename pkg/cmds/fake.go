@@ -86,6 +86,11 @@ func (f *FakePodRunner) ExecVSQL(ctx context.Context, podName types.NamespacedNa
 	return f.ExecInPod(ctx, podName, contName, command...)
 }
 
+// SetSUPassword changes the fake password used for vsql and admintools
+func (f *FakePodRunner) SetSUPassword(passwd string) {
+	f.SUPassword = passwd
+}
+
 // FindCommands will search through the command history for any command that
 // contains the given partial command.
 func (f *FakePodRunner) FindCommands(partialCmd ...string) []CmdHistory {
@@ -0,0 +1,82 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"strings"
+
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+)
+
+const (
+	// The name of the key in the communal credential secret that holds the
+	// Azure storage account key
+	AzureAccountKeyName = "accountKey"
+	// The name of the key in the communal credential secret that holds an
+	// Azure shared access signature.  This is used in place of the account key.
+	AzureSASName = "sharedAccessSignature"
+)
+
+// AzureCredential is one entry in the AzureStorageCredentials parameter
+type AzureCredential struct {
+	AccountName           string `json:"accountName"`
+	BlobEndpoint          string `json:"blobEndpoint,omitempty"`
+	AccountKey            string `json:"accountKey,omitempty"`
+	SharedAccessSignature string `json:"sharedAccessSignature,omitempty"`
+}
+
+// AzureEndpointConfig is one entry in the AzureStorageEndpointConfig parameter
+type AzureEndpointConfig struct {
+	AccountName  string `json:"accountName"`
+	BlobEndpoint string `json:"blobEndpoint"`
+	Protocol     string `json:"protocol"`
+}
+
+// getAzureAccountName returns the storage account from the communal path.
+// Azure paths have the form azb://account/container/path.
+func getAzureAccountName(vdb *vapi.VerticaDB) string {
+	return strings.SplitN(strings.TrimPrefix(vdb.Spec.Communal.Path, vapi.AzurePrefix), "/", 2)[0]
+}
+
+// genAzureCredentials returns the value for the AzureStorageCredentials
+// parameter.  Only one of accountKey or sas is expected to be set.
+func genAzureCredentials(accountName, blobEndpoint, accountKey, sas string) (string, error) {
+	creds := []AzureCredential{
+		{
+			AccountName:           accountName,
+			BlobEndpoint:          blobEndpoint,
+			AccountKey:            accountKey,
+			SharedAccessSignature: sas,
+		},
+	}
+	b, err := json.Marshal(creds)
+	return string(b), err
+}
+
+// genAzureEndpointConfig returns the value for the AzureStorageEndpointConfig
+// parameter
+func genAzureEndpointConfig(accountName, blobEndpoint, protocol string) (string, error) {
+	cfgs := []AzureEndpointConfig{
+		{
+			AccountName:  accountName,
+			BlobEndpoint: blobEndpoint,
+			Protocol:     protocol,
+		},
+	}
+	b, err := json.Marshal(cfgs)
+	return string(b), err
+}
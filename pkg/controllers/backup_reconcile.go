@@ -0,0 +1,141 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// BackupReconciler will take a backup of the database with 'vbr -t backup'
+type BackupReconciler struct {
+	VRec    *VerticaBackupReconciler
+	Log     logr.Logger
+	Vb      *vapi.VerticaBackup // Vb is the CRD we are acting on.
+	Vdb     *vapi.VerticaDB     // Vdb is the database that we are taking a backup of.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+	VBR     *VBRRunner
+}
+
+// MakeBackupReconciler will build a BackupReconciler object
+func MakeBackupReconciler(vbrecon *VerticaBackupReconciler, log logr.Logger, vb *vapi.VerticaBackup,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts, passwd string) ReconcileActor {
+	return &BackupReconciler{VRec: vbrecon, Log: log, Vb: vb, Vdb: vdb, PRunner: prunner, PFacts: pfacts,
+		VBR: MakeVBRRunner(vbrecon.Client, vbrecon.EVRec, log, vdb, &vb.Spec.Location, prunner, vb,
+			passwd, vb.Spec.RestorePointLimit),
+	}
+}
+
+// Reconcile will run vbr to take the backup and record the outcome in the
+// status of the VerticaBackup.
+func (b *BackupReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if b.Vb.IsComplete() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := b.PFacts.Collect(ctx, b.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// vbr needs the database to be running, so we must pick a pod that has
+	// an up vertica node.
+	pod, ok := b.PFacts.findPodToRunVsql()
+	if !ok {
+		b.Log.Info("No up pod found to run vbr from. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, b.setPhase(ctx, vapi.BackupPending,
+			"Waiting for an up vertica node to run vbr from")
+	}
+
+	if res, err := b.VBR.Setup(ctx, pod.name); err != nil || res.Requeue {
+		return res, err
+	}
+	defer func() {
+		// Removing the credentials is a best effort.  If it fails we continue
+		// on, as the file is overwritten with the next backup.
+		if err := b.VBR.Cleanup(ctx, pod.name); err != nil {
+			b.Log.Info("failed to cleanup vbr files, ignoring failure", "err", err)
+		}
+	}()
+
+	if err := b.startBackup(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	start := time.Now()
+	if _, err := b.VBR.RunTask(ctx, pod.name, "backup"); err != nil {
+		b.VRec.EVRec.Eventf(b.Vb, corev1.EventTypeWarning, events.BackupFailed,
+			"Failed while calling 'vbr -t backup' for VerticaDB '%s'", b.Vdb.Name)
+		// We don't return the error, as we don't retry a failed backup.
+		return ctrl.Result{}, b.finishBackup(ctx, vapi.BackupFailed, "", fmt.Sprintf("vbr failed: %s", err))
+	}
+
+	rps, err := b.VBR.ListRestorePoints(ctx, pod.name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	rpID := ""
+	if len(rps) > 0 {
+		rpID = rps[0].ID
+	}
+	b.VRec.EVRec.Eventf(b.Vb, corev1.EventTypeNormal, events.BackupSucceeded,
+		"Successfully called 'vbr -t backup' and it took %s.  Restore point is '%s'", time.Since(start), rpID)
+	return ctrl.Result{}, b.finishBackup(ctx, vapi.BackupSucceeded, rpID, "")
+}
+
+// startBackup will move the backup to the running phase
+func (b *BackupReconciler) startBackup(ctx context.Context) error {
+	b.VRec.EVRec.Eventf(b.Vb, corev1.EventTypeNormal, events.BackupStart,
+		"Calling 'vbr -t backup' for VerticaDB '%s'", b.Vdb.Name)
+	return status.UpdateBackup(ctx, b.VRec.Client, b.Vb, func(vb *vapi.VerticaBackup) error {
+		now := metav1.Now()
+		vb.Status.Phase = vapi.BackupRunning
+		vb.Status.StartTime = &now
+		vb.Status.Message = ""
+		return nil
+	})
+}
+
+// finishBackup will record the final phase of the backup
+func (b *BackupReconciler) finishBackup(ctx context.Context, phase vapi.BackupPhase, rpID, msg string) error {
+	return status.UpdateBackup(ctx, b.VRec.Client, b.Vb, func(vb *vapi.VerticaBackup) error {
+		now := metav1.Now()
+		vb.Status.Phase = phase
+		vb.Status.CompletionTime = &now
+		vb.Status.RestorePointID = rpID
+		vb.Status.Message = msg
+		return nil
+	})
+}
+
+// setPhase will update the phase and message of the backup
+func (b *BackupReconciler) setPhase(ctx context.Context, phase vapi.BackupPhase, msg string) error {
+	return status.UpdateBackup(ctx, b.VRec.Client, b.Vb, func(vb *vapi.VerticaBackup) error {
+		vb.Status.Phase = phase
+		vb.Status.Message = msg
+		return nil
+	})
+}
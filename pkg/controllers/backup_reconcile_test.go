@@ -0,0 +1,155 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("backup_reconcile", func() {
+	ctx := context.Background()
+
+	It("should wait in the pending phase if no vertica node is up", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsNotRunning)
+		defer deletePods(ctx, vdb)
+		vb := vapi.MakeVBackup()
+		createVBackup(ctx, vb)
+		defer deleteVBackup(ctx, vb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeBackupReconciler(makeVBackupReconciler(), logger, vb, vdb, fpr, &pfacts, "")
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(vb.Status.Phase).Should(Equal(vapi.BackupPending))
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+
+	It("should run vbr and record the restore point", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+		vb := vapi.MakeVBackup()
+		createVBackup(ctx, vb)
+		defer deleteVBackup(ctx, vb)
+
+		fpr := &cmds.FakePodRunner{Results: make(cmds.CmdResults)}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		fpr.Results[podName] = []cmds.CmdResult{
+			{}, // setup
+			{}, // vbr -t backup
+			{Stdout: "backup                  backup_type   epoch   objects   include_patterns   exclude_patterns   nodes(hosts)\n" +
+				"backup_20210802_093012  full          120     \n" +
+				"backup_20210801_093012  full          100     \n"},
+		}
+		r := MakeBackupReconciler(makeVBackupReconciler(), logger, vb, vdb, fpr, &pfacts, "")
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+		bkCmd := fpr.FindCommands(VbrPath, "-t", "backup")
+		Expect(len(bkCmd)).Should(Equal(1))
+		Expect(bkCmd[0].Pod).Should(Equal(podName))
+		Expect(vb.Status.Phase).Should(Equal(vapi.BackupSucceeded))
+		Expect(vb.Status.RestorePointID).Should(Equal("20210802_093012"))
+		Expect(vb.Status.StartTime).ShouldNot(BeNil())
+		Expect(vb.Status.CompletionTime).ShouldNot(BeNil())
+	})
+
+	It("should mark the backup as failed if vbr fails", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+		vb := vapi.MakeVBackup()
+		createVBackup(ctx, vb)
+		defer deleteVBackup(ctx, vb)
+
+		fpr := &cmds.FakePodRunner{Results: make(cmds.CmdResults)}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		fpr.Results[podName] = []cmds.CmdResult{
+			{}, // setup
+			{Err: errors.New("vbr failed")},
+		}
+		r := MakeBackupReconciler(makeVBackupReconciler(), logger, vb, vdb, fpr, &pfacts, "")
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vb.Status.Phase).Should(Equal(vapi.BackupFailed))
+		Expect(vb.Status.CompletionTime).ShouldNot(BeNil())
+
+		// A failed backup is not retried
+		fpr.Histories = []cmds.CmdHistory{}
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+
+	It("should requeue if the credential secret is missing", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		vb := vapi.MakeVBackup()
+		createVBackup(ctx, vb)
+		defer deleteVBackup(ctx, vb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeBackupReconciler(makeVBackupReconciler(), logger, vb, vdb, fpr, &pfacts, "")
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+})
+
+// makeVBackupReconciler will build a VerticaBackupReconciler that shares the
+// client and event recorder of the VerticaDB reconciler used in the tests.
+func makeVBackupReconciler() *VerticaBackupReconciler {
+	return &VerticaBackupReconciler{
+		Client: vrec.Client,
+		Log:    vrec.Log,
+		Scheme: vrec.Scheme,
+		Cfg:    vrec.Cfg,
+		EVRec:  vrec.EVRec,
+	}
+}
+
+func createVBackup(ctx context.Context, vb *vapi.VerticaBackup) {
+	ExpectWithOffset(1, k8sClient.Create(ctx, vb)).Should(Succeed())
+}
+
+func deleteVBackup(ctx context.Context, vb *vapi.VerticaBackup) {
+	ExpectWithOffset(1, k8sClient.Delete(ctx, vb)).Should(Succeed())
+}
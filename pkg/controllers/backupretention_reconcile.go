@@ -0,0 +1,177 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BackupRetentionReconciler will act on the outcome of the last backup that
+// a schedule created.  A failed backup generates an event.  After a
+// successful backup, restore points outside of the retention are removed.
+type BackupRetentionReconciler struct {
+	VRec    *VerticaBackupScheduleReconciler
+	Log     logr.Logger
+	Vbs     *vapi.VerticaBackupSchedule // Vbs is the CRD we are acting on.
+	Vdb     *vapi.VerticaDB             // Vdb is the database that is backed up.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+	VBR     *VBRRunner
+	// The current time.  Restore points are aged relative to this.
+	Now time.Time
+}
+
+// MakeBackupRetentionReconciler will build a BackupRetentionReconciler object
+func MakeBackupRetentionReconciler(vbsrecon *VerticaBackupScheduleReconciler, log logr.Logger,
+	vbs *vapi.VerticaBackupSchedule, vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts,
+	passwd string) ReconcileActor {
+	return &BackupRetentionReconciler{VRec: vbsrecon, Log: log, Vbs: vbs, Vdb: vdb, PRunner: prunner, PFacts: pfacts,
+		VBR: MakeVBRRunner(vbsrecon.Client, vbsrecon.EVRec, log, vdb, &vbs.Spec.Location, prunner, vbs,
+			passwd, ScheduledRestorePointLimit),
+		Now: time.Now().UTC(),
+	}
+}
+
+// Reconcile will check if the last backup has completed since we last looked
+// at it, and act on its outcome.
+func (b *BackupRetentionReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if b.Vbs.Status.LastBackupName == "" {
+		return ctrl.Result{}, nil
+	}
+	vb := &vapi.VerticaBackup{}
+	nm := types.NamespacedName{Namespace: b.Vbs.Namespace, Name: b.Vbs.Status.LastBackupName}
+	if err := b.VRec.Client.Get(ctx, nm, vb); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !vb.IsComplete() || b.Vbs.Status.LastBackupPhase == vb.Status.Phase {
+		return ctrl.Result{}, nil
+	}
+
+	if vb.Status.Phase == vapi.BackupFailed {
+		b.VRec.EVRec.Eventf(b.Vbs, corev1.EventTypeWarning, events.ScheduledBackupFailed,
+			"The scheduled backup '%s' failed: %s", vb.Name, vb.Status.Message)
+	} else if res, err := b.pruneRestorePoints(ctx); err != nil || res.Requeue {
+		return res, err
+	}
+
+	return ctrl.Result{}, status.UpdateBackupSchedule(ctx, b.VRec.Client, b.Vbs, func(vbs *vapi.VerticaBackupSchedule) error {
+		vbs.Status.LastBackupPhase = vb.Status.Phase
+		if vb.Status.Phase == vapi.BackupSucceeded {
+			vbs.Status.LastSuccessfulTime = vb.Status.CompletionTime
+		}
+		return nil
+	})
+}
+
+// pruneRestorePoints will remove the restore points that are outside of the
+// retention with 'vbr -t remove'.  Any VerticaBackup that the schedule created
+// for a removed restore point is deleted too.
+func (b *BackupRetentionReconciler) pruneRestorePoints(ctx context.Context) (ctrl.Result, error) {
+	if b.Vbs.Spec.Retention.KeepLast == 0 && b.Vbs.Spec.Retention.KeepFor.Duration == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if err := b.PFacts.Collect(ctx, b.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	atPod, ok := b.PFacts.findPodToRunAdmintools()
+	if !ok {
+		b.Log.Info("No pod found to run vbr from. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if res, err := b.VBR.Setup(ctx, atPod.name); err != nil || res.Requeue {
+		return res, err
+	}
+	defer func() {
+		if err := b.VBR.Cleanup(ctx, atPod.name); err != nil {
+			b.Log.Info("failed to cleanup vbr files, ignoring failure", "err", err)
+		}
+	}()
+
+	rps, err := b.VBR.ListRestorePoints(ctx, atPod.name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	expired := b.findExpiredRestorePoints(rps)
+	if len(expired) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	removed := map[string]bool{}
+	ids := make([]string, 0, len(expired))
+	for _, rp := range expired {
+		if _, err := b.VBR.RunTask(ctx, atPod.name, "remove", "--archive="+rp.ID); err != nil {
+			return ctrl.Result{}, err
+		}
+		removed[rp.ID] = true
+		ids = append(ids, rp.ID)
+	}
+	b.VRec.EVRec.Eventf(b.Vbs, corev1.EventTypeNormal, events.RestorePointsPruned,
+		"Removed %d restore point(s) that are outside of the retention: %s", len(ids), strings.Join(ids, ", "))
+
+	return ctrl.Result{}, b.deleteBackupsForRestorePoints(ctx, removed)
+}
+
+// findExpiredRestorePoints returns the restore points that are outside of the
+// retention.  The restore points must be sorted with the newest first.  The
+// newest restore point is never returned.
+func (b *BackupRetentionReconciler) findExpiredRestorePoints(rps []RestorePoint) []RestorePoint {
+	expired := []RestorePoint{}
+	keepLast := b.Vbs.Spec.Retention.KeepLast
+	keepFor := b.Vbs.Spec.Retention.KeepFor.Duration
+	for i := 1; i < len(rps); i++ {
+		if (keepLast > 0 && i >= keepLast) || (keepFor > 0 && rps[i].Time.Before(b.Now.Add(-keepFor))) {
+			expired = append(expired, rps[i])
+		}
+	}
+	return expired
+}
+
+// deleteBackupsForRestorePoints will delete the VerticaBackup objects that
+// this schedule created whose restore point has been removed.
+func (b *BackupRetentionReconciler) deleteBackupsForRestorePoints(ctx context.Context, removed map[string]bool) error {
+	vbs := &vapi.VerticaBackupList{}
+	if err := b.VRec.Client.List(ctx, vbs, client.InNamespace(b.Vbs.Namespace),
+		client.MatchingLabels{BackupScheduleLabel: b.Vbs.Name}); err != nil {
+		return err
+	}
+	for i := range vbs.Items {
+		if !removed[vbs.Items[i].Status.RestorePointID] {
+			continue
+		}
+		if err := b.VRec.Client.Delete(ctx, &vbs.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("backupretention_reconcile", func() {
+	ctx := context.Background()
+	lastSchedule := time.Date(2021, 7, 5, 10, 0, 0, 0, time.UTC)
+	const ListBackupOutput = "backup  backup_type   epoch\n" +
+		"scheduled_20210705_100012  full  130\n" +
+		"scheduled_20210705_090012  full  120\n" +
+		"scheduled_20210705_080012  full  110\n"
+
+	It("should record a failed backup without pruning", func() {
+		vdb := vapi.MakeVDB()
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Retention.KeepLast = 1
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+		vb := createScheduledVBackup(ctx, vbs, "20210705_100012", vapi.BackupFailed)
+		defer deleteVBackupIfExists(ctx, types.NamespacedName{Namespace: vb.Namespace, Name: vb.Name})
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeBackupRetentionReconciler(makeVBackupScheduleReconciler(), logger, vbs, vdb, fpr, &pfacts, "")
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vbs.Status.LastBackupPhase).Should(Equal(vapi.BackupFailed))
+		Expect(vbs.Status.LastSuccessfulTime).Should(BeNil())
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+
+	It("should remove restore points outside of keepLast after a successful backup", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Retention.KeepLast = 2
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+		oldVb := createScheduledVBackup(ctx, vbs, "20210705_080012", vapi.BackupSucceeded)
+		oldNm := types.NamespacedName{Namespace: oldVb.Namespace, Name: oldVb.Name}
+		defer deleteVBackupIfExists(ctx, oldNm)
+		vb := createScheduledVBackup(ctx, vbs, "20210705_100012", vapi.BackupSucceeded)
+		defer deleteVBackupIfExists(ctx, types.NamespacedName{Namespace: vb.Namespace, Name: vb.Name})
+
+		fpr := &cmds.FakePodRunner{Results: make(cmds.CmdResults)}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		fpr.Results[podName] = []cmds.CmdResult{
+			{}, // setup
+			{Stdout: ListBackupOutput},
+		}
+		r := MakeBackupRetentionReconciler(makeVBackupScheduleReconciler(), logger, vbs, vdb, fpr, &pfacts, "")
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+		rmCmds := fpr.FindCommands(VbrPath, "-t", "remove")
+		Expect(len(rmCmds)).Should(Equal(1))
+		Expect(rmCmds[0].Command[2]).Should(ContainSubstring("--archive=20210705_080012"))
+		Expect(kerrors.IsNotFound(k8sClient.Get(ctx, oldNm, &vapi.VerticaBackup{}))).Should(BeTrue())
+		Expect(vbs.Status.LastBackupPhase).Should(Equal(vapi.BackupSucceeded))
+		Expect(vbs.Status.LastSuccessfulTime).ShouldNot(BeNil())
+
+		// The outcome has been recorded, so we don't prune again
+		fpr.Histories = []cmds.CmdHistory{}
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+
+	It("should find expired restore points by age and count", func() {
+		vbs := vapi.MakeVBackupSchedule()
+		act := MakeBackupRetentionReconciler(makeVBackupScheduleReconciler(), logger, vbs, vapi.MakeVDB(),
+			&cmds.FakePodRunner{}, nil, "")
+		r := act.(*BackupRetentionReconciler)
+		r.Now = time.Date(2021, 7, 5, 10, 30, 0, 0, time.UTC)
+		rps := parseRestorePoints(ListBackupOutput, "scheduled")
+
+		Expect(len(r.findExpiredRestorePoints(rps))).Should(Equal(0))
+
+		vbs.Spec.Retention.KeepFor = metav1.Duration{Duration: 90 * time.Minute}
+		expired := r.findExpiredRestorePoints(rps)
+		Expect(len(expired)).Should(Equal(1))
+		Expect(expired[0].ID).Should(Equal("20210705_080012"))
+
+		// The newest restore point is always kept
+		vbs.Spec.Retention.KeepFor = metav1.Duration{Duration: time.Minute}
+		Expect(len(r.findExpiredRestorePoints(rps))).Should(Equal(2))
+
+		vbs.Spec.Retention.KeepFor = metav1.Duration{}
+		vbs.Spec.Retention.KeepLast = 1
+		Expect(len(r.findExpiredRestorePoints(rps))).Should(Equal(2))
+	})
+})
+
+// createScheduledVBackup will create a VerticaBackup as though the schedule
+// created it, and set its status to a completed phase.  The schedule's last
+// backup is set to it.
+func createScheduledVBackup(ctx context.Context, vbs *vapi.VerticaBackupSchedule, rpID string,
+	phase vapi.BackupPhase) *vapi.VerticaBackup {
+	vb := vapi.MakeVBackup()
+	vb.Name = vbs.Name + "-" + rpID
+	vb.Labels = map[string]string{BackupScheduleLabel: vbs.Name}
+	ExpectWithOffset(1, k8sClient.Create(ctx, vb)).Should(Succeed())
+	ExpectWithOffset(1, status.UpdateBackup(ctx, k8sClient, vb, func(v *vapi.VerticaBackup) error {
+		now := metav1.Now()
+		v.Status.Phase = phase
+		v.Status.CompletionTime = &now
+		v.Status.RestorePointID = rpID
+		return nil
+	})).Should(Succeed())
+	ExpectWithOffset(1, status.UpdateBackupSchedule(ctx, k8sClient, vbs, func(v *vapi.VerticaBackupSchedule) error {
+		v.Status.LastBackupName = vb.Name
+		return nil
+	})).Should(Succeed())
+	return vb
+}
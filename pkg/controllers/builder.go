@@ -23,13 +23,28 @@ import (
 	"github.com/vertica/vertica-kubernetes/pkg/paths"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
-	LicensingMountName = "licensing"
-	PodInfoMountName   = "podinfo"
+	LicensingMountName  = "licensing"
+	PodInfoMountName    = "podinfo"
+	Krb5KeytabMountName = "krb5-keytab"
+	Krb5ConfMountName   = "krb5-conf"
+	ServerCertMountName = "server-cert"
+	HadoopConfMountName = "hadoop-conf"
+	CommunalCAMountName = "communal-ca"
+
+	// The keys in the keytab secret and krb5 config map that we mount
+	Krb5KeytabKey = "krb5.keytab"
+	Krb5ConfKey   = "krb5.conf"
+
+	// The key in the communal CA secret that has the CA bundle
+	CommunalCAKey = "ca.crt"
 )
 
 // buildExtSvc creates desired spec for the external service.
@@ -39,20 +54,33 @@ func buildExtSvc(nm types.NamespacedName, vdb *vapi.VerticaDB, sc *vapi.Subclust
 			Name:        nm.Name,
 			Namespace:   nm.Namespace,
 			Labels:      makeLabelsForSvcObject(vdb, sc, "external"),
-			Annotations: makeAnnotationsForObject(vdb),
+			Annotations: makeAnnotationsForExtSvc(vdb, sc),
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: makeSvcSelectorLabels(vdb, sc),
-			Type:     sc.ServiceType,
-			Ports: []corev1.ServicePort{
-				{Port: 5433, Name: "vertica", NodePort: sc.NodePort},
-				{Port: 5444, Name: "agent"},
-			},
-			ExternalIPs: sc.ExternalIPs,
+			Selector:                 makeClientRoutingSelectorLabels(vdb, sc),
+			Type:                     sc.ServiceType,
+			Ports:                    buildExtSvcPorts(sc),
+			ExternalIPs:              sc.ExternalIPs,
+			LoadBalancerIP:           sc.LoadBalancerIP,
+			LoadBalancerSourceRanges: sc.LoadBalancerSourceRanges,
+			ExternalTrafficPolicy:    sc.ExternalTrafficPolicy,
 		},
 	}
 }
 
+// buildExtSvcPorts returns the ports that the external service of a
+// subcluster exposes
+func buildExtSvcPorts(sc *vapi.Subcluster) []corev1.ServicePort {
+	ports := []corev1.ServicePort{}
+	if sc.IsServicePortExposed(vapi.ServicePortVertica) {
+		ports = append(ports, corev1.ServicePort{Port: 5433, Name: string(vapi.ServicePortVertica), NodePort: sc.NodePort})
+	}
+	if sc.IsServicePortExposed(vapi.ServicePortAgent) {
+		ports = append(ports, corev1.ServicePort{Port: 5444, Name: string(vapi.ServicePortAgent)})
+	}
+	return ports
+}
+
 // buildHlSvc creates the desired spec for the headless service.
 func buildHlSvc(nm types.NamespacedName, vdb *vapi.VerticaDB) *corev1.Service {
 	return &corev1.Service{
@@ -81,10 +109,24 @@ func buildVolumeMounts(vdb *vapi.VerticaDB) []corev1.VolumeMount {
 		{Name: LocalDataPVC, SubPath: paths.GetPVSubPath(vdb, "config"), MountPath: paths.ConfigPath},
 		{Name: LocalDataPVC, SubPath: paths.GetPVSubPath(vdb, "log"), MountPath: paths.LogPath},
 		{Name: LocalDataPVC, SubPath: paths.GetPVSubPath(vdb, "data"), MountPath: vdb.Spec.Local.DataPath},
-		{Name: LocalDataPVC, SubPath: paths.GetPVSubPath(vdb, "depot"), MountPath: vdb.Spec.Local.DepotPath},
 		{Name: PodInfoMountName, MountPath: paths.PodInfoPath},
 	}
 
+	// The depot is either carved out of the local data PVC, or it has its own
+	// PVC.  The subpath is the same in both cases.
+	if vdb.HasSeparateDepotVolume() {
+		volMnts = append(volMnts,
+			corev1.VolumeMount{Name: LocalDepotPVC, MountPath: paths.LocalDepotPath},
+			corev1.VolumeMount{Name: LocalDepotPVC, SubPath: paths.GetPVSubPath(vdb, "depot"), MountPath: vdb.Spec.Local.DepotPath},
+		)
+	} else {
+		volMnts = append(volMnts, corev1.VolumeMount{
+			Name:      LocalDataPVC,
+			SubPath:   paths.GetPVSubPath(vdb, "depot"),
+			MountPath: vdb.Spec.Local.DepotPath,
+		})
+	}
+
 	if vdb.Spec.LicenseSecret != "" {
 		volMnts = append(volMnts, corev1.VolumeMount{
 			Name:      LicensingMountName,
@@ -92,6 +134,37 @@ func buildVolumeMounts(vdb *vapi.VerticaDB) []corev1.VolumeMount {
 		})
 	}
 
+	if vdb.IsKerberosEnabled() {
+		volMnts = append(volMnts,
+			corev1.VolumeMount{Name: Krb5KeytabMountName, MountPath: paths.Krb5KeytabDir},
+			corev1.VolumeMount{Name: Krb5ConfMountName, MountPath: paths.Krb5Conf, SubPath: Krb5ConfKey},
+		)
+	}
+
+	if vdb.IsTLSEnabled() {
+		volMnts = append(volMnts, corev1.VolumeMount{
+			Name:      ServerCertMountName,
+			MountPath: paths.ServerCertDir,
+			ReadOnly:  true,
+		})
+	}
+
+	if vdb.Spec.Communal.CaFile != "" {
+		volMnts = append(volMnts, corev1.VolumeMount{
+			Name:      CommunalCAMountName,
+			MountPath: paths.CommunalCADir,
+			ReadOnly:  true,
+		})
+	}
+
+	if vdb.Spec.Communal.HadoopConfig != "" {
+		volMnts = append(volMnts, corev1.VolumeMount{
+			Name:      HadoopConfMountName,
+			MountPath: paths.HadoopConfPath,
+			ReadOnly:  true,
+		})
+	}
+
 	return volMnts
 }
 
@@ -102,9 +175,90 @@ func buildVolumes(vdb *vapi.VerticaDB) []corev1.Volume {
 	if vdb.Spec.LicenseSecret != "" {
 		vols = append(vols, buildLicenseVolume(vdb))
 	}
+	if vdb.IsKerberosEnabled() {
+		vols = append(vols, buildKrb5KeytabVolume(vdb), buildKrb5ConfVolume(vdb))
+	}
+	if vdb.IsTLSEnabled() {
+		vols = append(vols, buildServerCertVolume(vdb))
+	}
+	if vdb.Spec.Communal.CaFile != "" {
+		vols = append(vols, buildCommunalCAVolume(vdb))
+	}
+	if vdb.Spec.Communal.HadoopConfig != "" {
+		vols = append(vols, buildHadoopConfVolume(vdb))
+	}
+	// The user's volumes come last so that the operator's volumes keep the
+	// same position when they are added
+	vols = append(vols, vdb.Spec.Volumes...)
 	return vols
 }
 
+// buildCommunalCAVolume returns a volume that contains the CA bundle for the
+// communal endpoint
+func buildCommunalCAVolume(vdb *vapi.VerticaDB) corev1.Volume {
+	return corev1.Volume{
+		Name: CommunalCAMountName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: vdb.Spec.Communal.CaFile,
+				Items:      []corev1.KeyToPath{{Key: CommunalCAKey, Path: CommunalCAKey}},
+			},
+		},
+	}
+}
+
+// buildHadoopConfVolume returns a volume that contains the Hadoop
+// configuration files
+func buildHadoopConfVolume(vdb *vapi.VerticaDB) corev1.Volume {
+	return corev1.Volume{
+		Name: HadoopConfMountName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: vdb.Spec.Communal.HadoopConfig},
+			},
+		},
+	}
+}
+
+// buildServerCertVolume returns a volume that contains the server certificate
+// and its key
+func buildServerCertVolume(vdb *vapi.VerticaDB) corev1.Volume {
+	return corev1.Volume{
+		Name: ServerCertMountName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: vdb.Spec.TLS.ServerSecret,
+			},
+		},
+	}
+}
+
+// buildKrb5KeytabVolume returns a volume that contains the keytab file
+func buildKrb5KeytabVolume(vdb *vapi.VerticaDB) corev1.Volume {
+	return corev1.Volume{
+		Name: Krb5KeytabMountName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: vdb.Spec.Kerberos.KeytabSecret,
+				Items:      []corev1.KeyToPath{{Key: Krb5KeytabKey, Path: Krb5KeytabKey}},
+			},
+		},
+	}
+}
+
+// buildKrb5ConfVolume returns a volume that contains the krb5.conf file
+func buildKrb5ConfVolume(vdb *vapi.VerticaDB) corev1.Volume {
+	return corev1.Volume{
+		Name: Krb5ConfMountName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: vdb.Spec.Kerberos.Krb5ConfigMap},
+				Items:                []corev1.KeyToPath{{Key: Krb5ConfKey, Path: Krb5ConfKey}},
+			},
+		},
+	}
+}
+
 // buildLicenseVolume returns a volume that contains any licenses
 func buildLicenseVolume(vdb *vapi.VerticaDB) corev1.Volume {
 	return corev1.Volume{
@@ -179,43 +333,65 @@ func buildPodInfoVolume() corev1.Volume {
 // buildPodSpec creates a PodSpec for the statefulset
 func buildPodSpec(vdb *vapi.VerticaDB, sc *vapi.Subcluster) corev1.PodSpec {
 	termGracePeriod := int64(0)
+	// The server container must stay first.  See ServerContainerIndex.
+	cnts := []corev1.Container{buildServerContainer(vdb, sc)}
+	cnts = append(cnts, buildSidecarContainers(vdb)...)
 	return corev1.PodSpec{
-		NodeSelector: sc.NodeSelector,
-		Affinity:     sc.Affinity,
-		Tolerations:  sc.Tolerations,
-		Containers: []corev1.Container{
-			{
-				Image:           vdb.Spec.Image,
-				ImagePullPolicy: vdb.Spec.ImagePullPolicy,
-				Name:            ServerContainer,
-				Resources:       sc.Resources,
-				Ports: []corev1.ContainerPort{
-					{ContainerPort: 5433, Name: "vertica"},
-					{ContainerPort: 5434, Name: "vertica-int"},
-					{ContainerPort: 22, Name: "ssh"},
-				},
-				ReadinessProbe: &corev1.Probe{
-					Handler: corev1.Handler{
-						Exec: &corev1.ExecAction{
-							Command: []string{"bash", "-c",
-								fmt.Sprintf("vertica --status -D %s/%s/v_*_catalog",
-									vdb.Spec.Local.DataPath, vdb.Spec.DBName)},
-						},
-					},
-				},
-				Env: []corev1.EnvVar{
-					{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{
-						FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
-					}},
+		NodeSelector:                  sc.NodeSelector,
+		Affinity:                      sc.Affinity,
+		Tolerations:                   sc.Tolerations,
+		Containers:                    cnts,
+		Volumes:                       buildVolumes(vdb),
+		TerminationGracePeriodSeconds: &termGracePeriod,
+		ServiceAccountName:            vdb.Spec.ServiceAccountName,
+	}
+}
+
+// buildServerContainer creates the container that runs the vertica server
+func buildServerContainer(vdb *vapi.VerticaDB, sc *vapi.Subcluster) corev1.Container {
+	env := []corev1.EnvVar{
+		{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+		}},
+	}
+	return corev1.Container{
+		Image:           vdb.Spec.Image,
+		ImagePullPolicy: vdb.Spec.ImagePullPolicy,
+		Name:            ServerContainer,
+		Resources:       sc.Resources,
+		Ports: []corev1.ContainerPort{
+			{ContainerPort: 5433, Name: "vertica"},
+			{ContainerPort: 5434, Name: "vertica-int"},
+			{ContainerPort: 22, Name: "ssh"},
+		},
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{
+					Command: []string{"bash", "-c",
+						fmt.Sprintf("vertica --status -D %s/%s/v_*_catalog",
+							vdb.Spec.Local.DataPath, vdb.Spec.DBName)},
 				},
-				VolumeMounts: buildVolumeMounts(vdb),
 			},
 		},
-		Volumes:                       buildVolumes(vdb),
-		TerminationGracePeriodSeconds: &termGracePeriod,
+		Env:          append(env, vdb.Spec.Env...),
+		EnvFrom:      vdb.Spec.EnvFrom,
+		VolumeMounts: append(buildVolumeMounts(vdb), vdb.Spec.VolumeMounts...),
 	}
 }
 
+// buildSidecarContainers creates the sidecar containers from the spec.  Each
+// gets the volume mounts of the server container so that it can get at the
+// vertica logs.
+func buildSidecarContainers(vdb *vapi.VerticaDB) []corev1.Container {
+	cnts := []corev1.Container{}
+	for i := range vdb.Spec.Sidecars {
+		c := vdb.Spec.Sidecars[i].DeepCopy()
+		c.VolumeMounts = append(c.VolumeMounts, buildVolumeMounts(vdb)...)
+		cnts = append(cnts, *c)
+	}
+	return cnts
+}
+
 // getStorageClassName returns a  pointer to the StorageClass
 func getStorageClassName(vdb *vapi.VerticaDB) *string {
 	if vdb.Spec.Local.StorageClass == "" {
@@ -224,8 +400,53 @@ func getStorageClassName(vdb *vapi.VerticaDB) *string {
 	return &vdb.Spec.Local.StorageClass
 }
 
+// getDepotStorageClassName returns a pointer to the StorageClass for the depot
+// PVC.  nil means use the default storage class.
+func getDepotStorageClassName(vdb *vapi.VerticaDB) *string {
+	if vdb.Spec.Local.DepotStorageClass == "" {
+		return nil
+	}
+	return &vdb.Spec.Local.DepotStorageClass
+}
+
+// buildVolumeClaimTemplates returns the PVCs that the statefulset creates for
+// each pod.  There is always a PVC for the local data.  The depot gets a second
+// PVC only if a size was given for it.
+func buildVolumeClaimTemplates(vdb *vapi.VerticaDB) []corev1.PersistentVolumeClaim {
+	pvcs := []corev1.PersistentVolumeClaim{
+		buildPVCTemplate(LocalDataPVC, getStorageClassName(vdb), vdb.Spec.Local.RequestSize),
+	}
+	if vdb.HasSeparateDepotVolume() {
+		pvcs = append(pvcs, buildPVCTemplate(LocalDepotPVC, getDepotStorageClassName(vdb), vdb.Spec.Local.DepotRequestSize))
+	}
+	return pvcs
+}
+
+// buildPVCTemplate builds a single volumeClaimTemplate for the statefulset
+func buildPVCTemplate(name string, storageClass *string, size resource.Quantity) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: storageClass,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					"storage": size,
+				},
+			},
+		},
+	}
+}
+
 // buildStsSpec builds manifest for a subclusters statefulset
 func buildStsSpec(nm types.NamespacedName, vdb *vapi.VerticaDB, sc *vapi.Subcluster) *appsv1.StatefulSet {
+	// A subcluster that is shutdown keeps its pods' PVCs, but has no pods.
+	replicas := sc.Size
+	if sc.Shutdown {
+		replicas = 0
+	}
 	return &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        nm.Name,
@@ -238,7 +459,7 @@ func buildStsSpec(nm types.NamespacedName, vdb *vapi.VerticaDB, sc *vapi.Subclus
 				MatchLabels: makeSvcSelectorLabels(vdb, sc),
 			},
 			ServiceName: names.GenHlSvcName(vdb).Name,
-			Replicas:    &sc.Size,
+			Replicas:    &replicas,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels:      makeLabelsForObject(vdb, sc),
@@ -246,23 +467,84 @@ func buildStsSpec(nm types.NamespacedName, vdb *vapi.VerticaDB, sc *vapi.Subclus
 				},
 				Spec: buildPodSpec(vdb, sc),
 			},
-			PodManagementPolicy: appsv1.ParallelPodManagement,
-			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: LocalDataPVC,
-					},
-					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-						StorageClassName: getStorageClassName(vdb),
-						Resources: corev1.ResourceRequirements{
-							Requests: corev1.ResourceList{
-								"storage": vdb.Spec.Local.RequestSize,
-							},
-						},
-					},
+			PodManagementPolicy:  appsv1.ParallelPodManagement,
+			UpdateStrategy:       buildUpdateStrategy(vdb),
+			VolumeClaimTemplates: buildVolumeClaimTemplates(vdb),
+		},
+	}
+}
+
+// buildUpdateStrategy returns the update strategy for the statefulsets.  While
+// the image is being changed, we control when each pod picks up the new image
+// (see the OnlineUpgradeReconciler), so the OnDelete strategy is used.  At any
+// other time, the statefulset controller rolls out changes to the pods.
+func buildUpdateStrategy(vdb *vapi.VerticaDB) appsv1.StatefulSetUpdateStrategy {
+	if inProgress, err := vdb.IsConditionSet(vapi.ImageChangeInProgress); err == nil && inProgress {
+		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	}
+	partition := int32(0)
+	return appsv1.StatefulSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+	}
+}
+
+// buildPDB creates the desired spec for the pod disruption budget of a
+// secondary subcluster.  It can lose up to half of its pods.  The primary
+// subclusters share a single budget, which is built by buildPrimaryPDB.
+func buildPDB(nm types.NamespacedName, vdb *vapi.VerticaDB, sc *vapi.Subcluster) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromString("50%")
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nm.Name,
+			Namespace:   nm.Namespace,
+			Labels:      makeLabelsForObject(vdb, sc),
+			Annotations: makeAnnotationsForObject(vdb),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: makeSvcSelectorLabels(vdb, sc),
+			},
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+// buildPrimaryPDB creates the desired spec for the pod disruption budget that
+// covers the pods of every primary subcluster.  With k-safety 1, the database
+// keeps quorum if one primary node is down, so only one pod across all of the
+// primaries can be evicted at a time.  With k-safety 0, any down primary node
+// takes the database down.  A budget of 0 would block draining the
+// Kubernetes nodes forever, so no budget is built and nil is returned.
+func buildPrimaryPDB(nm types.NamespacedName, vdb *vapi.VerticaDB) *policyv1beta1.PodDisruptionBudget {
+	if vdb.Spec.KSafety != vapi.KSafety1 {
+		return nil
+	}
+	primaries := []string{}
+	for i := range vdb.Spec.Subclusters {
+		if vdb.Spec.Subclusters[i].IsPrimary {
+			primaries = append(primaries, vdb.Spec.Subclusters[i].Name)
+		}
+	}
+	if len(primaries) == 0 {
+		return nil
+	}
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nm.Name,
+			Namespace:   nm.Namespace,
+			Labels:      makeLabelsForObject(vdb, nil),
+			Annotations: makeAnnotationsForObject(vdb),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: makeSvcSelectorLabels(vdb, nil),
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: SubclusterLabel, Operator: metav1.LabelSelectorOpIn, Values: primaries},
 				},
 			},
+			MaxUnavailable: &maxUnavailable,
 		},
 	}
 }
@@ -282,14 +564,16 @@ func buildPod(vdb *vapi.VerticaDB, sc *vapi.Subcluster, podIndex int32) *corev1.
 	// Set a few things in the spec that are normally done by the statefulset
 	// controller. Again, this is for testing purposes only as the statefulset
 	// controller handles adding of the PVC to the volume list.
-	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-		Name: LocalDataPVC,
-		VolumeSource: corev1.VolumeSource{
-			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-				ClaimName: LocalDataPVC + "-" + vdb.ObjectMeta.Name + "-" + sc.Name + fmt.Sprintf("%d", podIndex),
+	for _, pvc := range buildVolumeClaimTemplates(vdb) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: pvc.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvc.Name + "-" + vdb.ObjectMeta.Name + "-" + sc.Name + fmt.Sprintf("%d", podIndex),
+				},
 			},
-		},
-	})
+		})
+	}
 	pod.Spec.Hostname = nm.Name
 	pod.Spec.Subdomain = names.GenHlSvcName(vdb).Name
 	return pod
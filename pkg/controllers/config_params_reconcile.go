@@ -0,0 +1,383 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ConfigParamsReconciler will set the configuration parameters from the spec
+// in the database.  Database level parameters are set with ALTER DATABASE and
+// subcluster level ones with ALTER NODE for each node in the subcluster.  The
+// current values are checked each time, so any drift is fixed.  What was
+// applied is saved in the status so that we know what to clear when a
+// parameter is removed from the spec.
+type ConfigParamsReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeConfigParamsReconciler will build a ConfigParamsReconciler object
+func MakeConfigParamsReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &ConfigParamsReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will apply the configuration parameters that don't match what is
+// in the database
+func (c *ConfigParamsReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if !c.hasParmsToReconcile() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := c.PFacts.Collect(ctx, c.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Nothing to do until the database has been initialized
+	if !c.PFacts.doesDBExist().IsTrue() {
+		return ctrl.Result{}, nil
+	}
+	atPod, ok := c.PFacts.findPodToRunVsql()
+	if !ok {
+		c.Log.Info("No up pod found to set the configuration parameters. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if err := c.reconcileDatabaseParms(ctx, atPod); err != nil {
+		return ctrl.Result{}, err
+	}
+	appliedSc, allApplied, err := c.reconcileNodeParms(ctx, atPod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := c.updateStatus(ctx, appliedSc); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !allApplied {
+		c.Log.Info("Some nodes are down and don't have their configuration parameters set. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// hasParmsToReconcile returns true if there are parameters in the spec, or
+// parameters that were applied before and may need to be cleared
+func (c *ConfigParamsReconciler) hasParmsToReconcile() bool {
+	if len(c.Vdb.Spec.ConfigParameters) > 0 || len(c.Vdb.Status.ConfigParameters) > 0 ||
+		len(c.Vdb.Status.SubclusterConfigParameters) > 0 {
+		return true
+	}
+	for i := range c.Vdb.Spec.Subclusters {
+		if len(c.Vdb.Spec.Subclusters[i].ConfigParameters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileDatabaseParms will set the database level parameters that differ
+// from the spec and clear the ones that were removed from the spec
+func (c *ConfigParamsReconciler) reconcileDatabaseParms(ctx context.Context, atPod *PodFact) error {
+	expParms := c.Vdb.Spec.ConfigParameters
+	sqls := []string{}
+	if len(expParms) > 0 {
+		cmd := []string{
+			"-tAc", fmt.Sprintf("show database default %s", strings.Join(sortedKeys(expParms), ", ")),
+		}
+		stdout, err := c.execVSQL(ctx, atPod, cmd...)
+		if err != nil {
+			return err
+		}
+		curParms := parseParmOutput(stdout)
+		if setList := genSetList(expParms, curParms); len(setList) > 0 {
+			sqls = append(sqls, fmt.Sprintf("alter database default set %s;", strings.Join(setList, ", ")))
+		}
+	}
+	if clearList := genClearList(expParms, c.Vdb.Status.ConfigParameters); len(clearList) > 0 {
+		sqls = append(sqls, fmt.Sprintf("alter database default clear %s;", strings.Join(clearList, ", ")))
+	}
+	return c.applySQL(ctx, atPod, sqls)
+}
+
+// reconcileNodeParms will set the node level parameters for each subcluster.
+// It returns the names of the subclusters that had their parameters applied
+// to all of their nodes, and false if any subcluster was skipped because it
+// had nodes that were down.
+func (c *ConfigParamsReconciler) reconcileNodeParms(ctx context.Context, atPod *PodFact) (map[string]bool, bool, error) {
+	appliedSc := map[string]bool{}
+	allApplied := true
+	// The nodes to check grouped by subcluster
+	scNodes := map[string][]string{}
+	parmNames := map[string]string{}
+	for i := range c.Vdb.Spec.Subclusters {
+		sc := &c.Vdb.Spec.Subclusters[i]
+		if len(sc.ConfigParameters) == 0 && len(c.Vdb.Status.SubclusterConfigParameters[sc.Name]) == 0 {
+			continue
+		}
+		// A subcluster that is shutdown keeps whatever was last applied
+		if sc.Shutdown {
+			continue
+		}
+		nodes, ok := c.getUpNodesInSubcluster(sc)
+		if !ok {
+			allApplied = false
+			continue
+		}
+		scNodes[sc.Name] = nodes
+		for name, val := range sc.ConfigParameters {
+			parmNames[name] = val
+		}
+	}
+	if len(scNodes) == 0 {
+		return appliedSc, allApplied, nil
+	}
+
+	curParms := map[string]map[string]string{}
+	if len(parmNames) > 0 {
+		var err error
+		curParms, err = c.getNodeParms(ctx, atPod, scNodes, sortedKeys(parmNames))
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	sqls := []string{}
+	for i := range c.Vdb.Spec.Subclusters {
+		sc := &c.Vdb.Spec.Subclusters[i]
+		nodes, ok := scNodes[sc.Name]
+		if !ok {
+			continue
+		}
+		clearList := genClearList(sc.ConfigParameters, c.Vdb.Status.SubclusterConfigParameters[sc.Name])
+		for _, node := range nodes {
+			if setList := genSetList(sc.ConfigParameters, curParms[node]); len(setList) > 0 {
+				sqls = append(sqls, fmt.Sprintf("alter node %s set %s;", node, strings.Join(setList, ", ")))
+			}
+			if len(clearList) > 0 {
+				sqls = append(sqls, fmt.Sprintf("alter node %s clear %s;", node, strings.Join(clearList, ", ")))
+			}
+		}
+		appliedSc[sc.Name] = true
+	}
+	return appliedSc, allApplied, c.applySQL(ctx, atPod, sqls)
+}
+
+// getUpNodesInSubcluster returns the vertica node names of the pods in the
+// given subcluster.  False is returned if any of the pods aren't up.
+func (c *ConfigParamsReconciler) getUpNodesInSubcluster(sc *vapi.Subcluster) ([]string, bool) {
+	nodes := []string{}
+	for _, pf := range c.PFacts.Detail {
+		if pf.subcluster != sc.Name {
+			continue
+		}
+		if !pf.upNode || pf.vnodeName == "" {
+			return nil, false
+		}
+		nodes = append(nodes, pf.vnodeName)
+	}
+	// Pods that don't exist yet can't be set.  A scale out is in progress if
+	// we have fewer nodes than the size of the subcluster.
+	return nodes, int32(len(nodes)) >= sc.Size
+}
+
+// getNodeParms returns the current value of the given parameters for each of
+// the nodes.  The outer map is keyed by node name.  A parameter that isn't set
+// at the node level is omitted.
+func (c *ConfigParamsReconciler) getNodeParms(ctx context.Context, atPod *PodFact,
+	scNodes map[string][]string, parmNames []string) (map[string]map[string]string, error) {
+	nodeList := []string{}
+	for _, nodes := range scNodes {
+		for _, node := range nodes {
+			nodeList = append(nodeList, fmt.Sprintf("'%s'", node))
+		}
+	}
+	nameList := []string{}
+	for _, name := range parmNames {
+		nameList = append(nameList, fmt.Sprintf("'%s'", strings.ToLower(name)))
+	}
+	cmd := []string{
+		"-tAc", fmt.Sprintf("select node_name, parameter_name, current_value from configuration_parameters "+
+			"where node_name in (%s) and lower(parameter_name) in (%s)",
+			strings.Join(nodeList, ", "), strings.Join(nameList, ", ")),
+	}
+	stdout, err := c.execVSQL(ctx, atPod, cmd...)
+	if err != nil {
+		return nil, err
+	}
+	// Each line of the output has the node, parameter name and value,
+	// separated by a '|'.
+	curParms := map[string]map[string]string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		const NumCols = 3
+		cols := strings.SplitN(strings.TrimSpace(line), "|", NumCols)
+		if len(cols) != NumCols {
+			continue
+		}
+		if _, ok := curParms[cols[0]]; !ok {
+			curParms[cols[0]] = map[string]string{}
+		}
+		curParms[cols[0]][strings.ToLower(cols[1])] = cols[2]
+	}
+	return curParms, nil
+}
+
+// applySQL will run the given SQL statements in a single vsql call
+func (c *ConfigParamsReconciler) applySQL(ctx context.Context, atPod *PodFact, sqls []string) error {
+	if len(sqls) == 0 {
+		return nil
+	}
+	if _, err := c.execVSQL(ctx, atPod, "-v", "ON_ERROR_STOP=1", "-c", strings.Join(sqls, " ")); err != nil {
+		return err
+	}
+	c.VRec.EVRec.Event(c.Vdb, corev1.EventTypeNormal, events.ConfigParametersApplied,
+		"Applied the configuration parameters from the spec")
+	return nil
+}
+
+// execVSQL will run vsql with the given args.  An event is written if it
+// fails, as the likely cause is a bad parameter name or value in the spec.
+func (c *ConfigParamsReconciler) execVSQL(ctx context.Context, atPod *PodFact, args ...string) (string, error) {
+	stdout, stderr, err := c.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, args...)
+	if err != nil {
+		c.VRec.EVRec.Eventf(c.Vdb, corev1.EventTypeWarning, events.ConfigParametersFailed,
+			"Failed to apply the configuration parameters: %s", strings.TrimSpace(stderr))
+		return "", err
+	}
+	return stdout, nil
+}
+
+// updateStatus will record the parameters that were applied.  Subclusters
+// that weren't applied keep what was last recorded for them.
+func (c *ConfigParamsReconciler) updateStatus(ctx context.Context, appliedSc map[string]bool) error {
+	return status.Update(ctx, c.VRec.Client, c.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.ConfigParameters = copyParms(c.Vdb.Spec.ConfigParameters)
+		scParms := map[string]map[string]string{}
+		for i := range c.Vdb.Spec.Subclusters {
+			sc := &c.Vdb.Spec.Subclusters[i]
+			parms := vdb.Status.SubclusterConfigParameters[sc.Name]
+			if appliedSc[sc.Name] {
+				parms = copyParms(sc.ConfigParameters)
+			}
+			if len(parms) > 0 {
+				scParms[sc.Name] = parms
+			}
+		}
+		if len(scParms) == 0 {
+			scParms = nil
+		}
+		vdb.Status.SubclusterConfigParameters = scParms
+		return nil
+	})
+}
+
+// parseParmOutput parses the output of SHOW DATABASE.  Each line has the name
+// and value of one parameter, separated by a '|'.  The returned map is keyed
+// by the lowercase parameter name as parameter names are case insensitive.
+func parseParmOutput(stdout string) map[string]string {
+	curParms := map[string]string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		cols := strings.SplitN(strings.TrimSpace(line), "|", 2)
+		if len(cols) != 2 {
+			continue
+		}
+		curParms[strings.ToLower(cols[0])] = cols[1]
+	}
+	return curParms
+}
+
+// The size suffixes that Vertica accepts in parameter values, such as 4G or
+// 512MB.  They are powers of 1024.
+var parmSizeRegexp = regexp.MustCompile(`(?i)^([0-9]+(?:\.[0-9]+)?)\s*([KMGTP]?)B?$`)
+
+// normalizeParmValue returns the value in a form that can be compared with
+// the value Vertica shows for a parameter.  Vertica shows booleans as 0 or 1,
+// may show a size with a different unit than it was set with, and isn't case
+// sensitive for most values.
+func normalizeParmValue(val string) string {
+	val = strings.TrimSpace(val)
+	switch strings.ToLower(val) {
+	case "true", "t", "yes", "y", "on":
+		return "1"
+	case "false", "f", "no", "n", "off":
+		return "0"
+	}
+	if m := parmSizeRegexp.FindStringSubmatch(val); m != nil {
+		num, err := strconv.ParseFloat(m[1], 64)
+		if err == nil {
+			exp := 0.0
+			if m[2] != "" {
+				exp = float64(strings.Index("KMGTP", strings.ToUpper(m[2])) + 1)
+			}
+			return strconv.FormatFloat(num*math.Pow(1024, exp), 'f', -1, 64)
+		}
+	}
+	return strings.ToLower(val)
+}
+
+// genSetList returns the assignments for the parameters whose current value
+// doesn't match what we expect.  curParms must be keyed by lowercase name.
+func genSetList(expParms, curParms map[string]string) []string {
+	setList := []string{}
+	for _, name := range sortedKeys(expParms) {
+		cur, ok := curParms[strings.ToLower(name)]
+		if !ok || normalizeParmValue(cur) != normalizeParmValue(expParms[name]) {
+			setList = append(setList, fmt.Sprintf("%s = '%s'", name, strings.ReplaceAll(expParms[name], "'", "''")))
+		}
+	}
+	return setList
+}
+
+// genClearList returns the names of the parameters that were applied before
+// but are no longer expected
+func genClearList(expParms, appliedParms map[string]string) []string {
+	clearList := []string{}
+	for _, name := range sortedKeys(appliedParms) {
+		if _, ok := expParms[name]; !ok {
+			clearList = append(clearList, name)
+		}
+	}
+	return clearList
+}
+
+// copyParms returns a copy of the given parameters.  Nil is returned if there
+// are none so that the status omits them.
+func copyParms(parms map[string]string) map[string]string {
+	if len(parms) == 0 {
+		return nil
+	}
+	cp := make(map[string]string, len(parms))
+	for k, v := range parms {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,167 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("config_params_reconcile", func() {
+	ctx := context.Background()
+
+	// setVsqlOutput will have the first vsql call in every pod return the given output
+	setVsqlOutput := func(vdb *vapi.VerticaDB, fpr *cmds.FakePodRunner, stdout string) {
+		fpr.Results = cmds.CmdResults{}
+		for i := int32(0); i < vdb.Spec.Subclusters[0].Size; i++ {
+			fpr.Results[names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i)] = []cmds.CmdResult{
+				{Stdout: stdout},
+			}
+		}
+	}
+
+	It("should not run any vsql if there are no parameters", func() {
+		vdb := vapi.MakeVDB()
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeConfigParamsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(0))
+	})
+
+	It("should set the database parameters that differ and record them in the status", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.ConfigParameters = map[string]string{
+			"MaxClientSessions":  "100",
+			"EnableSSL":          "0",
+			"DefaultIdleSession": "1 hour",
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		setVsqlOutput(vdb, fpr, "DefaultIdleSession|\nEnableSSL|0\nmaxclientsessions|50\n")
+		r := MakeConfigParamsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		h := fpr.FindCommands("alter database default set")
+		Expect(len(h)).Should(Equal(1))
+		Expect(h[0].Command).Should(ContainElement(
+			"alter database default set DefaultIdleSession = '1 hour', MaxClientSessions = '100';"))
+		Expect(vdb.Status.ConfigParameters).Should(Equal(vdb.Spec.ConfigParameters))
+	})
+
+	It("should not change a parameter whose value only differs in form", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.ConfigParameters = map[string]string{
+			"EnableSSL":         "true",
+			"MaxMemorySize":     "1G",
+			"MaxClientSessions": "100",
+			"DataSSLParams":     "On",
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		setVsqlOutput(vdb, fpr, "DataSSLParams|1\nEnableSSL|1\nMaxClientSessions|100\nMaxMemorySize|1024M\n")
+		evrec := record.NewFakeRecorder(10)
+		r := MakeConfigParamsReconciler(&VerticaDBReconciler{Client: k8sClient, EVRec: evrec}, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("alter database default set"))).Should(Equal(0))
+		Expect(evrec.Events).Should(BeEmpty())
+	})
+
+	It("should normalize parameter values before comparing them", func() {
+		Expect(normalizeParmValue("TRUE")).Should(Equal(normalizeParmValue("1")))
+		Expect(normalizeParmValue("off")).Should(Equal(normalizeParmValue("0")))
+		Expect(normalizeParmValue("2G")).Should(Equal(normalizeParmValue("2048MB")))
+		Expect(normalizeParmValue(" 1.0 ")).Should(Equal(normalizeParmValue("1")))
+		Expect(normalizeParmValue("ABC")).Should(Equal(normalizeParmValue("abc")))
+		Expect(normalizeParmValue("1G")).ShouldNot(Equal(normalizeParmValue("1M")))
+		Expect(normalizeParmValue("1 hour")).ShouldNot(Equal(normalizeParmValue("2 hours")))
+	})
+
+	It("should clear a parameter that was removed from the spec", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.ConfigParameters = map[string]string{"MaxClientSessions": "100"}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		vdb.Status.ConfigParameters = map[string]string{"MaxClientSessions": "100", "EnableSSL": "1"}
+		Expect(k8sClient.Status().Update(ctx, vdb)).Should(Succeed())
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		setVsqlOutput(vdb, fpr, "MaxClientSessions|100\n")
+		r := MakeConfigParamsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("alter database default set"))).Should(Equal(0))
+		Expect(len(fpr.FindCommands("alter database default clear EnableSSL;"))).Should(Equal(1))
+		Expect(vdb.Status.ConfigParameters).Should(Equal(map[string]string{"MaxClientSessions": "100"}))
+	})
+
+	It("should set the subcluster parameters for each node", func() {
+		vdb := vapi.MakeVDB()
+		sc := &vdb.Spec.Subclusters[0]
+		sc.ConfigParameters = map[string]string{"MaxClientSessions": "20"}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		for i := int32(0); i < sc.Size; i++ {
+			pfacts.Detail[names.GenPodName(vdb, sc, i)].vnodeName = fmt.Sprintf("v_%s_node%04d", vdb.Spec.DBName, i+1)
+		}
+		// Only the first node has the parameter set already
+		pf := pfacts.Detail[names.GenPodName(vdb, sc, 0)]
+		setVsqlOutput(vdb, fpr, pf.vnodeName+"|MaxClientSessions|20\n")
+		r := MakeConfigParamsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		h := fpr.FindCommands("alter node")
+		Expect(len(h)).Should(Equal(1))
+		for i := int32(1); i < sc.Size; i++ {
+			pf := pfacts.Detail[names.GenPodName(vdb, sc, i)]
+			Expect(h[0].Command[len(h[0].Command)-1]).Should(
+				ContainSubstring("alter node " + pf.vnodeName + " set MaxClientSessions = '20';"))
+		}
+		Expect(h[0].Command[len(h[0].Command)-1]).ShouldNot(ContainSubstring("alter node " + pf.vnodeName + " "))
+		Expect(vdb.Status.SubclusterConfigParameters).Should(Equal(
+			map[string]map[string]string{sc.Name: {"MaxClientSessions": "20"}}))
+	})
+})
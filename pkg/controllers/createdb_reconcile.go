@@ -85,13 +85,19 @@ func (c *CreateDBReconciler) execCmd(ctx context.Context, atPod types.Namespaced
 	if err != nil {
 		switch {
 		case isEndpointBadError(stdout):
-			c.VRec.EVRec.Eventf(c.Vdb, corev1.EventTypeWarning, events.S3EndpointIssue,
-				"Unable to write to the bucket in the S3 endpoint '%s'", c.Vdb.Spec.Communal.Endpoint)
+			c.VRec.EVRec.Eventf(c.Vdb, corev1.EventTypeWarning, getEndpointIssueReason(c.Vdb),
+				"Unable to write to the bucket in the communal endpoint '%s'", c.Vdb.Spec.Communal.Endpoint)
 			return ctrl.Result{Requeue: true}, nil
 
 		case isBucketNotExistError(stdout):
-			c.VRec.EVRec.Eventf(c.Vdb, corev1.EventTypeWarning, events.S3BucketDoesNotExist,
-				"The bucket in the S3 path '%s' does not exist", paths.GetCommunalPath(c.Vdb))
+			c.VRec.EVRec.Eventf(c.Vdb, corev1.EventTypeWarning, getBucketNotExistReason(c.Vdb),
+				"The bucket in the communal path '%s' does not exist", paths.GetCommunalPath(c.Vdb))
+			return ctrl.Result{Requeue: true}, nil
+
+		case isGCSAuthError(stdout):
+			c.VRec.EVRec.Eventf(c.Vdb, corev1.EventTypeWarning, events.GCSAuthFailed,
+				"The HMAC key in the communal credential secret '%s' was rejected by Google Cloud Storage",
+				c.Vdb.Spec.Communal.CredentialSecret)
 			return ctrl.Result{Requeue: true}, nil
 
 		case isCommunalPathNotEmpty(stdout):
@@ -119,12 +125,15 @@ func isCommunalPathNotEmpty(op string) bool {
 // This file runs any custom SQL for the create_db.
 func (c *CreateDBReconciler) preCmdSetup(ctx context.Context, atPod types.NamespacedName) error {
 	// We include SQL to reset the AWS connection parms we temporarily set in the
-	// auth file (see constructAuthParms).  We also rename the default
+	// auth file (see getAdditionalAuthParms).  We also rename the default
 	// subcluster to match the name of the first subcluster in the spec -- any
 	// remaining subclusters will be added by DBAddSubclusterReconciler.
-	sql := "alter database default clear AWSConnectTimeout;\n" +
-		"alter database default clear AWSMaxRetryCount;\n" +
-		"alter subcluster default_subcluster rename to " + c.Vdb.Spec.Subclusters[0].Name + ";\n"
+	sql := ""
+	if c.Vdb.IsS3() {
+		sql += "alter database default clear AWSConnectTimeout;\n" +
+			"alter database default clear AWSMaxRetryCount;\n"
+	}
+	sql += "alter subcluster default_subcluster rename to " + c.Vdb.Spec.Subclusters[0].Name + ";\n"
 	if c.Vdb.Spec.KSafety == vapi.KSafety0 {
 		sql += "select set_preferred_ksafe(0);\n"
 	}
@@ -139,6 +148,9 @@ func (c *CreateDBReconciler) getAdditionalAuthParms() string {
 	// We temporarily lower the connect time and retry count for AWS. This is
 	// done so that we fail fast if the S3 endpoint isn't setup. These are
 	// cleared at the end of the create_db.
+	if !c.Vdb.IsS3() {
+		return ""
+	}
 	const TempAWSConnectTime = "20"
 	const TempMaxRetryCount = "3"
 
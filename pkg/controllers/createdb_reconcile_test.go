@@ -50,6 +50,10 @@ var _ = Describe("createdb_reconciler", func() {
 	It("should run create db if db doesn't exist", func() {
 		vdb := vapi.MakeVDB()
 		vdb.Spec.Subclusters[0].Size = 3
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: "create", When: vapi.InitScriptPostCreate},
+			{ConfigMap: "revive", When: vapi.InitScriptPostRevive},
+		}
 		createVdb(ctx, vdb)
 		defer deleteVdb(ctx, vdb)
 		createPods(ctx, vdb, AllPodsRunning)
@@ -65,6 +69,9 @@ var _ = Describe("createdb_reconciler", func() {
 		Expect(len(hist)).Should(Equal(1))
 		hist = fpr.FindCommands("rm", paths.AuthParmsFile)
 		Expect(len(hist)).Should(Equal(1))
+		Expect(vdb.Status.PendingInitScripts).Should(Equal([]vapi.InitScriptStatus{
+			{ConfigMap: "create", When: vapi.InitScriptPostCreate},
+		}))
 	})
 
 	It("host list for create db should only include pods from first subcluster", func() {
@@ -108,6 +115,17 @@ var _ = Describe("createdb_reconciler", func() {
 		Expect(len(fpr.Histories)).Should(Equal(0))
 	})
 
+	It("should not set the temporary AWS parms for a Google Cloud Storage communal path", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.Path = "gs://bucket/db"
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		act := MakeCreateDBReconciler(vrec, logger, vdb, fpr, &pfacts)
+		r := act.(*CreateDBReconciler)
+		Expect(r.getAdditionalAuthParms()).Should(Equal(""))
+	})
+
 	It("should generate a requeue error for various known s3 errors", func() {
 		vdb := vapi.MakeVDB()
 
@@ -121,6 +139,7 @@ var _ = Describe("createdb_reconciler", func() {
 			"Unable to connect to endpoint",
 			"The specified bucket does not exist.",
 			"Communal location [s3://blah] is not empty",
+			"Check your Google secret key",
 		}
 
 		for i := range errStrings {
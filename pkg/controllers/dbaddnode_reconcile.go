@@ -130,8 +130,14 @@ func (d *DBAddNodeReconciler) runAddNodeForPod(ctx context.Context, pod, atPod *
 	if err != nil {
 		switch {
 		case isLicenseLimitError(stdout):
-			d.VRec.EVRec.Event(d.Vdb, corev1.EventTypeWarning, events.AddNodeLicenseFail,
-				"You cannot add more nodes to the database.  You have reached the limit allowed by your license.")
+			if lic := d.Vdb.Status.License; lic != nil && lic.NodeLimit > 0 {
+				d.VRec.EVRec.Eventf(d.Vdb, corev1.EventTypeWarning, events.AddNodeLicenseFail,
+					"You cannot add more nodes to the database.  You have reached the limit of %d nodes allowed by your license.",
+					lic.NodeLimit)
+			} else {
+				d.VRec.EVRec.Event(d.Vdb, corev1.EventTypeWarning, events.AddNodeLicenseFail,
+					"You cannot add more nodes to the database.  You have reached the limit allowed by your license.")
+			}
 		default:
 			d.VRec.EVRec.Eventf(d.Vdb, corev1.EventTypeWarning, events.AddNodeFailed,
 				"Failed when calling 'admintools -t db_add_node' from pod %s", pod.name.Name)
@@ -39,6 +39,7 @@ type DBRemoveNodeReconciler struct {
 	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
 	PRunner cmds.PodRunner
 	PFacts  *PodFacts
+	Drainer *NodeDrainer
 }
 
 // MakeDBRemoveNodeReconciler will build and return the DBRemoveNodeReconciler object.
@@ -50,6 +51,7 @@ func MakeDBRemoveNodeReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
 		Vdb:     vdb,
 		PRunner: prunner,
 		PFacts:  pfacts,
+		Drainer: MakeNodeDrainer(vdbrecon, log, vdb, prunner, pfacts),
 	}
 }
 
@@ -87,7 +89,8 @@ func (d *DBRemoveNodeReconciler) Reconcile(ctx context.Context, req *ctrl.Reques
 		}
 	}
 
-	return ctrl.Result{}, nil
+	// Any nodes that were drained have now been removed
+	return ctrl.Result{}, d.Drainer.Clear(ctx)
 }
 
 // reconcileSubcluster Will handle reconcile for a single subcluster
@@ -104,6 +107,12 @@ func (d *DBRemoveNodeReconciler) removeNodesInSubcluster(ctx context.Context, sc
 	startPodIndex, endPodIndex int32) (ctrl.Result, error) {
 	podsToRemove, requeueNeeded := d.findPodsSuitableForScaleDown(sc, startPodIndex, endPodIndex)
 	if len(podsToRemove) > 0 {
+		// Drain the client connections before removing the nodes, so that
+		// in-flight sessions are not killed.
+		if res, err := d.Drainer.Drain(ctx, podsToRemove); err != nil || res.Requeue {
+			return res, err
+		}
+
 		cmd := d.genCmdRemoveNode(podsToRemove)
 		atPod, ok := d.PFacts.findPodToRunAdmintools()
 		if !ok {
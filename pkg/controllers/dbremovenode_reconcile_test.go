@@ -44,6 +44,8 @@ var _ = Describe("dbremovenode_reconcile", func() {
 		vdb := vapi.MakeVDB()
 		sc := &vdb.Spec.Subclusters[0]
 		sc.Size = 2
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
 		createPods(ctx, vdb, AllPodsRunning)
 		defer deletePods(ctx, vdb)
 
@@ -69,6 +71,8 @@ var _ = Describe("dbremovenode_reconcile", func() {
 		sc := &vdb.Spec.Subclusters[0]
 		sc.Size = 3
 		vdbCopy := vdb.DeepCopy() // Take a copy so that we cleanup with the original size
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdbCopy)
 		createPods(ctx, vdb, AllPodsRunning)
 		defer deletePods(ctx, vdbCopy)
 		sc.Size = 1 // mimic a pending db_remove_node
@@ -81,9 +85,11 @@ var _ = Describe("dbremovenode_reconcile", func() {
 		res, err := r.Reconcile(ctx, &ctrl.Request{})
 		Expect(err).Should(Succeed())
 		Expect(res.Requeue).Should(BeFalse())
-		Expect(len(fpr.Histories)).Should(BeNumerically(">", 0))
-		lastCall := fpr.Histories[len(fpr.Histories)-1]
-		Expect(lastCall.Command).Should(ContainElements(
+		// The pod facts are collected again when the drain is cleared, so we
+		// look for the command rather than taking the last call.
+		hist := fpr.FindCommands("db_remove_node")
+		Expect(len(hist)).Should(Equal(1))
+		Expect(hist[0].Command).Should(ContainElements(
 			"/opt/vertica/bin/admintools",
 			"db_remove_node",
 			"--hosts="+pfacts.Detail[uninstallPods[0]].dnsName+","+pfacts.Detail[uninstallPods[1]].dnsName,
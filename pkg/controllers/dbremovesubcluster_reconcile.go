@@ -18,6 +18,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -36,12 +37,20 @@ type DBRemoveSubclusterReconciler struct {
 	PRunner cmds.PodRunner
 	PFacts  *PodFacts
 	ATPod   *PodFact // The pod that we run admintools from
+	Drainer *NodeDrainer
 }
 
 // MakeDBRemoveSubclusterReconciler will build a DBRemoveSubclusterReconciler object
 func MakeDBRemoveSubclusterReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
 	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
-	return &DBRemoveSubclusterReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+	return &DBRemoveSubclusterReconciler{
+		VRec:    vdbrecon,
+		Log:     log,
+		Vdb:     vdb,
+		PRunner: prunner,
+		PFacts:  pfacts,
+		Drainer: MakeNodeDrainer(vdbrecon, log, vdb, prunner, pfacts),
+	}
 }
 
 // Reconcile will remove any subcluster that no longer exists in the vdb.
@@ -63,17 +72,25 @@ func (d *DBRemoveSubclusterReconciler) removeExtraSubclusters(ctx context.Contex
 		return ctrl.Result{}, err
 	}
 
-	if len(subclusters) > 0 {
-		atPod, ok := d.PFacts.findPodToRunAdmintools()
-		if !ok || !atPod.upNode {
-			d.Log.Info("No pod found to run admintools from. Requeue reconciliation.")
-			return ctrl.Result{Requeue: true}, nil
-		}
-		d.ATPod = atPod
+	if len(subclusters) == 0 {
+		return ctrl.Result{}, nil
+	}
 
-		if err := d.resetDefaultSubcluster(ctx); err != nil {
-			return ctrl.Result{}, err
-		}
+	atPod, ok := d.PFacts.findPodToRunAdmintools()
+	if !ok || !atPod.upNode {
+		d.Log.Info("No pod found to run admintools from. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	d.ATPod = atPod
+
+	// Drain the client connections from all of the subclusters before
+	// removing any of them, so that in-flight sessions are not killed.
+	if res, err := d.Drainer.Drain(ctx, d.findPodsInSubclusters(subclusters)); err != nil || res.Requeue {
+		return res, err
+	}
+
+	if err := d.resetDefaultSubcluster(ctx); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	for i := range subclusters {
@@ -81,7 +98,26 @@ func (d *DBRemoveSubclusterReconciler) removeExtraSubclusters(ctx context.Contex
 			return ctrl.Result{}, err
 		}
 	}
-	return ctrl.Result{}, nil
+
+	// The nodes were removed, so refresh the pod facts before the drain
+	// progress is cleared.
+	d.PFacts.Invalidate()
+	return ctrl.Result{}, d.Drainer.Clear(ctx)
+}
+
+// findPodsInSubclusters returns the pods of the given subclusters
+func (d *DBRemoveSubclusterReconciler) findPodsInSubclusters(subclusters []*vapi.Subcluster) []*PodFact {
+	pods := []*PodFact{}
+	for _, pf := range d.PFacts.Detail {
+		for i := range subclusters {
+			if pf.subcluster == subclusters[i].Name {
+				pods = append(pods, pf)
+			}
+		}
+	}
+	// Sort them so that the drain status doesn't change between reconciles
+	sort.Slice(pods, func(i, j int) bool { return pods[i].name.Name < pods[j].name.Name })
+	return pods
 }
 
 // removeSubcluster will call admintools to remove the given subcluster from vertica
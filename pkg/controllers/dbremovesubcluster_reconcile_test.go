@@ -23,6 +23,7 @@ import (
 	. "github.com/onsi/gomega"
 	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
 	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -54,6 +55,8 @@ var _ = Describe("dbremovedsubcluster_reconcile", func() {
 		// the finder to discover this additional subcluster.
 		lookupVdb := vapi.MakeVDB()
 		lookupVdb.Spec.Subclusters[0] = vapi.Subcluster{Name: scNames[0], Size: scSizes[0]}
+		createVdb(ctx, lookupVdb)
+		defer deleteVdb(ctx, lookupVdb)
 
 		fpr := &cmds.FakePodRunner{}
 		pfacts := MakePodFacts(k8sClient, fpr)
@@ -65,5 +68,39 @@ var _ = Describe("dbremovedsubcluster_reconcile", func() {
 		Expect(len(cmds)).Should(Equal(1))
 		cmds = fpr.FindCommands(fmt.Sprintf("alter subcluster %s set default", scNames[0]))
 		Expect(len(cmds)).Should(Equal(1))
+		// The drain is cleared after the subcluster is removed
+		Expect(lookupVdb.Status.Drain).Should(BeNil())
+	})
+
+	It("should not call db_remove_subcluster while sessions are still active", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters = []vapi.Subcluster{
+			{Name: "sc1", Size: 1},
+			{Name: "sc2", Size: 1},
+		}
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		lookupVdb := vapi.MakeVDB()
+		lookupVdb.Spec.Subclusters[0] = vapi.Subcluster{Name: "sc1", Size: 1}
+		lookupVdb.Spec.DrainTimeoutSeconds = 300
+		createVdb(ctx, lookupVdb)
+		defer deleteVdb(ctx, lookupVdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, lookupVdb)).Should(Succeed())
+		removePod := names.GenPodName(vdb, &vdb.Spec.Subclusters[1], 0)
+		for _, pf := range pfacts.Detail {
+			pf.upNode = pf.name == removePod
+		}
+		pfacts.Detail[removePod].vnodeName = "v_db_node0002"
+		fpr.Results = cmds.CmdResults{
+			removePod: []cmds.CmdResult{{}, {}, {Stdout: "2\n"}},
+		}
+		r := MakeDBRemoveSubclusterReconciler(vrec, logger, lookupVdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true, RequeueAfter: DrainPollInterval}))
+		Expect(len(fpr.FindCommands("admintools -t db_remove_subcluster"))).Should(Equal(0))
+		Expect(lookupVdb.Status.Drain.Pods).Should(Equal([]string{removePod.Name}))
 	})
 })
@@ -0,0 +1,269 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The amount of time to wait before checking again for active sessions on
+// nodes that are being drained
+const DrainPollInterval = time.Second * 5
+
+// NodeDrainer will drain the client connections from pods before their nodes
+// are removed from the database.  The pods are taken out of the external
+// service and Vertica is told to stop giving connections to their nodes, then
+// we wait for the sessions that are still connected to end.
+type NodeDrainer struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeNodeDrainer will build a NodeDrainer object
+func MakeNodeDrainer(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) *NodeDrainer {
+	return &NodeDrainer{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Drain will drain the client connections from the given pods.  The result
+// asks for a requeue while sessions are still connected and the drain timeout
+// hasn't passed.  The progress is kept in the vdb status.
+func (n *NodeDrainer) Drain(ctx context.Context, pods []*PodFact) (ctrl.Result, error) {
+	if err := n.removeClientRouting(ctx, pods); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	podNames := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		podNames = append(podNames, pod.name.Name)
+	}
+	if n.Vdb.Status.Drain == nil || !reflect.DeepEqual(n.Vdb.Status.Drain.Pods, podNames) {
+		n.VRec.EVRec.Eventf(n.Vdb, corev1.EventTypeNormal, events.DrainStart,
+			"Draining client connections from pods '%s'", genPodNames(pods))
+		if err := n.setNodesExcluded(ctx, pods, true); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := n.updateDrainStatus(ctx, &vapi.DrainStatus{Pods: podNames, StartTime: metav1.Now()}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// A timeout of zero means we don't wait for the sessions to end
+	if n.Vdb.Spec.DrainTimeoutSeconds == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	sessions, res, err := n.countActiveSessions(ctx, pods)
+	if err != nil || res.Requeue {
+		return res, err
+	}
+	if sessions == 0 {
+		n.VRec.EVRec.Eventf(n.Vdb, corev1.EventTypeNormal, events.DrainSucceeded,
+			"All client connections have been drained from pods '%s'", genPodNames(pods))
+		return ctrl.Result{}, nil
+	}
+
+	timeout := time.Second * time.Duration(n.Vdb.Spec.DrainTimeoutSeconds)
+	if time.Since(n.Vdb.Status.Drain.StartTime.Time) >= timeout {
+		n.VRec.EVRec.Eventf(n.Vdb, corev1.EventTypeWarning, events.DrainTimedOut,
+			"Timed out draining pods '%s'. There are still %d sessions connected.", genPodNames(pods), sessions)
+		return ctrl.Result{}, nil
+	}
+
+	drain := n.Vdb.Status.Drain.DeepCopy()
+	drain.ActiveSessions = sessions
+	if err := n.updateDrainStatus(ctx, drain); err != nil {
+		return ctrl.Result{}, err
+	}
+	n.Log.Info("Waiting for client sessions to end before removing nodes", "sessions", sessions)
+	return ctrl.Result{Requeue: true, RequeueAfter: DrainPollInterval}, nil
+}
+
+// Clear will remove the drain progress from the status.  This is called once
+// the drained nodes have been removed.  If any of the drained nodes are still
+// up, because the scale down was reverted, Vertica is allowed to give them
+// connections again.
+func (n *NodeDrainer) Clear(ctx context.Context) error {
+	if n.Vdb.Status.Drain == nil {
+		return nil
+	}
+	if err := n.PFacts.Collect(ctx, n.Vdb); err != nil {
+		return err
+	}
+	pods := []*PodFact{}
+	for _, pf := range n.PFacts.Detail {
+		for _, podName := range n.Vdb.Status.Drain.Pods {
+			if pf.name.Name == podName {
+				pods = append(pods, pf)
+			}
+		}
+	}
+	if err := n.setNodesExcluded(ctx, pods, false); err != nil {
+		return err
+	}
+	return n.updateDrainStatus(ctx, nil)
+}
+
+// setNodesExcluded will change whether Vertica gives new client connections
+// to the nodes of the given pods.  When excluded, MaxClientSessions is 0 on
+// the node, so that only the dbadmin can connect, and the network addresses
+// of the node are disabled, which takes it out of the load balance groups.
+// Nodes that are down are skipped.
+func (n *NodeDrainer) setNodesExcluded(ctx context.Context, pods []*PodFact, excluded bool) error {
+	nodeNames := genUpNodeNames(pods)
+	if len(nodeNames) == 0 {
+		return nil
+	}
+	atPod, ok := n.PFacts.findPodToRunVsql()
+	if !ok {
+		return nil
+	}
+
+	stmts := []string{}
+	for _, nodeName := range nodeNames {
+		if excluded {
+			stmts = append(stmts, fmt.Sprintf("alter node %s set MaxClientSessions = 0", nodeName))
+		} else {
+			stmts = append(stmts, fmt.Sprintf("alter node %s clear MaxClientSessions", nodeName))
+		}
+	}
+	addrs, err := n.getNetworkAddresses(ctx, atPod, nodeNames, !excluded)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if excluded {
+			stmts = append(stmts, fmt.Sprintf(`alter network address "%s" disable`, addr))
+		} else {
+			stmts = append(stmts, fmt.Sprintf(`alter network address "%s" enable`, addr))
+		}
+	}
+	_, _, err = n.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, "-c", strings.Join(stmts, "; "))
+	return err
+}
+
+// getNetworkAddresses returns the names of the network addresses of the given
+// nodes that are either disabled or enabled
+func (n *NodeDrainer) getNetworkAddresses(ctx context.Context, atPod *PodFact, nodeNames []string,
+	disabled bool) ([]string, error) {
+	cmd := []string{
+		"-tAc",
+		fmt.Sprintf("select name from network_addresses where node in ('%s') and is_enabled = %t",
+			strings.Join(nodeNames, "','"), !disabled),
+	}
+	stdout, _, err := n.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, cmd...)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if addr := strings.TrimSpace(line); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// genUpNodeNames returns the vnode names of the pods that have an up node
+func genUpNodeNames(pods []*PodFact) []string {
+	nodeNames := []string{}
+	for _, pod := range pods {
+		if pod.upNode && pod.vnodeName != "" {
+			nodeNames = append(nodeNames, pod.vnodeName)
+		}
+	}
+	return nodeNames
+}
+
+// removeClientRouting will remove the client routing label from each pod.
+// This takes them out of the external service for their subcluster.
+func (n *NodeDrainer) removeClientRouting(ctx context.Context, pods []*PodFact) error {
+	for _, pf := range pods {
+		pod := &corev1.Pod{}
+		if err := n.VRec.Client.Get(ctx, pf.name, pod); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if _, ok := pod.Labels[ClientRoutingLabel]; !ok {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		delete(pod.Labels, ClientRoutingLabel)
+		if err := n.VRec.Client.Patch(ctx, pod, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countActiveSessions returns the number of client sessions that are connected
+// to the nodes of the given pods
+func (n *NodeDrainer) countActiveSessions(ctx context.Context, pods []*PodFact) (int, ctrl.Result, error) {
+	nodeNames := genUpNodeNames(pods)
+	// Nodes that are down have no sessions
+	if len(nodeNames) == 0 {
+		return 0, ctrl.Result{}, nil
+	}
+
+	atPod, ok := n.PFacts.findPodToRunVsql()
+	if !ok {
+		n.Log.Info("No up pod found to count the sessions from. Requeue reconciliation.")
+		return 0, ctrl.Result{Requeue: true}, nil
+	}
+	cmd := []string{
+		"-tAc",
+		fmt.Sprintf("select count(*) from sessions where node_name in ('%s') and session_id <> current_session()",
+			strings.Join(nodeNames, "','")),
+	}
+	stdout, _, err := n.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, cmd...)
+	if err != nil {
+		return 0, ctrl.Result{}, err
+	}
+	sessions, err := strconv.Atoi(strings.TrimSpace(stdout))
+	if err != nil {
+		return 0, ctrl.Result{}, fmt.Errorf("failed to parse the session count '%s': %w", stdout, err)
+	}
+	return sessions, ctrl.Result{}, nil
+}
+
+// updateDrainStatus will set the drain status in the vdb
+func (n *NodeDrainer) updateDrainStatus(ctx context.Context, drain *vapi.DrainStatus) error {
+	return status.Update(ctx, n.VRec.Client, n.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.Drain = drain
+		return nil
+	})
+}
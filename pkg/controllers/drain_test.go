@@ -0,0 +1,186 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("drain", func() {
+	ctx := context.Background()
+
+	It("should take the pod out of the service without waiting if the timeout is 0", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		setClientRoutingLabel(ctx, podName)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		fpr.Histories = []cmds.CmdHistory{}
+		d := MakeNodeDrainer(vrec, logger, vdb, fpr, &pfacts)
+		Expect(d.Drain(ctx, []*PodFact{pfacts.Detail[podName]})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("from sessions"))).Should(Equal(0))
+
+		pod := &corev1.Pod{}
+		Expect(k8sClient.Get(ctx, podName, pod)).Should(Succeed())
+		Expect(pod.Labels).ShouldNot(HaveKey(ClientRoutingLabel))
+		Expect(vdb.Status.Drain).ShouldNot(BeNil())
+		Expect(vdb.Status.Drain.Pods).Should(Equal([]string{podName.Name}))
+
+		Expect(d.Clear(ctx)).Should(Succeed())
+		Expect(vdb.Status.Drain).Should(BeNil())
+	})
+
+	It("should requeue until the sessions have ended", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		vdb.Spec.DrainTimeoutSeconds = 300
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		pfacts.Detail[podName].vnodeName = "v_db_node0001"
+		fpr.Results = cmds.CmdResults{
+			// The first two are for excluding the node
+			podName: []cmds.CmdResult{{}, {}, {Stdout: "3\n"}, {Stdout: "0\n"}},
+		}
+		d := MakeNodeDrainer(vrec, logger, vdb, fpr, &pfacts)
+		pods := []*PodFact{pfacts.Detail[podName]}
+		Expect(d.Drain(ctx, pods)).Should(Equal(ctrl.Result{Requeue: true, RequeueAfter: DrainPollInterval}))
+		Expect(vdb.Status.Drain.ActiveSessions).Should(Equal(3))
+		hist := fpr.FindCommands("from sessions where node_name in ('v_db_node0001')")
+		Expect(len(hist)).Should(Equal(1))
+
+		Expect(d.Drain(ctx, pods)).Should(Equal(ctrl.Result{}))
+	})
+
+	It("should stop waiting once the timeout has passed", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		vdb.Spec.DrainTimeoutSeconds = 60
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		pfacts.Detail[podName].vnodeName = "v_db_node0001"
+		fpr.Results = cmds.CmdResults{
+			podName: []cmds.CmdResult{{Stdout: "3\n"}},
+		}
+		d := MakeNodeDrainer(vrec, logger, vdb, fpr, &pfacts)
+		Expect(d.updateDrainStatus(ctx, &vapi.DrainStatus{
+			Pods:      []string{podName.Name},
+			StartTime: metav1.NewTime(time.Now().Add(-time.Minute * 2)),
+		})).Should(Succeed())
+		Expect(d.Drain(ctx, []*PodFact{pfacts.Detail[podName]})).Should(Equal(ctrl.Result{}))
+	})
+
+	It("should exclude the nodes in Vertica and include them again if the drain is cleared", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].Size = 1
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		pfacts.Detail[podName].vnodeName = "v_db_node0001"
+		fpr.Results = cmds.CmdResults{
+			podName: []cmds.CmdResult{{Stdout: "addr1\n"}, {}, {Stdout: "addr1\n"}, {}},
+		}
+		d := MakeNodeDrainer(vrec, logger, vdb, fpr, &pfacts)
+		Expect(d.Drain(ctx, []*PodFact{pfacts.Detail[podName]})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("alter node v_db_node0001 set MaxClientSessions = 0"))).Should(Equal(1))
+		Expect(len(fpr.FindCommands(`alter network address "addr1" disable`))).Should(Equal(1))
+
+		// The node is still up, so it must have been kept in the database
+		Expect(d.Clear(ctx)).Should(Succeed())
+		Expect(len(fpr.FindCommands("alter node v_db_node0001 clear MaxClientSessions"))).Should(Equal(1))
+		Expect(len(fpr.FindCommands(`alter network address "addr1" enable`))).Should(Equal(1))
+		Expect(vdb.Status.Drain).Should(BeNil())
+	})
+
+	It("should not call db_remove_node while sessions are still active", func() {
+		vdb := vapi.MakeVDB()
+		sc := &vdb.Spec.Subclusters[0]
+		sc.Size = 2
+		vdb.Spec.DrainTimeoutSeconds = 300
+		vdbCopy := vdb.DeepCopy()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdbCopy)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdbCopy)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		removePod := names.GenPodName(vdb, sc, 1)
+		pfacts.Detail[removePod].vnodeName = "v_db_node0002"
+		for _, pf := range pfacts.Detail {
+			pf.upNode = pf.name == removePod
+		}
+		fpr.Results = cmds.CmdResults{
+			removePod: []cmds.CmdResult{{}, {}, {Stdout: "1\n"}},
+		}
+		actor := MakeDBRemoveNodeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		r := actor.(*DBRemoveNodeReconciler)
+		res, err := r.removeNodesInSubcluster(ctx, sc, 1, 1)
+		Expect(err).Should(Succeed())
+		Expect(res.Requeue).Should(BeTrue())
+		Expect(len(fpr.FindCommands("db_remove_node"))).Should(Equal(0))
+	})
+})
+
+// setClientRoutingLabel will add the client routing label to a pod
+func setClientRoutingLabel(ctx context.Context, podName client.ObjectKey) {
+	pod := &corev1.Pod{}
+	ExpectWithOffset(1, k8sClient.Get(ctx, podName, pod)).Should(Succeed())
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels[ClientRoutingLabel] = ClientRoutingVal
+	ExpectWithOffset(1, k8sClient.Patch(ctx, pod, patch)).Should(Succeed())
+}
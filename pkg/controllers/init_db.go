@@ -115,6 +115,10 @@ func (g *GenericDatabaseInitializer) runInit(ctx context.Context) (ctrl.Result,
 
 	debugDumpAdmintoolsConf(ctx, g.PRunner, atPod)
 
+	if err := g.setPendingInitScripts(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	cond := vapi.VerticaDBCondition{Type: vapi.DBInitialized, Status: corev1.ConditionTrue}
 	if err := status.UpdateCondition(ctx, g.VRec.Client, g.Vdb, cond); err != nil {
 		return ctrl.Result{}, err
@@ -134,6 +138,34 @@ func (g *GenericDatabaseInitializer) runInit(ctx context.Context) (ctrl.Result,
 	return ctrl.Result{}, nil
 }
 
+// setPendingInitScripts will record the init scripts to run now that the
+// database was initialized.  Only the scripts for the way it was initialized,
+// that are in the spec at this point, are run.
+func (g *GenericDatabaseInitializer) setPendingInitScripts(ctx context.Context) error {
+	var phase vapi.InitScriptPhase
+	switch g.Vdb.Spec.InitPolicy {
+	case vapi.CommunalInitPolicyCreate:
+		phase = vapi.InitScriptPostCreate
+	case vapi.CommunalInitPolicyRevive:
+		phase = vapi.InitScriptPostRevive
+	default:
+		return nil
+	}
+	pending := []vapi.InitScriptStatus{}
+	for i := range g.Vdb.Spec.InitScripts {
+		if g.Vdb.Spec.InitScripts[i].When == phase {
+			pending = append(pending, vapi.InitScriptStatus{ConfigMap: g.Vdb.Spec.InitScripts[i].ConfigMap, When: phase})
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return status.Update(ctx, g.VRec.Client, g.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.PendingInitScripts = pending
+		return nil
+	})
+}
+
 // getHostList will return a host list from the given pods
 func getHostList(podList []*PodFact) []string {
 	hostList := make([]string, 0, len(podList))
@@ -167,25 +199,97 @@ func (g *GenericDatabaseInitializer) cleanupLocalFilesInPods(ctx context.Context
 	return nil
 }
 
-// ConstructAuthParms builds the s3 authentication parms and ensure it exists in the pod
+// ConstructAuthParms builds the communal authentication parms and ensure it exists in the pod
 func (g *GenericDatabaseInitializer) ConstructAuthParms(ctx context.Context, atPod types.NamespacedName) (ctrl.Result, error) {
-	// Extract the auth from the credential secret.
-	auth, res, err := g.getS3Auth(ctx)
-	if err != nil || res.Requeue {
-		return res, err
+	// Extract the auth from the credential secret.  HDFS doesn't have one, and
+	// it is optional for s3 when the pods get access through an IAM role.
+	auth := ""
+	if !g.Vdb.IsHDFS() && g.Vdb.Spec.Communal.CredentialSecret != "" {
+		var res ctrl.Result
+		var err error
+		auth, res, err = g.getCommunalAuth(ctx)
+		if err != nil || res.Requeue {
+			return res, err
+		}
+	}
+	authParms, err := g.genAuthParms(auth)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
 	_, _, err = g.PRunner.ExecInPod(ctx, atPod, ServerContainer,
 		"bash", "-c", "cat > "+paths.AuthParmsFile+"<<< '"+
-			"awsauth = "+auth+"\n"+
-			"awsendpoint = "+g.getS3Endpoint()+"\n"+
-			"awsenablehttps = "+g.getEnableHTTPS()+"\n"+
+			authParms+
 			g.initializer.getAdditionalAuthParms()+
 			"'",
 	)
 	return ctrl.Result{}, err
 }
 
+// genAuthParms returns the auth parms that connect to the communal endpoint.
+// The names of the parms depend on the type of communal storage.
+func (g *GenericDatabaseInitializer) genAuthParms(auth string) (string, error) {
+	if g.Vdb.IsHDFS() {
+		return g.genHDFSParms(), nil
+	}
+	if g.Vdb.IsAzure() {
+		parms := "AzureStorageCredentials = " + auth + "\n"
+		// The endpoint config is only needed if we aren't using the default
+		// endpoint for the account.
+		if g.Vdb.Spec.Communal.Endpoint != "" {
+			protocol := "http"
+			if g.getEnableHTTPS() == "1" {
+				protocol = "https"
+			}
+			cfg, err := genAzureEndpointConfig(getAzureAccountName(g.Vdb), g.getCommunalEndpoint(), protocol)
+			if err != nil {
+				return "", err
+			}
+			parms += "AzureStorageEndpointConfig = " + cfg + "\n"
+		}
+		return parms, nil
+	}
+	if g.Vdb.IsGCloud() {
+		return "GCSAuth = " + auth + "\n" +
+			"GCSEndpoint = " + g.getCommunalEndpoint() + "\n" +
+			"GCSEnableHttps = " + g.getEnableHTTPS() + "\n", nil
+	}
+	parms := ""
+	// Without an auth, s3 will use the IAM role the pod has access to
+	if auth != "" {
+		parms += "awsauth = " + auth + "\n"
+	}
+	parms += "awsendpoint = " + g.getCommunalEndpoint() + "\n" +
+		"awsenablehttps = " + g.getEnableHTTPS() + "\n"
+	if g.Vdb.Spec.Communal.CaFile != "" {
+		parms += "AWSCAFile = " + paths.CommunalCAFile + "\n"
+	}
+	switch g.Vdb.Spec.Communal.S3ServerSideEncryption {
+	case vapi.SSES3:
+		parms += "AWSServerSideEncryption = AES256\n"
+	case vapi.SSEKMS:
+		parms += "AWSServerSideEncryption = aws:kms\n" +
+			"AWSSSEKMSKeyId = " + g.Vdb.Spec.Communal.S3SSEKMSKeyID + "\n"
+	}
+	return parms, nil
+}
+
+// genHDFSParms returns the parms needed to access HDFS.  There are no
+// credentials for HDFS, but if the Hadoop cluster uses Kerberos the database
+// must be created with the kerberos parms.
+func (g *GenericDatabaseInitializer) genHDFSParms() string {
+	parms := ""
+	if g.Vdb.Spec.Communal.HadoopConfig != "" {
+		parms += "HadoopConfDir = " + paths.HadoopConfPath + "\n"
+	}
+	if g.Vdb.IsKerberosEnabled() {
+		parms += "KerberosServiceName = " + g.Vdb.Spec.Kerberos.ServiceName + "\n" +
+			"KerberosRealm = " + g.Vdb.Spec.Kerberos.Realm + "\n" +
+			"KerberosKeytabFile = " + paths.Krb5Keytab + "\n"
+	}
+	return parms
+}
+
 // DestroyAuthParms will remove the auth parms file that was created in the pod
 func (g *GenericDatabaseInitializer) DestroyAuthParms(ctx context.Context, atPod types.NamespacedName) error {
 	_, _, err := g.PRunner.ExecInPod(ctx, atPod, ServerContainer,
@@ -194,9 +298,11 @@ func (g *GenericDatabaseInitializer) DestroyAuthParms(ctx context.Context, atPod
 	return err
 }
 
-// getS3Auth will return the access key and secret key.
-// Value is returned in the format: <accessKey>:<secretKey>
-func (g *GenericDatabaseInitializer) getS3Auth(ctx context.Context) (string, ctrl.Result, error) {
+// getCommunalAuth will return the auth for the communal endpoint from the
+// credential secret.  For s3 and Google Cloud Storage, the value is returned in
+// the format: <accessKey>:<secretKey>.  For Azure Blob Storage it is the value
+// of the AzureStorageCredentials parameter.
+func (g *GenericDatabaseInitializer) getCommunalAuth(ctx context.Context) (string, ctrl.Result, error) {
 	secret := &corev1.Secret{}
 	if err := g.VRec.Client.Get(ctx, names.GenCommunalCredSecretName(g.Vdb), secret); err != nil {
 		if errors.IsNotFound(err) {
@@ -207,6 +313,10 @@ func (g *GenericDatabaseInitializer) getS3Auth(ctx context.Context) (string, ctr
 		return "", ctrl.Result{}, fmt.Errorf("could not read the communal credential secret %s: %w", g.Vdb.Spec.Communal.CredentialSecret, err)
 	}
 
+	if g.Vdb.IsAzure() {
+		return g.getAzureAuth(secret)
+	}
+
 	accessKey, ok := secret.Data[S3AccessKeyName]
 	if !ok {
 		g.VRec.EVRec.Eventf(g.Vdb, corev1.EventTypeWarning, events.S3CredsWrongKey,
@@ -226,9 +336,31 @@ func (g *GenericDatabaseInitializer) getS3Auth(ctx context.Context) (string, ctr
 	return auth, ctrl.Result{}, nil
 }
 
-// getS3Endpoint get the s3 endpoint for inclusion in the auth files.
-// Takes the endpoint from vdb and strips off the protocol.
-func (g *GenericDatabaseInitializer) getS3Endpoint() string {
+// getAzureAuth will return the value for the AzureStorageCredentials parameter.
+// The credential secret must have either the account key or a shared access
+// signature.
+func (g *GenericDatabaseInitializer) getAzureAuth(secret *corev1.Secret) (string, ctrl.Result, error) {
+	accountKey, hasKey := secret.Data[AzureAccountKeyName]
+	sas, hasSAS := secret.Data[AzureSASName]
+	if !hasKey && !hasSAS {
+		g.VRec.EVRec.Eventf(g.Vdb, corev1.EventTypeWarning, events.S3CredsWrongKey,
+			"The communal credential secret '%s' does not have a key named '%s' or '%s'",
+			g.Vdb.Spec.Communal.CredentialSecret, AzureAccountKeyName, AzureSASName)
+		return "", ctrl.Result{Requeue: true}, nil
+	}
+
+	blobEndpoint := ""
+	if g.Vdb.Spec.Communal.Endpoint != "" {
+		blobEndpoint = g.getCommunalEndpoint()
+	}
+	auth, err := genAzureCredentials(getAzureAccountName(g.Vdb), blobEndpoint,
+		strings.TrimSuffix(string(accountKey), "\n"), strings.TrimSuffix(string(sas), "\n"))
+	return auth, ctrl.Result{}, err
+}
+
+// getCommunalEndpoint get the communal endpoint for inclusion in the auth
+// files.  Takes the endpoint from vdb and strips off the protocol.
+func (g *GenericDatabaseInitializer) getCommunalEndpoint() string {
 	prefix := []string{"https://", "http://"}
 	for _, pref := range prefix {
 		if i := strings.Index(g.Vdb.Spec.Communal.Endpoint, pref); i == 0 {
@@ -245,3 +377,21 @@ func (g *GenericDatabaseInitializer) getEnableHTTPS() string {
 	}
 	return "0"
 }
+
+// getEndpointIssueReason returns the event reason to use when we can't
+// connect to the communal endpoint
+func getEndpointIssueReason(vdb *vapi.VerticaDB) string {
+	if vdb.IsGCloud() {
+		return events.GCSEndpointIssue
+	}
+	return events.S3EndpointIssue
+}
+
+// getBucketNotExistReason returns the event reason to use when the bucket in
+// the communal path doesn't exist
+func getBucketNotExistReason(vdb *vapi.VerticaDB) string {
+	if vdb.IsGCloud() {
+		return events.GCSBucketDoesNotExist
+	}
+	return events.S3BucketDoesNotExist
+}
@@ -24,6 +24,10 @@ import (
 	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
 	"github.com/vertica/vertica-kubernetes/pkg/cmds"
 	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/paths"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 var _ = Describe("s3_auth", func() {
@@ -41,10 +45,10 @@ var _ = Describe("s3_auth", func() {
 			Vdb:     vdb,
 			PRunner: fpr,
 		}
-		Expect(g.getS3Auth(ctx)).Should(Equal(fmt.Sprintf("%s:%s", testAccessKey, testSecretKey)))
+		Expect(g.getCommunalAuth(ctx)).Should(Equal(fmt.Sprintf("%s:%s", testAccessKey, testSecretKey)))
 	})
 
-	It("should return s3 endpoint stripped of https/http", func() {
+	It("should return communal endpoint stripped of https/http", func() {
 		vdb := vapi.MakeVDB()
 		vdb.Spec.Communal.Endpoint = "https://192.168.0.1"
 
@@ -56,15 +60,148 @@ var _ = Describe("s3_auth", func() {
 			PRunner: fpr,
 		}
 
-		Expect(g.getS3Endpoint()).Should(Equal("192.168.0.1"))
+		Expect(g.getCommunalEndpoint()).Should(Equal("192.168.0.1"))
 		Expect(g.getEnableHTTPS()).Should(Equal("1"))
 
 		vdb.Spec.Communal.Endpoint = "http://fqdn.example.com:8080"
 
-		Expect(g.getS3Endpoint()).Should(Equal("fqdn.example.com:8080"))
+		Expect(g.getCommunalEndpoint()).Should(Equal("fqdn.example.com:8080"))
 		Expect(g.getEnableHTTPS()).Should(Equal("0"))
 	})
 
+	It("should generate the GCS auth parms for a Google Cloud Storage communal path", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.Path = "gs://bucket/db"
+		vdb.Spec.Communal.Endpoint = "https://storage.googleapis.com"
+
+		fpr := &cmds.FakePodRunner{}
+		g := GenericDatabaseInitializer{
+			VRec:    vrec,
+			Log:     logger,
+			Vdb:     vdb,
+			PRunner: fpr,
+		}
+		parms, err := g.genAuthParms("access:secret")
+		Expect(err).Should(Succeed())
+		Expect(parms).Should(ContainSubstring("GCSAuth = access:secret\n"))
+		Expect(parms).Should(ContainSubstring("GCSEndpoint = storage.googleapis.com\n"))
+		Expect(parms).Should(ContainSubstring("GCSEnableHttps = 1\n"))
+		Expect(parms).ShouldNot(ContainSubstring("awsauth"))
+	})
+
+	It("should generate the Azure auth parms from an account key", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.Path = "azb://account/container/db"
+		vdb.Spec.Communal.Endpoint = "https://account.blob.core.windows.net"
+		nm := names.GenCommunalCredSecretName(vdb)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: nm.Name, Namespace: nm.Namespace},
+			Data: map[string][]byte{
+				AzureAccountKeyName: []byte("azkey"),
+			},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+		defer deleteCommunalCredSecret(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		g := GenericDatabaseInitializer{
+			VRec:    vrec,
+			Log:     logger,
+			Vdb:     vdb,
+			PRunner: fpr,
+		}
+		auth, res, err := g.getCommunalAuth(ctx)
+		Expect(err).Should(Succeed())
+		Expect(res).Should(Equal(ctrl.Result{}))
+		Expect(auth).Should(Equal(`[{"accountName":"account","blobEndpoint":"account.blob.core.windows.net","accountKey":"azkey"}]`))
+		parms, err := g.genAuthParms(auth)
+		Expect(err).Should(Succeed())
+		Expect(parms).Should(ContainSubstring("AzureStorageCredentials = " + auth + "\n"))
+		Expect(parms).Should(ContainSubstring(
+			`AzureStorageEndpointConfig = [{"accountName":"account","blobEndpoint":"account.blob.core.windows.net","protocol":"https"}]`))
+
+		// No endpoint config is needed when the default endpoint is used
+		vdb.Spec.Communal.Endpoint = ""
+		parms, err = g.genAuthParms(auth)
+		Expect(err).Should(Succeed())
+		Expect(parms).ShouldNot(ContainSubstring("AzureStorageEndpointConfig"))
+	})
+
+	It("should requeue if the Azure credential secret has neither a key nor a sas", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.Path = "azb://account/container/db"
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		g := GenericDatabaseInitializer{
+			VRec:    vrec,
+			Log:     logger,
+			Vdb:     vdb,
+			PRunner: fpr,
+		}
+		_, res, err := g.getCommunalAuth(ctx)
+		Expect(err).Should(Succeed())
+		Expect(res).Should(Equal(ctrl.Result{Requeue: true}))
+	})
+
+	It("should omit awsauth without a credential secret and include the sse parms", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.CredentialSecret = ""
+		vdb.Spec.Communal.S3ServerSideEncryption = vapi.SSEKMS
+		vdb.Spec.Communal.S3SSEKMSKeyID = "kms-key"
+
+		fpr := &cmds.FakePodRunner{}
+		g := GenericDatabaseInitializer{
+			VRec:    vrec,
+			Log:     logger,
+			Vdb:     vdb,
+			PRunner: fpr,
+		}
+		parms, err := g.genAuthParms("")
+		Expect(err).Should(Succeed())
+		Expect(parms).ShouldNot(ContainSubstring("awsauth"))
+		Expect(parms).Should(ContainSubstring("awsendpoint = "))
+		Expect(parms).Should(ContainSubstring("AWSServerSideEncryption = aws:kms\n"))
+		Expect(parms).Should(ContainSubstring("AWSSSEKMSKeyId = kms-key\n"))
+		Expect(parms).ShouldNot(ContainSubstring("AWSCAFile"))
+
+		vdb.Spec.Communal.CaFile = "s3-ca"
+		parms, err = g.genAuthParms("")
+		Expect(err).Should(Succeed())
+		Expect(parms).Should(ContainSubstring("AWSCAFile = " + paths.CommunalCAFile + "\n"))
+	})
+
+	It("should generate the HDFS parms without reading a credential secret", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.Path = "webhdfs://namenode:50070/db"
+		vdb.Spec.Communal.CredentialSecret = ""
+		vdb.Spec.Communal.HadoopConfig = "hadoop-conf"
+		vdb.Spec.Kerberos = vapi.KerberosSpec{
+			KeytabSecret:  "keytab",
+			Krb5ConfigMap: "krb5-conf",
+			Realm:         "EXAMPLE.COM",
+			ServiceName:   "vertica",
+		}
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		act := MakeCreateDBReconciler(vrec, logger, vdb, fpr, &pfacts)
+		g := GenericDatabaseInitializer{
+			initializer: act.(*CreateDBReconciler),
+			VRec:        vrec,
+			Log:         logger,
+			Vdb:         vdb,
+			PRunner:     fpr,
+			PFacts:      &pfacts,
+		}
+		atPod := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		Expect(g.ConstructAuthParms(ctx, atPod)).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("HadoopConfDir = " + paths.HadoopConfPath))).Should(Equal(1))
+		Expect(len(fpr.FindCommands("KerberosRealm = EXAMPLE.COM"))).Should(Equal(1))
+		Expect(len(fpr.FindCommands("awsauth"))).Should(Equal(0))
+	})
+
 	It("should fail to get host list if some pods not running", func() {
 		vdb := vapi.MakeVDB()
 		const ScIndex = 0
@@ -0,0 +1,307 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// The file in the pod that we copy an init script to before running it
+	InitScriptSQLFile = "/home/dbadmin/init-script.sql"
+	// The most base64 encoded SQL that we put in a single command.  This
+	// keeps each command well under the limit that Linux has for the size of
+	// a single argument.  It must be a multiple of 4 so that each chunk can
+	// be decoded on its own.
+	InitScriptChunkSize = 64 * 1024
+)
+
+// InitScriptsReconciler will run the SQL scripts from spec.initScripts.  Each
+// script is run exactly once for its phase, which is recorded in the status.
+// The PostCreate and PostRevive scripts are only run if they were pending when
+// the database was initialized.  The PostSubclusterAdd scripts are only run for
+// subclusters that didn't exist when the script was first seen.
+type InitScriptsReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeInitScriptsReconciler will build an InitScriptsReconciler object
+func MakeInitScriptsReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &InitScriptsReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will run any init script that hasn't been run yet
+func (i *InitScriptsReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if len(i.Vdb.Spec.InitScripts) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if err := i.PFacts.Collect(ctx, i.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Nothing to do until the database has been initialized
+	if !i.PFacts.doesDBExist().IsTrue() {
+		return ctrl.Result{}, nil
+	}
+
+	for j := range i.Vdb.Spec.InitScripts {
+		script := &i.Vdb.Spec.InitScripts[j]
+		switch script.When {
+		case vapi.InitScriptPostCreate, vapi.InitScriptPostRevive:
+			if !i.isScriptPending(script) {
+				continue
+			}
+			if res, err := i.runScriptOnce(ctx, script, ""); err != nil || res.Requeue {
+				return res, err
+			}
+		case vapi.InitScriptPostSubclusterAdd:
+			if res, err := i.runSubclusterScript(ctx, script); err != nil || res.Requeue {
+				return res, err
+			}
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// runSubclusterScript will run a PostSubclusterAdd script for each subcluster
+// that was added since the script was first seen.  The first time we see the
+// script, the current subclusters are recorded as skipped.
+func (i *InitScriptsReconciler) runSubclusterScript(ctx context.Context, script *vapi.InitScript) (ctrl.Result, error) {
+	if !i.isScriptSeen(script) {
+		return ctrl.Result{}, i.skipExistingSubclusters(ctx, script)
+	}
+	for k := range i.Vdb.Spec.Subclusters {
+		sc := &i.Vdb.Spec.Subclusters[k]
+		if sc.Shutdown || i.isScriptDone(script, sc.Name) {
+			continue
+		}
+		// The subcluster must be in the database with at least one
+		// node up before we can run its script.  We don't requeue for
+		// it, as that would hold up the rest of the reconcile.  The
+		// pods coming up will trigger another reconcile.
+		if !i.hasUpNode(sc.Name) {
+			i.Log.Info("Waiting for a node to be up before running the init script", "subcluster", sc.Name,
+				"configMap", script.ConfigMap)
+			continue
+		}
+		if res, err := i.runScriptOnce(ctx, script, sc.Name); err != nil || res.Requeue {
+			return res, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// isScriptSeen returns true if the status has any entry for the script
+func (i *InitScriptsReconciler) isScriptSeen(script *vapi.InitScript) bool {
+	for _, s := range i.Vdb.Status.InitScripts {
+		if s.ConfigMap == script.ConfigMap && s.When == script.When {
+			return true
+		}
+	}
+	return false
+}
+
+// skipExistingSubclusters will record the script as skipped for every
+// subcluster currently in the spec, so it is only run for ones added later
+func (i *InitScriptsReconciler) skipExistingSubclusters(ctx context.Context, script *vapi.InitScript) error {
+	i.Log.Info("Skipping the init script for the existing subclusters", "configMap", script.ConfigMap)
+	return status.Update(ctx, i.VRec.Client, i.Vdb, func(vdb *vapi.VerticaDB) error {
+		for k := range vdb.Spec.Subclusters {
+			scName := vdb.Spec.Subclusters[k].Name
+			if i.isScriptDone(script, scName) {
+				continue
+			}
+			vdb.Status.InitScripts = append(vdb.Status.InitScripts, vapi.InitScriptStatus{
+				ConfigMap:  script.ConfigMap,
+				When:       script.When,
+				Subcluster: scName,
+				Skipped:    true,
+			})
+		}
+		return nil
+	})
+}
+
+// isScriptPending returns true if the script was recorded as one to run when
+// the database was initialized
+func (i *InitScriptsReconciler) isScriptPending(script *vapi.InitScript) bool {
+	for _, s := range i.Vdb.Status.PendingInitScripts {
+		if s.ConfigMap == script.ConfigMap && s.When == script.When {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUpNode returns true if the subcluster has at least one node that is up
+func (i *InitScriptsReconciler) hasUpNode(scName string) bool {
+	for _, pf := range i.PFacts.Detail {
+		if pf.subcluster == scName && pf.upNode {
+			return true
+		}
+	}
+	return false
+}
+
+// isScriptDone returns true if the status shows the script was already run
+func (i *InitScriptsReconciler) isScriptDone(script *vapi.InitScript, scName string) bool {
+	for _, s := range i.Vdb.Status.InitScripts {
+		if s.ConfigMap == script.ConfigMap && s.When == script.When && s.Subcluster == scName {
+			return true
+		}
+	}
+	return false
+}
+
+// runScriptOnce will run the script if it wasn't run before.  scName is the
+// subcluster to run it for, which is only set for PostSubclusterAdd scripts.
+func (i *InitScriptsReconciler) runScriptOnce(ctx context.Context, script *vapi.InitScript, scName string) (ctrl.Result, error) {
+	if i.isScriptDone(script, scName) {
+		return ctrl.Result{}, nil
+	}
+
+	sql, res, err := i.getScript(ctx, script)
+	if err != nil || res.Requeue {
+		return res, err
+	}
+	atPod, ok := i.PFacts.findPodToRunVsql()
+	if !ok {
+		i.Log.Info("No up pod found to run the init script. Requeue reconciliation.", "configMap", script.ConfigMap)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if err := i.execScript(ctx, atPod.name, sql, scName); err != nil {
+		i.VRec.EVRec.Eventf(i.Vdb, corev1.EventTypeWarning, events.InitScriptFailed,
+			"Failed to run the %s init script from the config map '%s'", genScriptPhaseDesc(script, scName), script.ConfigMap)
+		return ctrl.Result{}, err
+	}
+	i.VRec.EVRec.Eventf(i.Vdb, corev1.EventTypeNormal, events.InitScriptSucceeded,
+		"Successfully ran the %s init script from the config map '%s'", genScriptPhaseDesc(script, scName), script.ConfigMap)
+
+	// Record it right away, so that a failure in a later script doesn't
+	// get this one run again.
+	return ctrl.Result{}, status.Update(ctx, i.VRec.Client, i.Vdb, func(vdb *vapi.VerticaDB) error {
+		if !i.isScriptDone(script, scName) {
+			vdb.Status.InitScripts = append(vdb.Status.InitScripts, vapi.InitScriptStatus{
+				ConfigMap:  script.ConfigMap,
+				When:       script.When,
+				Subcluster: scName,
+			})
+		}
+		pending := []vapi.InitScriptStatus{}
+		for _, s := range vdb.Status.PendingInitScripts {
+			if s.ConfigMap != script.ConfigMap || s.When != script.When {
+				pending = append(pending, s)
+			}
+		}
+		if len(pending) == 0 {
+			pending = nil
+		}
+		vdb.Status.PendingInitScripts = pending
+		return nil
+	})
+}
+
+// getScript returns the SQL from the script's config map.  The keys are
+// joined in alphabetical order.
+func (i *InitScriptsReconciler) getScript(ctx context.Context, script *vapi.InitScript) (string, ctrl.Result, error) {
+	cm := &corev1.ConfigMap{}
+	nm := types.NamespacedName{Namespace: i.Vdb.Namespace, Name: script.ConfigMap}
+	if err := i.VRec.Client.Get(ctx, nm, cm); err != nil {
+		if errors.IsNotFound(err) {
+			i.VRec.EVRec.Eventf(i.Vdb, corev1.EventTypeWarning, events.InitScriptConfigMapNotFound,
+				"Could not find the config map '%s' for an init script", script.ConfigMap)
+			return "", ctrl.Result{Requeue: true}, nil
+		}
+		return "", ctrl.Result{}, fmt.Errorf("could not read the init script config map %s: %w", nm.Name, err)
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(cm.Data[k])
+		if !strings.HasSuffix(cm.Data[k], "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), ctrl.Result{}, nil
+}
+
+// execScript will copy the SQL to a file in the pod and run it with vsql.  We
+// stop at the first error, so a failed script can be fixed and run again.
+func (i *InitScriptsReconciler) execScript(ctx context.Context, atPod types.NamespacedName, sql, scName string) error {
+	if err := i.copyScript(ctx, atPod, sql); err != nil {
+		return err
+	}
+	cmd := []string{"-v", "ON_ERROR_STOP=1"}
+	if scName != "" {
+		cmd = append(cmd, "-v", "subcluster="+scName)
+	}
+	cmd = append(cmd, "-f", InitScriptSQLFile)
+	_, _, err := i.PRunner.ExecVSQL(ctx, atPod, ServerContainer, cmd...)
+	return err
+}
+
+// copyScript will write the SQL to the script file in the pod.  The SQL is
+// base64 encoded, so nothing in it can be interpreted by bash.  A large script
+// is written in chunks.
+func (i *InitScriptsReconciler) copyScript(ctx context.Context, atPod types.NamespacedName, sql string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(sql))
+	redirect := ">"
+	for start := 0; start == 0 || start < len(encoded); start += InitScriptChunkSize {
+		end := start + InitScriptChunkSize
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		cmd := fmt.Sprintf("echo '%s' | base64 -d %s %s", encoded[start:end], redirect, InitScriptSQLFile)
+		if _, _, err := i.PRunner.ExecInPod(ctx, atPod, ServerContainer, "bash", "-c", cmd); err != nil {
+			return err
+		}
+		redirect = ">>"
+	}
+	return nil
+}
+
+// genScriptPhaseDesc returns a description of when the script runs for events
+func genScriptPhaseDesc(script *vapi.InitScript, scName string) string {
+	if scName == "" {
+		return string(script.When)
+	}
+	return fmt.Sprintf("%s (subcluster '%s')", script.When, scName)
+}
@@ -0,0 +1,239 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("init_scripts_reconcile", func() {
+	ctx := context.Background()
+	const ScriptConfigMapName = "bootstrap"
+
+	createScriptConfigMap := func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ScriptConfigMapName, Namespace: vapi.MakeVDBName().Namespace},
+			Data: map[string]string{
+				"02-users.sql":   "create user app;",
+				"01-schemas.sql": "create schema app;\n",
+			},
+		}
+		Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+	}
+	deleteScriptConfigMap := func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ScriptConfigMapName, Namespace: vapi.MakeVDBName().Namespace},
+		}
+		Expect(k8sClient.Delete(ctx, cm)).Should(Succeed())
+	}
+
+	It("should run a PostCreate script exactly once", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostCreate},
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostRevive},
+		}
+		vdb.Status.PendingInitScripts = []vapi.InitScriptStatus{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostCreate},
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createScriptConfigMap()
+		defer deleteScriptConfigMap()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		r := MakeInitScriptsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		encoded := base64.StdEncoding.EncodeToString([]byte("create schema app;\ncreate user app;\n"))
+		Expect(len(fpr.FindCommands(fmt.Sprintf("echo '%s' | base64 -d > %s", encoded, InitScriptSQLFile)))).Should(Equal(1))
+		Expect(len(fpr.FindCommands("-v ON_ERROR_STOP=1 -f " + InitScriptSQLFile))).Should(Equal(1))
+		Expect(vdb.Status.InitScripts).Should(Equal([]vapi.InitScriptStatus{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostCreate},
+		}))
+		Expect(vdb.Status.PendingInitScripts).Should(BeNil())
+
+		fpr.Histories = []cmds.CmdHistory{}
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(0))
+	})
+
+	It("should not run a PostCreate script added after the database was created", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostCreate},
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createScriptConfigMap()
+		defer deleteScriptConfigMap()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		r := MakeInitScriptsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(InitScriptSQLFile))).Should(Equal(0))
+		Expect(vdb.Status.InitScripts).Should(BeNil())
+	})
+
+	It("should copy a large script in chunks without passing it through bash", func() {
+		vdb := vapi.MakeVDB()
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		r := &InitScriptsReconciler{VRec: vrec, Log: logger, Vdb: vdb, PRunner: fpr, PFacts: &pfacts}
+		sql := "select 1;\nEND_OF_INIT_SCRIPT\n" + strings.Repeat("-", InitScriptChunkSize)
+		atPod := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		fpr.Histories = nil
+		Expect(r.copyScript(ctx, atPod, sql)).Should(Succeed())
+		Expect(len(fpr.Histories)).Should(Equal(2))
+		Expect(fpr.Histories[0].Command[2]).Should(HaveSuffix("| base64 -d > " + InitScriptSQLFile))
+		Expect(fpr.Histories[1].Command[2]).Should(HaveSuffix("| base64 -d >> " + InitScriptSQLFile))
+		Expect(fpr.Histories[0].Command[2]).ShouldNot(ContainSubstring("END_OF_INIT_SCRIPT"))
+	})
+
+	It("should skip the existing subclusters the first time a PostSubclusterAdd script is seen", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{Name: "sc2", Size: 1})
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd},
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createScriptConfigMap()
+		defer deleteScriptConfigMap()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		r := MakeInitScriptsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(InitScriptSQLFile))).Should(Equal(0))
+		Expect(vdb.Status.InitScripts).Should(Equal([]vapi.InitScriptStatus{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd, Subcluster: "defaultsubcluster", Skipped: true},
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd, Subcluster: "sc2", Skipped: true},
+		}))
+
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(InitScriptSQLFile))).Should(Equal(0))
+	})
+
+	It("should run a PostSubclusterAdd script for a subcluster added later", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{Name: "sc2", Size: 1})
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd},
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createScriptConfigMap()
+		defer deleteScriptConfigMap()
+		// The script was seen before sc2 was added
+		Expect(status.Update(ctx, k8sClient, vdb, func(vdb *vapi.VerticaDB) error {
+			vdb.Status.InitScripts = []vapi.InitScriptStatus{
+				{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd, Subcluster: "defaultsubcluster", Skipped: true},
+			}
+			return nil
+		})).Should(Succeed())
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		r := MakeInitScriptsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("-v subcluster=defaultsubcluster -f"))).Should(Equal(0))
+		Expect(len(fpr.FindCommands("-v subcluster=sc2 -f"))).Should(Equal(1))
+		Expect(len(vdb.Status.InitScripts)).Should(Equal(2))
+		Expect(vdb.Status.InitScripts[1]).Should(Equal(vapi.InitScriptStatus{
+			ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd, Subcluster: "sc2",
+		}))
+	})
+
+	It("should not requeue while waiting for a subcluster to have a node up", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd},
+		}
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createScriptConfigMap()
+		defer deleteScriptConfigMap()
+		// The script was seen before the subcluster was added
+		Expect(status.Update(ctx, k8sClient, vdb, func(vdb *vapi.VerticaDB) error {
+			vdb.Status.InitScripts = []vapi.InitScriptStatus{
+				{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostSubclusterAdd, Subcluster: "removed", Skipped: true},
+			}
+			return nil
+		})).Should(Succeed())
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		for _, pf := range pfacts.Detail {
+			pf.upNode = false
+		}
+		r := MakeInitScriptsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(InitScriptSQLFile))).Should(Equal(0))
+	})
+
+	It("should requeue if the config map doesn't exist", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.InitScripts = []vapi.InitScript{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostCreate},
+		}
+		vdb.Status.PendingInitScripts = []vapi.InitScriptStatus{
+			{ConfigMap: ScriptConfigMapName, When: vapi.InitScriptPostCreate},
+		}
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		r := MakeInitScriptsReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(len(fpr.FindCommands(InitScriptSQLFile))).Should(Equal(0))
+	})
+})
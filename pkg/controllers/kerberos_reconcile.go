@@ -0,0 +1,123 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/paths"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// KerberosReconciler will set the database parameters for Kerberos
+// authentication.  The parameters are stored in the catalog, so this must wait
+// until the database is up.  This handles a database that was created as well
+// as one that was revived, and any later change to the kerberos spec.
+type KerberosReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeKerberosReconciler will build a KerberosReconciler object
+func MakeKerberosReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &KerberosReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will set the Kerberos parameters in the database if they don't
+// match the spec
+func (k *KerberosReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if !k.Vdb.IsKerberosEnabled() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := k.PFacts.Collect(ctx, k.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Nothing to do until the database has been initialized
+	if !k.PFacts.doesDBExist().IsTrue() {
+		return ctrl.Result{}, nil
+	}
+	atPod, ok := k.PFacts.findPodToRunVsql()
+	if !ok {
+		k.Log.Info("No up pod found to set the Kerberos parameters. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	expParms := k.genParms()
+	curParms, err := k.getCurrentParms(ctx, atPod, expParms)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	setList := genSetList(expParms, curParms)
+	if len(setList) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	cmd := []string{
+		"-c", fmt.Sprintf("alter database default set %s", strings.Join(setList, ", ")),
+	}
+	if _, _, err := k.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, cmd...); err != nil {
+		return ctrl.Result{}, err
+	}
+	k.VRec.EVRec.Eventf(k.Vdb, corev1.EventTypeNormal, events.KerberosAuthConfigured,
+		"Set the Kerberos parameters for the realm '%s'", k.Vdb.Spec.Kerberos.Realm)
+	return ctrl.Result{}, nil
+}
+
+// genParms returns the Kerberos parameters we want set in the database
+func (k *KerberosReconciler) genParms() map[string]string {
+	return map[string]string{
+		"KerberosServiceName": k.Vdb.Spec.Kerberos.ServiceName,
+		"KerberosRealm":       k.Vdb.Spec.Kerberos.Realm,
+		"KerberosKeytabFile":  paths.Krb5Keytab,
+	}
+}
+
+// getCurrentParms returns the current value in the database of each of the
+// given parameters.  The map is keyed by the lowercase parameter name.
+func (k *KerberosReconciler) getCurrentParms(ctx context.Context, atPod *PodFact,
+	parms map[string]string) (map[string]string, error) {
+	cmd := []string{
+		"-tAc", fmt.Sprintf("show database default %s", strings.Join(sortedKeys(parms), ", ")),
+	}
+	stdout, _, err := k.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, cmd...)
+	if err != nil {
+		return nil, err
+	}
+	return parseParmOutput(stdout), nil
+}
+
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("kerberos_reconcile", func() {
+	ctx := context.Background()
+
+	It("should not run any vsql if kerberos isn't enabled", func() {
+		vdb := vapi.MakeVDB()
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeKerberosReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("show database"))).Should(Equal(0))
+		Expect(len(fpr.FindCommands("alter database"))).Should(Equal(0))
+	})
+
+	It("should set the kerberos parameters that don't match the spec", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Kerberos = vapi.KerberosSpec{
+			KeytabSecret:  "keytab",
+			Krb5ConfigMap: "krb5-conf",
+			Realm:         "EXAMPLE.COM",
+			ServiceName:   "vertica",
+		}
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		fpr.Results = cmds.CmdResults{}
+		for i := int32(0); i < vdb.Spec.Subclusters[0].Size; i++ {
+			fpr.Results[names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i)] = []cmds.CmdResult{
+				{Stdout: "KerberosKeytabFile|/etc/krb5/krb5.keytab\nKerberosRealm|\nKerberosServiceName|vertica\n"},
+			}
+		}
+		r := MakeKerberosReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		h := fpr.FindCommands("alter database default set")
+		Expect(len(h)).Should(Equal(1))
+		Expect(h[0].Command).Should(ContainElement("alter database default set KerberosRealm = 'EXAMPLE.COM'"))
+	})
+
+	It("should not change the parameters if they already match", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Kerberos = vapi.KerberosSpec{
+			KeytabSecret:  "keytab",
+			Krb5ConfigMap: "krb5-conf",
+			Realm:         "EXAMPLE.COM",
+			ServiceName:   "vertica",
+		}
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		fpr.Results = cmds.CmdResults{}
+		for i := int32(0); i < vdb.Spec.Subclusters[0].Size; i++ {
+			fpr.Results[names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i)] = []cmds.CmdResult{
+				{Stdout: "KerberosKeytabFile|/etc/krb5/krb5.keytab\nKerberosRealm|EXAMPLE.COM\nKerberosServiceName|vertica\n"},
+			}
+		}
+		r := MakeKerberosReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("alter database"))).Should(Equal(0))
+	})
+})
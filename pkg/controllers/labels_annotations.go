@@ -15,11 +15,28 @@
 
 package controllers
 
-import vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+import (
+	"sort"
+	"strings"
+
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+)
 
 const (
 	SvcTypeLabel    = "vertica.com/svc-type"
 	SubclusterLabel = "vertica.com/subcluster"
+	// The label added to a VerticaBackup that was created by a schedule.  The
+	// value is the name of the VerticaBackupSchedule.
+	BackupScheduleLabel = "vertica.com/backup-schedule"
+	// The label that the external service uses to pick the pods that client
+	// connections are routed to.  It is removed from a pod to drain it of
+	// client connections before its node is removed.
+	ClientRoutingLabel = "vertica.com/client-routing"
+	ClientRoutingVal   = "true"
+	// The annotation on the external service that holds a comma separated
+	// list of the annotations the operator set on it.  It is used to find the
+	// annotations to remove when they are taken out of the spec.
+	LastAppliedSvcAnnotations = "vertica.com/last-applied-svc-annotations"
 	// The name of the operator
 	OperatorName = "verticadb-operator"
 	// The version number of the operator
@@ -90,8 +107,43 @@ func makeAnnotationsForObject(vdb *vapi.VerticaDB) map[string]string {
 	return annotations
 }
 
+// makeAnnotationsForExtSvc returns the annotations for the external service
+// of a subcluster.  The subcluster's service annotations take precedence over
+// the ones for all objects.
+func makeAnnotationsForExtSvc(vdb *vapi.VerticaDB, sc *vapi.Subcluster) map[string]string {
+	annotations := makeAnnotationsForObject(vdb)
+	for k, v := range sc.ServiceAnnotations {
+		annotations[k] = v
+	}
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	annotations[LastAppliedSvcAnnotations] = strings.Join(keys, ",")
+	return annotations
+}
+
+// getLastAppliedSvcAnnotations returns the keys of the annotations that the
+// operator set on the service the last time it was updated
+func getLastAppliedSvcAnnotations(annotations map[string]string) []string {
+	val, ok := annotations[LastAppliedSvcAnnotations]
+	if !ok || val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
 // makeSvcSelectorLabels returns the labels that are used for selectors in service objects.
 func makeSvcSelectorLabels(vdb *vapi.VerticaDB, sc *vapi.Subcluster) map[string]string {
 	// The selector will simply use the common labels for all objects.
 	return makeCommonLabels(vdb, sc)
 }
+
+// makeClientRoutingSelectorLabels returns the labels that the external service
+// uses as its selector.  Only pods that have not been drained are selected.
+func makeClientRoutingSelectorLabels(vdb *vapi.VerticaDB, sc *vapi.Subcluster) map[string]string {
+	labels := makeSvcSelectorLabels(vdb, sc)
+	labels[ClientRoutingLabel] = ClientRoutingVal
+	return labels
+}
@@ -0,0 +1,264 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/license"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// We start warning about the license expiring when it has this many days
+	// left
+	LicenseExpiryWarningDays = 30
+	// How often the license details in the status are refreshed from the
+	// database.  They are also refreshed whenever licenses are installed.
+	LicenseStatusRefreshInterval = time.Hour
+)
+
+// LicenseReconciler will install the licenses from the licenseSecret in the
+// database whenever the secret changes.  It also reports the limits of the
+// installed license in the status.
+type LicenseReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeLicenseReconciler will build a LicenseReconciler object
+func MakeLicenseReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &LicenseReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will install the licenses if they differ from the ones last
+// installed, then refresh the license details in the status if they are stale.
+// Once the license is in the status, we ask to be run again when it is due for
+// its next refresh.
+func (l *LicenseReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if err := l.PFacts.Collect(ctx, l.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Nothing to do until the database has been initialized
+	if !l.PFacts.doesDBExist().IsTrue() {
+		return ctrl.Result{}, nil
+	}
+	// The license is checked again in a later reconcile.  We don't requeue,
+	// as the database may be purposely down and this must not hold up the
+	// actors that come after us.
+	atPod, ok := l.PFacts.findPodToRunVsql()
+	if !ok {
+		l.Log.Info("No up pod found to check the license. Skipping.")
+		return ctrl.Result{}, nil
+	}
+
+	installed := false
+	if l.Vdb.Spec.LicenseSecret != "" {
+		var res ctrl.Result
+		var err error
+		if installed, res, err = l.reconcileLicenseSecret(ctx, atPod.name); err != nil || res.Requeue {
+			return res, err
+		}
+	}
+	if installed || l.isLicenseStatusStale() {
+		if err := l.reconcileLicenseStatus(ctx, atPod.name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: l.getTimeUntilRefresh()}, nil
+}
+
+// getTimeUntilRefresh returns how long until the license details in the
+// status are due to be refreshed.  Zero is returned if there are no details.
+func (l *LicenseReconciler) getTimeUntilRefresh() time.Duration {
+	lic := l.Vdb.Status.License
+	if lic == nil {
+		return 0
+	}
+	if d := LicenseStatusRefreshInterval - time.Since(lic.LastChecked.Time); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// isLicenseStatusStale returns true if the license details in the status
+// haven't been refreshed within the refresh interval
+func (l *LicenseReconciler) isLicenseStatusStale() bool {
+	lic := l.Vdb.Status.License
+	return lic == nil || time.Since(lic.LastChecked.Time) >= LicenseStatusRefreshInterval
+}
+
+// reconcileLicenseSecret will install the licenses from the secret if they
+// have changed since they were last installed.  It returns true if licenses
+// were installed.
+func (l *LicenseReconciler) reconcileLicenseSecret(ctx context.Context, atPod types.NamespacedName) (bool, ctrl.Result, error) {
+	secret := &corev1.Secret{}
+	nm := types.NamespacedName{Namespace: l.Vdb.Namespace, Name: l.Vdb.Spec.LicenseSecret}
+	if err := l.VRec.Client.Get(ctx, nm, secret); err != nil {
+		if errors.IsNotFound(err) {
+			l.VRec.EVRec.Eventf(l.Vdb, corev1.EventTypeWarning, events.LicenseSecretNotFound,
+				"Could not find the license secret '%s'", l.Vdb.Spec.LicenseSecret)
+			return false, ctrl.Result{Requeue: true}, nil
+		}
+		return false, ctrl.Result{}, fmt.Errorf("could not read the license secret %s: %w", nm.Name, err)
+	}
+	// An empty secret means the CE license is used, which comes with the
+	// container
+	if len(secret.Data) == 0 {
+		return false, ctrl.Result{}, nil
+	}
+
+	licenseNames := license.GetSortedNames(secret)
+	licenseHash := genLicenseHash(secret, licenseNames)
+	if licenseHash == l.Vdb.Status.LicenseHash {
+		return false, ctrl.Result{}, nil
+	}
+
+	// Like the server certificate, the licenses are read from the mount.  We
+	// wait for the kubelet to update it after the secret changes.
+	mounted, err := l.areLicensesMounted(ctx, atPod, licenseNames, licenseHash)
+	if err != nil {
+		return false, ctrl.Result{}, err
+	}
+	if !mounted {
+		l.Log.Info("Licenses in the pod don't match the secret yet. Requeue reconciliation.", "pod", atPod)
+		return false, ctrl.Result{Requeue: true}, nil
+	}
+
+	sqls := []string{}
+	for _, nm := range licenseNames {
+		sqls = append(sqls, fmt.Sprintf("select install_license('%s');", license.GetMountedPath(nm)))
+	}
+	if _, _, err := l.PRunner.ExecVSQL(ctx, atPod, ServerContainer, "-c", strings.Join(sqls, " ")); err != nil {
+		l.VRec.EVRec.Eventf(l.Vdb, corev1.EventTypeWarning, events.LicenseInstallFailed,
+			"Failed to install the licenses from the secret '%s'", l.Vdb.Spec.LicenseSecret)
+		return false, ctrl.Result{}, err
+	}
+	l.VRec.EVRec.Eventf(l.Vdb, corev1.EventTypeNormal, events.LicenseInstalled,
+		"Installed the licenses from the secret '%s'", l.Vdb.Spec.LicenseSecret)
+
+	return true, ctrl.Result{}, status.Update(ctx, l.VRec.Client, l.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.LicenseHash = licenseHash
+		return nil
+	})
+}
+
+// areLicensesMounted returns true if the licenses mounted in the pod match the
+// given hash
+func (l *LicenseReconciler) areLicensesMounted(ctx context.Context, atPod types.NamespacedName,
+	licenseNames []string, licenseHash string) (bool, error) {
+	files := make([]string, len(licenseNames))
+	for i, nm := range licenseNames {
+		files[i] = fmt.Sprintf("'%s'", license.GetMountedPath(nm))
+	}
+	cmd := []string{
+		"bash", "-c", fmt.Sprintf("cat %s | sha256sum", strings.Join(files, " ")),
+	}
+	stdout, _, err := l.PRunner.ExecInPod(ctx, atPod, ServerContainer, cmd...)
+	if err != nil {
+		return false, err
+	}
+	// The output of sha256sum is the hash followed by the file name
+	return strings.HasPrefix(stdout, licenseHash), nil
+}
+
+// reconcileLicenseStatus will update the status with the details of the
+// installed license.  A warning is logged as the license gets close to
+// expiring.
+func (l *LicenseReconciler) reconcileLicenseStatus(ctx context.Context, atPod types.NamespacedName) error {
+	stdout, _, err := l.PRunner.ExecVSQL(ctx, atPod, ServerContainer, "-tAc", "select get_compliance_status()")
+	if err != nil {
+		return err
+	}
+	lic := parseComplianceStatus(stdout)
+	lic.LastChecked = metav1.Now()
+
+	oldLic := l.Vdb.Status.License
+	if lic.Expiration != "" && lic.DaysRemaining <= LicenseExpiryWarningDays &&
+		(oldLic == nil || oldLic.Expiration != lic.Expiration || oldLic.DaysRemaining != lic.DaysRemaining) {
+		if lic.DaysRemaining < 0 {
+			l.VRec.EVRec.Eventf(l.Vdb, corev1.EventTypeWarning, events.LicenseExpired,
+				"The license expired on %s", lic.Expiration)
+		} else {
+			l.VRec.EVRec.Eventf(l.Vdb, corev1.EventTypeWarning, events.LicenseExpiring,
+				"The license expires in %d days on %s", lic.DaysRemaining, lic.Expiration)
+		}
+	}
+
+	return status.Update(ctx, l.VRec.Client, l.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.License = &lic
+		return nil
+	})
+}
+
+// genLicenseHash returns a hash of the licenses in the secret.  They are
+// hashed in the same order that areLicensesMounted reads them.
+func genLicenseHash(secret *corev1.Secret, licenseNames []string) string {
+	h := sha256.New()
+	for _, nm := range licenseNames {
+		h.Write(secret.Data[nm])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseComplianceStatus will parse the output of get_compliance_status() for
+// the limits of the license.  Each line of interest has the form 'name : value'.
+func parseComplianceStatus(stdout string) vapi.LicenseStatus {
+	lic := vapi.LicenseStatus{}
+	for _, line := range strings.Split(stdout, "\n") {
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		switch {
+		case key == "license size":
+			lic.SizeLimit = val
+		case key == "license end date":
+			lic.Expiration = val
+		case key == "days remaining":
+			if days, err := strconv.ParseFloat(val, 64); err == nil {
+				lic.DaysRemaining = int(math.Floor(days))
+			}
+		case strings.HasSuffix(key, "node limit"):
+			if nodes, err := strconv.Atoi(val); err == nil {
+				lic.NodeLimit = nodes
+			}
+		}
+	}
+	return lic
+}
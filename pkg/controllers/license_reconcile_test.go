@@ -0,0 +1,195 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"yunion.io/x/pkg/tristate"
+)
+
+var _ = Describe("license_reconcile", func() {
+	ctx := context.Background()
+	const LicenseSecretName = "license"
+	const ComplianceOutput = ` Raw Data Size: 0.02TB +/- 0.01TB
+ License Size : 10.00TB
+ Utilization  : 0%
+ Audit Time   : 2021-07-26 13:43:42.076583+00
+ Compliance Status : The database is in compliance with respect to raw data size.
+
+ License End Date: 08/15/2021
+ Days Remaining: 20.37
+ Node count : 3
+ License Node limit : 12
+`
+
+	createLicenseSecret := func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: LicenseSecretName, Namespace: vapi.MakeVDBName().Namespace},
+			Data: map[string][]byte{
+				"b.key": []byte("second"),
+				"a.key": []byte("first"),
+			},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+	}
+	deleteLicenseSecret := func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: LicenseSecretName, Namespace: vapi.MakeVDBName().Namespace},
+		}
+		Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+	}
+	// setResults will have every pod return the given outputs in order
+	setResults := func(vdb *vapi.VerticaDB, fpr *cmds.FakePodRunner, stdouts ...string) {
+		fpr.Results = cmds.CmdResults{}
+		for i := int32(0); i < vdb.Spec.Subclusters[0].Size; i++ {
+			res := []cmds.CmdResult{}
+			for _, stdout := range stdouts {
+				res = append(res, cmds.CmdResult{Stdout: stdout})
+			}
+			fpr.Results[names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i)] = res
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte("first"))
+	h.Write([]byte("second"))
+	expLicenseHash := hex.EncodeToString(h.Sum(nil))
+
+	It("should parse the license limits from the compliance status", func() {
+		Expect(parseComplianceStatus(ComplianceOutput)).Should(Equal(vapi.LicenseStatus{
+			Expiration:    "08/15/2021",
+			DaysRemaining: 20,
+			SizeLimit:     "10.00TB",
+			NodeLimit:     12,
+		}))
+		Expect(parseComplianceStatus(" License Size : 1.00TB\n No expiration date for a Perpetual license\n")).Should(Equal(
+			vapi.LicenseStatus{SizeLimit: "1.00TB"}))
+	})
+
+	It("should install the licenses from the secret and report the license in the status", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.LicenseSecret = LicenseSecretName
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createLicenseSecret()
+		defer deleteLicenseSecret()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		setResults(vdb, fpr, expLicenseHash+"  -\n", "", ComplianceOutput)
+		r := MakeLicenseReconciler(vrec, logger, vdb, fpr, &pfacts)
+		res, err := r.Reconcile(ctx, &ctrl.Request{})
+		Expect(err).Should(Succeed())
+		Expect(res.Requeue).Should(BeFalse())
+		Expect(res.RequeueAfter).Should(BeNumerically("~", LicenseStatusRefreshInterval, time.Minute))
+		Expect(len(fpr.FindCommands(
+			"select install_license('/home/dbadmin/licensing/mnt/a.key'); select install_license('/home/dbadmin/licensing/mnt/b.key');",
+		))).Should(Equal(1))
+		Expect(vdb.Status.LicenseHash).Should(Equal(expLicenseHash))
+		Expect(vdb.Status.License).ShouldNot(BeNil())
+		Expect(vdb.Status.License.NodeLimit).Should(Equal(12))
+		Expect(vdb.Status.License.DaysRemaining).Should(Equal(20))
+
+		// Nothing is run once the licenses are installed and the status is
+		// fresh
+		fpr.Histories = []cmds.CmdHistory{}
+		setResults(vdb, fpr, ComplianceOutput)
+		_, err = r.Reconcile(ctx, &ctrl.Request{})
+		Expect(err).Should(Succeed())
+		Expect(len(fpr.Histories)).Should(Equal(0))
+
+		// Only the compliance status is checked once the status is stale
+		vdb.Status.License.LastChecked = metav1.NewTime(time.Now().Add(-LicenseStatusRefreshInterval))
+		_, err = r.Reconcile(ctx, &ctrl.Request{})
+		Expect(err).Should(Succeed())
+		Expect(len(fpr.FindCommands("install_license"))).Should(Equal(0))
+		Expect(len(fpr.FindCommands("get_compliance_status"))).Should(Equal(1))
+	})
+
+	It("should requeue when the license status is due to be refreshed", func() {
+		vdb := vapi.MakeVDB()
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		vdb.Status.License = &vapi.LicenseStatus{
+			LastChecked: metav1.NewTime(time.Now().Add(-LicenseStatusRefreshInterval / 4)),
+		}
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		for _, pf := range pfacts.Detail {
+			pf.dbExists = tristate.True
+			pf.upNode = true
+		}
+		r := MakeLicenseReconciler(vrec, logger, vdb, fpr, &pfacts)
+		res, err := r.Reconcile(ctx, &ctrl.Request{})
+		Expect(err).Should(Succeed())
+		Expect(res.Requeue).Should(BeFalse())
+		Expect(res.RequeueAfter).Should(BeNumerically("~", LicenseStatusRefreshInterval*3/4, time.Minute))
+		Expect(len(fpr.FindCommands("get_compliance_status"))).Should(Equal(0))
+	})
+
+	It("should not requeue if there is no up pod to check the license", func() {
+		vdb := vapi.MakeVDB()
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		for _, pf := range pfacts.Detail {
+			pf.dbExists = tristate.True
+			pf.upNode = false
+		}
+		r := MakeLicenseReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("get_compliance_status"))).Should(Equal(0))
+	})
+
+	It("should wait for the pods to have the new licenses mounted", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.LicenseSecret = LicenseSecretName
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createLicenseSecret()
+		defer deleteLicenseSecret()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		setResults(vdb, fpr, "0123456789abcdef  -\n")
+		r := MakeLicenseReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(len(fpr.FindCommands("install_license"))).Should(Equal(0))
+		Expect(vdb.Status.LicenseHash).Should(Equal(""))
+	})
+})
@@ -24,7 +24,10 @@ import (
 	"github.com/vertica/vertica-kubernetes/pkg/names"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -35,6 +38,7 @@ const (
 	ServerContainer      = "server"
 	ServerContainerIndex = 0
 	LocalDataPVC         = "local-data"
+	LocalDepotPVC        = "local-depot"
 )
 
 // ObjReconciler will reconcile for all dependent Kubernetes objects. This is
@@ -63,8 +67,8 @@ func (o *ObjReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.
 	// Check the objects for subclusters that should exist.  This will create
 	// missing objects and update existing objects to match the vdb.
 	for i := range o.Vdb.Spec.Subclusters {
-		if err := o.checkForCreatedSubcluster(ctx, &o.Vdb.Spec.Subclusters[i]); err != nil {
-			return ctrl.Result{}, err
+		if res, err := o.checkForCreatedSubcluster(ctx, &o.Vdb.Spec.Subclusters[i]); err != nil || res.Requeue {
+			return res, err
 		}
 	}
 
@@ -73,16 +77,27 @@ func (o *ObjReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	nm := names.GenPrimaryPDBName(o.Vdb)
+	return ctrl.Result{}, o.reconcilePDBObject(ctx, nm, buildPrimaryPDB(nm, o.Vdb))
 }
 
 // checkForCreatedSubcluster handles reconciliation of one subcluster that should exist
-func (o *ObjReconciler) checkForCreatedSubcluster(ctx context.Context, sc *vapi.Subcluster) error {
+func (o *ObjReconciler) checkForCreatedSubcluster(ctx context.Context, sc *vapi.Subcluster) (ctrl.Result, error) {
+	// Label the pods before the service, so that a service that starts
+	// selecting on the client routing label doesn't lose its pods.
+	if err := o.reconcileClientRouting(ctx, sc); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	if err := o.reconcileExtSvc(ctx, sc); err != nil {
-		return err
+		return ctrl.Result{}, err
+	}
+
+	if res, err := o.reconcileSts(ctx, sc); err != nil || res.Requeue {
+		return res, err
 	}
 
-	return o.reconcileSts(ctx, sc)
+	return ctrl.Result{}, o.reconcilePDB(ctx, sc)
 }
 
 // checkForDeletedSubcluster will remove any objects that were created for
@@ -115,6 +130,19 @@ func (o *ObjReconciler) checkForDeletedSubcluster(ctx context.Context) error {
 			return err
 		}
 	}
+
+	// Find any pod disruption budgets that need to be deleted
+	pdbs, err := finder.FindPDBs(ctx, FindNotInVdb)
+	if err != nil {
+		return err
+	}
+
+	for i := range pdbs.Items {
+		err = o.Client.Delete(ctx, &pdbs.Items[i])
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -127,36 +155,32 @@ func (o ObjReconciler) reconcileExtSvc(ctx context.Context, sc *vapi.Subcluster)
 	if err != nil && errors.IsNotFound(err) {
 		return o.createService(ctx, expSvc, svcName)
 	}
+	if err != nil {
+		return err
+	}
 	updated := false
-	const verticaPortIndex = 0
 	// Update the svc according to fields that changed w.r.t  expSvc
 	if expSvc.Spec.Type != curSvc.Spec.Type {
 		updated = true
 		curSvc.Spec.Type = expSvc.Spec.Type
 	}
-
-	if expSvc.Spec.Type == corev1.ServiceTypeLoadBalancer || expSvc.Spec.Type == corev1.ServiceTypeNodePort {
-		if sc.NodePort != 0 {
-			if expSvc.Spec.Ports[verticaPortIndex].NodePort != curSvc.Spec.Ports[verticaPortIndex].NodePort {
-				updated = true
-				curSvc.Spec.Ports = expSvc.Spec.Ports
-			}
-		}
-	} else {
-		// Ensure the nodePort is cleared for each port we expose.  That setting
-		// is only valid for LoadBalancer and NodePort service types.
-		for i := range curSvc.Spec.Ports {
-			if curSvc.Spec.Ports[i].NodePort != 0 {
-				updated = true
-				curSvc.Spec.Ports[i].NodePort = 0
-			}
-		}
+	if updateExtSvcPorts(curSvc, expSvc) {
+		updated = true
 	}
-
 	if !reflect.DeepEqual(expSvc.Spec.ExternalIPs, curSvc.Spec.ExternalIPs) {
 		updated = true
 		curSvc.Spec.ExternalIPs = expSvc.Spec.ExternalIPs
 	}
+	if !reflect.DeepEqual(expSvc.Spec.Selector, curSvc.Spec.Selector) {
+		updated = true
+		curSvc.Spec.Selector = expSvc.Spec.Selector
+	}
+	if updateExtSvcTrafficOptions(curSvc, expSvc) {
+		updated = true
+	}
+	if updateSvcAnnotations(curSvc, expSvc) {
+		updated = true
+	}
 	if updated {
 		o.Log.Info("updating svc", "Name", svcName)
 		return o.Client.Update(ctx, curSvc)
@@ -164,6 +188,196 @@ func (o ObjReconciler) reconcileExtSvc(ctx context.Context, sc *vapi.Subcluster)
 	return nil
 }
 
+// isExternalSvcType returns true if the service type opens a port on each node
+func isExternalSvcType(svcType corev1.ServiceType) bool {
+	return svcType == corev1.ServiceTypeLoadBalancer || svcType == corev1.ServiceTypeNodePort
+}
+
+// updateExtSvcPorts will change the ports in curSvc if they differ from
+// expSvc.  It returns true if anything changed.
+func updateExtSvcPorts(curSvc, expSvc *corev1.Service) bool {
+	if isExternalSvcType(expSvc.Spec.Type) {
+		// Keep the nodePort that Kubernetes allocated for any port that we
+		// didn't pick one for.
+		for i := range expSvc.Spec.Ports {
+			if expSvc.Spec.Ports[i].NodePort != 0 {
+				continue
+			}
+			for j := range curSvc.Spec.Ports {
+				if curSvc.Spec.Ports[j].Name == expSvc.Spec.Ports[i].Name {
+					expSvc.Spec.Ports[i].NodePort = curSvc.Spec.Ports[j].NodePort
+				}
+			}
+		}
+	}
+	// For the other types, the expected ports have no nodePort.  That setting
+	// is only valid for LoadBalancer and NodePort service types.
+	if servicePortsChanged(curSvc.Spec.Ports, expSvc.Spec.Ports) {
+		curSvc.Spec.Ports = expSvc.Spec.Ports
+		return true
+	}
+	return false
+}
+
+// updateExtSvcTrafficOptions will change the load balancer settings and the
+// externalTrafficPolicy in curSvc if they differ from expSvc.  It returns true
+// if anything changed.
+func updateExtSvcTrafficOptions(curSvc, expSvc *corev1.Service) bool {
+	updated := false
+	if expSvc.Spec.LoadBalancerIP != curSvc.Spec.LoadBalancerIP {
+		updated = true
+		curSvc.Spec.LoadBalancerIP = expSvc.Spec.LoadBalancerIP
+	}
+	if !stringSlicesEqual(expSvc.Spec.LoadBalancerSourceRanges, curSvc.Spec.LoadBalancerSourceRanges) {
+		updated = true
+		curSvc.Spec.LoadBalancerSourceRanges = expSvc.Spec.LoadBalancerSourceRanges
+	}
+	// Kubernetes defaults the externalTrafficPolicy to Cluster for the types
+	// that allow it.  It has to be cleared for the other types.
+	expPolicy := expSvc.Spec.ExternalTrafficPolicy
+	if !isExternalSvcType(expSvc.Spec.Type) {
+		expPolicy = ""
+	} else if expPolicy == "" {
+		expPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	}
+	if expPolicy != curSvc.Spec.ExternalTrafficPolicy {
+		updated = true
+		curSvc.Spec.ExternalTrafficPolicy = expPolicy
+	}
+	return updated
+}
+
+// updateSvcAnnotations will add any annotation from expSvc that is missing or
+// different in curSvc.  An annotation is only removed if the operator set it
+// the last time, as a cloud provider may have added its own to the service.
+// It returns true if anything changed.
+func updateSvcAnnotations(curSvc, expSvc *corev1.Service) bool {
+	updated := false
+	for _, k := range getLastAppliedSvcAnnotations(curSvc.Annotations) {
+		if _, ok := expSvc.Annotations[k]; ok {
+			continue
+		}
+		if _, ok := curSvc.Annotations[k]; ok {
+			delete(curSvc.Annotations, k)
+			updated = true
+		}
+	}
+	for k, v := range expSvc.Annotations {
+		if cv, ok := curSvc.Annotations[k]; ok && cv == v {
+			continue
+		}
+		if curSvc.Annotations == nil {
+			curSvc.Annotations = map[string]string{}
+		}
+		curSvc.Annotations[k] = v
+		updated = true
+	}
+	return updated
+}
+
+// servicePortsChanged returns true if the current ports of a service differ
+// from the expected ones in name, port or nodePort
+func servicePortsChanged(curPorts, expPorts []corev1.ServicePort) bool {
+	if len(curPorts) != len(expPorts) {
+		return true
+	}
+	for i := range expPorts {
+		if curPorts[i].Name != expPorts[i].Name || curPorts[i].Port != expPorts[i].Port ||
+			curPorts[i].NodePort != expPorts[i].NodePort {
+			return true
+		}
+	}
+	return false
+}
+
+// stringSlicesEqual returns true if both slices have the same elements.  An
+// empty slice is treated the same as nil.
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// reconcileClientRouting will add the client routing label to any pod in the
+// subcluster that is missing it.  Pods beyond the size of the subcluster are
+// skipped, as they may have been drained before they are removed.  This is the
+// only place the label gets set.  It is kept out of the pod template, as
+// changing the template would roll the pods of existing statefulsets.
+func (o *ObjReconciler) reconcileClientRouting(ctx context.Context, sc *vapi.Subcluster) error {
+	for podIndex := int32(0); podIndex < sc.Size; podIndex++ {
+		pod := &corev1.Pod{}
+		if err := o.Client.Get(ctx, names.GenPodName(o.Vdb, sc, podIndex), pod); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if pod.Labels[ClientRoutingLabel] == ClientRoutingVal {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[ClientRoutingLabel] = ClientRoutingVal
+		o.Log.Info("adding client routing label to pod", "Name", pod.Name)
+		if err := o.Client.Patch(ctx, pod, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcilePDB verifies the pod disruption budget for a secondary subcluster
+// exists and is up to date.  A primary subcluster doesn't have its own, as it
+// is covered by the budget for all of the primaries.
+func (o *ObjReconciler) reconcilePDB(ctx context.Context, sc *vapi.Subcluster) error {
+	nm := names.GenPDBName(o.Vdb, sc)
+	if sc.IsPrimary {
+		return o.reconcilePDBObject(ctx, nm, nil)
+	}
+	return o.reconcilePDBObject(ctx, nm, buildPDB(nm, o.Vdb, sc))
+}
+
+// reconcilePDBObject will create or update a pod disruption budget so that it
+// matches expPDB.  If expPDB is nil, the budget is deleted.
+func (o *ObjReconciler) reconcilePDBObject(ctx context.Context, nm types.NamespacedName,
+	expPDB *policyv1beta1.PodDisruptionBudget) error {
+	curPDB := &policyv1beta1.PodDisruptionBudget{}
+	err := o.Client.Get(ctx, nm, curPDB)
+	if expPDB == nil {
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		o.Log.Info("Deleting pod disruption budget", "Name", nm)
+		return o.Client.Delete(ctx, curPDB)
+	}
+	if err != nil && errors.IsNotFound(err) {
+		o.Log.Info("Creating pod disruption budget", "Name", nm)
+		err = ctrl.SetControllerReference(o.Vdb, expPDB, o.Scheme)
+		if err != nil {
+			return err
+		}
+		return o.Client.Create(ctx, expPDB)
+	} else if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(expPDB.Spec.MaxUnavailable, curPDB.Spec.MaxUnavailable) ||
+		!reflect.DeepEqual(expPDB.Spec.Selector, curPDB.Spec.Selector) {
+		o.Log.Info("Updating pod disruption budget", "Name", nm)
+		patch := client.MergeFrom(curPDB.DeepCopy())
+		curPDB.Spec.MaxUnavailable = expPDB.Spec.MaxUnavailable
+		curPDB.Spec.Selector = expPDB.Spec.Selector
+		return o.Client.Patch(ctx, curPDB, patch)
+	}
+	return nil
+}
+
 // reconcileHlSvc verifies the headless service object exists and creates it if necessary.
 func (o ObjReconciler) reconcileHlSvc(ctx context.Context) error {
 	curSvc := &corev1.Service{}
@@ -187,7 +401,7 @@ func (o *ObjReconciler) createService(ctx context.Context, svc *corev1.Service,
 }
 
 // reconcileSts reconciles the statefulset for a particular subcluster.
-func (o *ObjReconciler) reconcileSts(ctx context.Context, sc *vapi.Subcluster) error {
+func (o *ObjReconciler) reconcileSts(ctx context.Context, sc *vapi.Subcluster) (ctrl.Result, error) {
 	nm := names.GenStsName(o.Vdb, sc)
 	curSts := &appsv1.StatefulSet{}
 	expSts := buildStsSpec(nm, o.Vdb, sc)
@@ -196,20 +410,135 @@ func (o *ObjReconciler) reconcileSts(ctx context.Context, sc *vapi.Subcluster) e
 		o.Log.Info("Creating statefulset", "Name", nm, "Size", expSts.Spec.Replicas)
 		err = ctrl.SetControllerReference(o.Vdb, expSts, o.Scheme)
 		if err != nil {
-			return err
+			return ctrl.Result{}, err
 		}
 		// Invalidate the pod facts cache since we are creating a new sts
 		o.PFacts.Invalidate()
-		return o.Client.Create(ctx, expSts)
+		return ctrl.Result{}, o.Client.Create(ctx, expSts)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	// Update the sts by patching in fields that changed according to expSts
-	if !reflect.DeepEqual(expSts.Spec, curSts.Spec) {
-		patch := client.MergeFrom(curSts.DeepCopy())
-		expSts.Spec.DeepCopyInto(&curSts.Spec)
-		// Invalidate the pod facts cache since we are about to change the sts
+	// The volumeClaimTemplates of a statefulset cannot be changed.  When the
+	// size of a PVC was increased, we delete the statefulset but leave its
+	// pods running.  The statefulset is created again with the new size once
+	// the delete finishes, and it adopts the orphaned pods.  The PVCs that
+	// already exist are expanded by the PVCExpandReconciler.
+	if curSts.DeletionTimestamp != nil {
+		o.Log.Info("Waiting for the statefulset to be deleted before creating it again", "Name", nm)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if volumeClaimTemplatesChanged(curSts, expSts) {
+		allowed, err := o.canExpandVolumes(ctx, sc, curSts, expSts)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// If the storage class doesn't allow the PVCs to be expanded, we keep
+		// the statefulset as is.  The PVCExpandReconciler writes an event for
+		// each PVC that can't be expanded.
+		if !allowed {
+			o.Log.Info("Storage class doesn't allow volume expansion. Keeping the current volumeClaimTemplates", "Name", nm)
+			expSts.Spec.VolumeClaimTemplates = curSts.Spec.VolumeClaimTemplates
+		}
+	}
+	if volumeClaimTemplatesChanged(curSts, expSts) {
+		o.Log.Info("Deleting statefulset to change its volumeClaimTemplates", "Name", nm)
+		if err := o.Client.Delete(ctx, curSts, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil {
+			return ctrl.Result{}, err
+		}
 		o.PFacts.Invalidate()
-		return o.Client.Patch(ctx, curSts, patch)
+		return ctrl.Result{Requeue: true}, nil
 	}
-	return nil
+
+	// The volumeClaimTemplates are immutable and we have just checked that
+	// they match, so we keep the ones from the server.  This stops the patch
+	// from sending them back without the defaults the API server filled in.
+	expSts.Spec.VolumeClaimTemplates = curSts.Spec.VolumeClaimTemplates
+
+	// The API server fills in defaults for fields we leave empty, such as
+	// those in the sidecars and volumes from the spec, so curSts never
+	// matches expSts.  We do a dry-run of the patch to get the spec with the
+	// server defaults, and only send the real patch if it differs from what
+	// is there now.
+	origSts := curSts.DeepCopy()
+	patch := client.MergeFrom(origSts)
+	expSts.Spec.DeepCopyInto(&curSts.Spec)
+	if err := o.Client.Patch(ctx, curSts, patch, client.DryRunAll); err != nil {
+		return ctrl.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(curSts.Spec, origSts.Spec) {
+		return ctrl.Result{}, nil
+	}
+	expSts.Spec.DeepCopyInto(&curSts.Spec)
+	if err := o.Client.Patch(ctx, curSts, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	o.Log.Info("Patched statefulset", "Name", nm)
+	// Invalidate the pod facts cache since we changed the sts
+	o.PFacts.Invalidate()
+	return ctrl.Result{}, nil
+}
+
+// canExpandVolumes returns false if the size of a volumeClaimTemplate was
+// increased, but the storage class of the PVCs made from it doesn't allow
+// them to be expanded
+func (o *ObjReconciler) canExpandVolumes(ctx context.Context, sc *vapi.Subcluster, curSts, expSts *appsv1.StatefulSet) (bool, error) {
+	for i := range expSts.Spec.VolumeClaimTemplates {
+		expTmpl := &expSts.Spec.VolumeClaimTemplates[i]
+		for j := range curSts.Spec.VolumeClaimTemplates {
+			curTmpl := &curSts.Spec.VolumeClaimTemplates[j]
+			if curTmpl.Name != expTmpl.Name {
+				continue
+			}
+			curSize := curTmpl.Spec.Resources.Requests[corev1.ResourceStorage]
+			expSize := expTmpl.Spec.Resources.Requests[corev1.ResourceStorage]
+			if expSize.Cmp(curSize) <= 0 {
+				continue
+			}
+			allowed, err := o.canExpandPVCsFromTemplate(ctx, sc, expTmpl.Name)
+			if err != nil || !allowed {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// canExpandPVCsFromTemplate checks the storage class of the first PVC that
+// exists for the given volumeClaimTemplate.  If none exist, there is nothing
+// to expand.
+func (o *ObjReconciler) canExpandPVCsFromTemplate(ctx context.Context, sc *vapi.Subcluster, tmplName string) (bool, error) {
+	for podIndex := int32(0); podIndex < sc.Size; podIndex++ {
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := o.Client.Get(ctx, names.GenPVCName(o.Vdb, sc, tmplName, podIndex), pvc); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		return isVolumeExpansionAllowed(ctx, o.Client, pvc)
+	}
+	return true, nil
+}
+
+// volumeClaimTemplatesChanged returns true if the PVCs in the current
+// statefulset differ in name or size from the expected ones
+func volumeClaimTemplatesChanged(curSts, expSts *appsv1.StatefulSet) bool {
+	curTmpls := curSts.Spec.VolumeClaimTemplates
+	expTmpls := expSts.Spec.VolumeClaimTemplates
+	if len(curTmpls) != len(expTmpls) {
+		return true
+	}
+	for i := range expTmpls {
+		if curTmpls[i].Name != expTmpls[i].Name {
+			return true
+		}
+		curSize := curTmpls[i].Spec.Resources.Requests[corev1.ResourceStorage]
+		expSize := expTmpls[i].Spec.Resources.Requests[corev1.ResourceStorage]
+		if curSize.Cmp(expSize) != 0 {
+			return true
+		}
+	}
+	return false
 }
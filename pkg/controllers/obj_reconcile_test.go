@@ -23,8 +23,12 @@ import (
 	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
 	"github.com/vertica/vertica-kubernetes/pkg/cmds"
 	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/paths"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -77,6 +81,20 @@ var _ = Describe("obj_reconcile", func() {
 			Expect(k8sClient.Get(ctx, stsNm, sts)).Should(Succeed())
 			Expect(sts.ObjectMeta.OwnerReferences).To(ContainElement(expOwnerRef))
 			Expect(k8sClient.Delete(ctx, sts)).Should(Succeed())
+
+			pdb := &policyv1beta1.PodDisruptionBudget{}
+			if vdb.Spec.Subclusters[i].IsPrimary {
+				Expect(kerrors.IsNotFound(k8sClient.Get(ctx, names.GenPDBName(vdb, &vdb.Spec.Subclusters[i]), pdb))).Should(BeTrue())
+				continue
+			}
+			Expect(k8sClient.Get(ctx, names.GenPDBName(vdb, &vdb.Spec.Subclusters[i]), pdb)).Should(Succeed())
+			Expect(pdb.ObjectMeta.OwnerReferences).To(ContainElement(expOwnerRef))
+			Expect(k8sClient.Delete(ctx, pdb)).Should(Succeed())
+		}
+		pdb := &policyv1beta1.PodDisruptionBudget{}
+		if err := k8sClient.Get(ctx, names.GenPrimaryPDBName(vdb), pdb); err == nil {
+			Expect(pdb.ObjectMeta.OwnerReferences).To(ContainElement(expOwnerRef))
+			Expect(k8sClient.Delete(ctx, pdb)).Should(Succeed())
 		}
 		svc := &corev1.Service{}
 		Expect(k8sClient.Get(ctx, names.GenHlSvcName(vdb), svc)).Should(Succeed())
@@ -148,6 +166,60 @@ var _ = Describe("obj_reconcile", func() {
 			Expect(foundSvc.Spec.ExternalIPs).Should(Equal(newExternalIPs))
 		})
 
+		It("should have load balancer options, annotations and a choice of ports in ext service", func() {
+			vdb := vapi.MakeVDB()
+			sc := &vdb.Spec.Subclusters[0]
+			sc.ServiceType = corev1.ServiceTypeLoadBalancer
+			sc.ServiceAnnotations = map[string]string{
+				"service.beta.kubernetes.io/aws-load-balancer-internal": "true",
+				"service.beta.kubernetes.io/aws-load-balancer-type":     "nlb",
+			}
+			sc.LoadBalancerIP = "10.20.30.40"
+			sc.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+			sc.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+			sc.ServicePorts = []vapi.ServicePortName{vapi.ServicePortVertica}
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			extNameLookup := names.GenExtSvcName(vdb, sc)
+			foundSvc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, extNameLookup, foundSvc)).Should(Succeed())
+			Expect(foundSvc.Annotations).Should(HaveKeyWithValue("service.beta.kubernetes.io/aws-load-balancer-internal", "true"))
+			Expect(foundSvc.Annotations).Should(HaveKeyWithValue("service.beta.kubernetes.io/aws-load-balancer-type", "nlb"))
+			// An annotation added by someone else must survive the update
+			foundSvc.Annotations["cloud-provider/lb-id"] = "abc"
+			Expect(k8sClient.Update(ctx, foundSvc)).Should(Succeed())
+			Expect(foundSvc.Spec.LoadBalancerIP).Should(Equal("10.20.30.40"))
+			Expect(foundSvc.Spec.LoadBalancerSourceRanges).Should(Equal([]string{"10.0.0.0/8"}))
+			Expect(foundSvc.Spec.ExternalTrafficPolicy).Should(Equal(corev1.ServiceExternalTrafficPolicyTypeLocal))
+			Expect(len(foundSvc.Spec.Ports)).Should(Equal(1))
+			Expect(foundSvc.Spec.Ports[0].Port).Should(Equal(int32(5433)))
+
+			// Switch back to a ClusterIP with both ports exposed
+			sc.ServiceType = corev1.ServiceTypeClusterIP
+			sc.ServiceAnnotations = map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "false"}
+			sc.LoadBalancerIP = ""
+			sc.LoadBalancerSourceRanges = nil
+			sc.ExternalTrafficPolicy = ""
+			sc.ServicePorts = nil
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+			foundSvc = &corev1.Service{}
+			Expect(k8sClient.Get(ctx, extNameLookup, foundSvc)).Should(Succeed())
+			Expect(foundSvc.Spec.Type).Should(Equal(corev1.ServiceTypeClusterIP))
+			Expect(foundSvc.Annotations).Should(HaveKeyWithValue("service.beta.kubernetes.io/aws-load-balancer-internal", "false"))
+			Expect(foundSvc.Annotations).ShouldNot(HaveKey("service.beta.kubernetes.io/aws-load-balancer-type"))
+			Expect(foundSvc.Annotations).Should(HaveKeyWithValue("cloud-provider/lb-id", "abc"))
+			Expect(foundSvc.Spec.LoadBalancerIP).Should(Equal(""))
+			Expect(foundSvc.Spec.LoadBalancerSourceRanges).Should(BeEmpty())
+			Expect(foundSvc.Spec.ExternalTrafficPolicy).Should(BeEmpty())
+			Expect(len(foundSvc.Spec.Ports)).Should(Equal(2))
+			Expect(foundSvc.Spec.Ports[1].Port).Should(Equal(int32(5444)))
+		})
+
 		It("should have custom labels and annotations in service objects and statefulsets", func() {
 			vdb := vapi.MakeVDB()
 			vdb.Spec.Labels["my-label"] = "r1"
@@ -178,6 +250,63 @@ var _ = Describe("obj_reconcile", func() {
 			verifyLabelsAnnotations(&sts.ObjectMeta, true /* subcluster specific */)
 		})
 
+		It("should route client connections only to pods with the client routing label", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.Subclusters[0].Size = 2
+			createVdb(ctx, vdb)
+			defer deleteVdb(ctx, vdb)
+			createPods(ctx, vdb, AllPodsRunning)
+			defer deletePods(ctx, vdb)
+			createSvcs(ctx, vdb)
+			defer deleteSvcs(ctx, vdb)
+
+			// Mimic a service that was created before the client routing label
+			svc := &corev1.Service{}
+			svcName := names.GenExtSvcName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(k8sClient.Get(ctx, svcName, svc)).Should(Succeed())
+			delete(svc.Spec.Selector, ClientRoutingLabel)
+			Expect(k8sClient.Update(ctx, svc)).Should(Succeed())
+
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+			Expect(k8sClient.Get(ctx, svcName, svc)).Should(Succeed())
+			Expect(svc.Spec.Selector[ClientRoutingLabel]).Should(Equal(ClientRoutingVal))
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, names.GenStsName(vdb, &vdb.Spec.Subclusters[0]), sts)).Should(Succeed())
+			// The label is only ever set on the pods.  Having it in the template
+			// would roll the pods of existing statefulsets.
+			Expect(sts.Spec.Template.Labels).ShouldNot(HaveKey(ClientRoutingLabel))
+
+			// Pods that were created before the label existed get it added
+			for i := int32(0); i < 2; i++ {
+				pod := &corev1.Pod{}
+				Expect(k8sClient.Get(ctx, names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i), pod)).Should(Succeed())
+				Expect(pod.Labels[ClientRoutingLabel]).Should(Equal(ClientRoutingVal))
+			}
+		})
+
+		It("should not change the pod template of a statefulset when the vdb is unchanged", func() {
+			vdb := vapi.MakeVDB()
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			sts := &appsv1.StatefulSet{}
+			stsName := names.GenStsName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(k8sClient.Get(ctx, stsName, sts)).Should(Succeed())
+			origTemplate := sts.Spec.Template.DeepCopy()
+			origVersion := sts.ResourceVersion
+
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+			Expect(k8sClient.Get(ctx, stsName, sts)).Should(Succeed())
+			Expect(sts.ResourceVersion).Should(Equal(origVersion))
+			Expect(sts.Spec.Template).Should(Equal(*origTemplate))
+		})
+
 		It("should create a statefulset with the configured size", func() {
 			vdb := vapi.MakeVDB()
 			var desiredSize int32 = 16
@@ -194,6 +323,20 @@ var _ = Describe("obj_reconcile", func() {
 			Expect(sts.Spec.Template.Spec.Containers[0].Image).Should(Equal(vdb.Spec.Image))
 		})
 
+		It("should scale the statefulset to zero when the subcluster is shutdown", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.Subclusters[0].Size = 3
+			vdb.Spec.Subclusters[0].Shutdown = true
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			sts := &appsv1.StatefulSet{}
+			nm := names.GenStsName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(k8sClient.Get(ctx, nm, sts)).Should(Succeed())
+			Expect(*sts.Spec.Replicas).Should(Equal(int32(0)))
+		})
+
 		It("should create a statefulset with a configured pull policy", func() {
 			vdb := vapi.MakeVDB()
 			vdb.Spec.ImagePullPolicy = corev1.PullNever
@@ -208,6 +351,44 @@ var _ = Describe("obj_reconcile", func() {
 			Expect(sts.Spec.Template.Spec.Containers[0].ImagePullPolicy).Should(Equal(corev1.PullNever))
 		})
 
+		It("should create a statefulset with sidecars, extra volumes and extra env", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.Sidecars = []corev1.Container{{Name: "vlogger", Image: "vertica/vertica-logger"}}
+			vdb.Spec.Volumes = []corev1.Volume{
+				{Name: "udx-libs", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			}
+			vdb.Spec.VolumeMounts = []corev1.VolumeMount{{Name: "udx-libs", MountPath: "/opt/udx"}}
+			vdb.Spec.Env = []corev1.EnvVar{{Name: "TZ", Value: "UTC"}}
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			sts := &appsv1.StatefulSet{}
+			nm := names.GenStsName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(k8sClient.Get(ctx, nm, sts)).Should(Succeed())
+			cnts := sts.Spec.Template.Spec.Containers
+			Expect(len(cnts)).Should(Equal(2))
+			Expect(cnts[ServerContainerIndex].Name).Should(Equal(ServerContainer))
+			Expect(cnts[ServerContainerIndex].Env).Should(ContainElement(vdb.Spec.Env[0]))
+			Expect(cnts[ServerContainerIndex].VolumeMounts).Should(ContainElement(vdb.Spec.VolumeMounts[0]))
+			Expect(cnts[1].Name).Should(Equal("vlogger"))
+			Expect(cnts[1].VolumeMounts).Should(Equal(cnts[ServerContainerIndex].VolumeMounts[:len(cnts[1].VolumeMounts)]))
+			Expect(sts.Spec.Template.Spec.Volumes).Should(ContainElement(vdb.Spec.Volumes[0]))
+
+			// Nothing changes when we reconcile the same spec again
+			createdVdb := &vapi.VerticaDB{}
+			Expect(k8sClient.Get(ctx, vdb.ExtractNamespacedName(), createdVdb)).Should(Succeed())
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			pfacts.NeedCollection = false
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, createdVdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+			Expect(pfacts.NeedCollection).Should(BeFalse())
+			// The statefulset isn't written to when nothing changed
+			unchangedSts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, nm, unchangedSts)).Should(Succeed())
+			Expect(unchangedSts.ResourceVersion).Should(Equal(sts.ResourceVersion))
+		})
+
 		It("should create a statefulset with a configured StorageClassName", func() {
 			vdb := vapi.MakeVDB()
 			desiredStorageClass := "my-storage"
@@ -223,6 +404,109 @@ var _ = Describe("obj_reconcile", func() {
 			Expect(currStorageClass).Should(Equal(desiredStorageClass))
 		})
 
+		It("should create a separate depot PVC when a depot size is given", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.Local.DepotRequestSize = resource.MustParse("100Gi")
+			vdb.Spec.Local.DepotStorageClass = "nvme"
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			sts := &appsv1.StatefulSet{}
+			nm := names.GenStsName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(k8sClient.Get(ctx, nm, sts)).Should(Succeed())
+			Expect(len(sts.Spec.VolumeClaimTemplates)).Should(Equal(2))
+			depotPVC := sts.Spec.VolumeClaimTemplates[1]
+			Expect(depotPVC.Name).Should(Equal(LocalDepotPVC))
+			Expect(*depotPVC.Spec.StorageClassName).Should(Equal("nvme"))
+			depotSize := depotPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(depotSize.Cmp(vdb.Spec.Local.DepotRequestSize)).Should(Equal(0))
+			Expect(sts.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).Should(BeNil())
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name: LocalDepotPVC, SubPath: paths.GetPVSubPath(vdb, "depot"), MountPath: vdb.Spec.Local.DepotPath,
+			}))
+		})
+
+		It("should recreate the statefulset when the size of the local data PVC is increased", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.Local.RequestSize = resource.MustParse("500Gi")
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			vdb.Spec.Local.RequestSize = resource.MustParse("600Gi")
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+
+			sts := &appsv1.StatefulSet{}
+			nm := names.GenStsName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(kerrors.IsNotFound(k8sClient.Get(ctx, nm, sts))).Should(BeTrue())
+
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+			Expect(k8sClient.Get(ctx, nm, sts)).Should(Succeed())
+			size := sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(size.String()).Should(Equal("600Gi"))
+		})
+
+		It("should not recreate the statefulset if the storage class doesn't allow expansion", func() {
+			createStorageClass(ctx, NonExpandableStorageClass, false)
+			defer deleteStorageClass(ctx, NonExpandableStorageClass)
+			vdb := vapi.MakeVDB()
+			vdb.Spec.Local.RequestSize = resource.MustParse("500Gi")
+			vdb.Spec.Local.StorageClass = NonExpandableStorageClass
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+			sc := &vdb.Spec.Subclusters[0]
+			storageClass := NonExpandableStorageClass
+			nm := names.GenPVCName(vdb, sc, LocalDataPVC, 0)
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: nm.Name, Namespace: nm.Namespace},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClass,
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("500Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, pvc)).Should(Succeed()) }()
+
+			vdb.Spec.Local.RequestSize = resource.MustParse("600Gi")
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, names.GenStsName(vdb, sc), sts)).Should(Succeed())
+			Expect(sts.DeletionTimestamp).Should(BeNil())
+			size := sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(size.String()).Should(Equal("500Gi"))
+		})
+
+		It("should only use the OnDelete update strategy while the image is being changed", func() {
+			vdb := vapi.MakeVDB()
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			sts := &appsv1.StatefulSet{}
+			nm := names.GenStsName(vdb, &vdb.Spec.Subclusters[0])
+			Expect(k8sClient.Get(ctx, nm, sts)).Should(Succeed())
+			Expect(sts.Spec.UpdateStrategy.Type).Should(Equal(appsv1.RollingUpdateStatefulSetStrategyType))
+
+			Expect(status.UpdateCondition(ctx, k8sClient, vdb,
+				vapi.VerticaDBCondition{Type: vapi.ImageChangeInProgress, Status: corev1.ConditionTrue})).Should(Succeed())
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+			sts = &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, nm, sts)).Should(Succeed())
+			Expect(sts.Spec.UpdateStrategy.Type).Should(Equal(appsv1.OnDeleteStatefulSetStrategyType))
+			Expect(sts.Spec.UpdateStrategy.RollingUpdate).Should(BeNil())
+		})
+
 		It("should create a statefulset with a configured NodeSelector", func() {
 			vdb := vapi.MakeVDB()
 			desiredNodeSelector := map[string]string{
@@ -339,6 +623,58 @@ var _ = Describe("obj_reconcile", func() {
 			Expect(curSize).Should(Equal(int32(8)))
 		})
 
+		It("should create one pod disruption budget for the primaries and one for each secondary", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.KSafety = vapi.KSafety1
+			vdb.Spec.Subclusters[0].IsPrimary = true
+			vdb.Spec.Subclusters = append(vdb.Spec.Subclusters,
+				vapi.Subcluster{Name: "analytics", Size: 4},
+				vapi.Subcluster{Name: "main2", Size: 3, IsPrimary: true},
+			)
+
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+
+			pdb := &policyv1beta1.PodDisruptionBudget{}
+			Expect(k8sClient.Get(ctx, names.GenPrimaryPDBName(vdb), pdb)).Should(Succeed())
+			Expect(pdb.Spec.MaxUnavailable.String()).Should(Equal("1"))
+			Expect(pdb.Spec.Selector.MatchLabels).ShouldNot(HaveKey(SubclusterLabel))
+			Expect(pdb.Spec.Selector.MatchExpressions).Should(Equal([]metav1.LabelSelectorRequirement{
+				{Key: SubclusterLabel, Operator: metav1.LabelSelectorOpIn,
+					Values: []string{vdb.Spec.Subclusters[0].Name, vdb.Spec.Subclusters[2].Name}},
+			}))
+			Expect(kerrors.IsNotFound(k8sClient.Get(ctx, names.GenPDBName(vdb, &vdb.Spec.Subclusters[0]), pdb))).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, names.GenPDBName(vdb, &vdb.Spec.Subclusters[1]), pdb)).Should(Succeed())
+			Expect(pdb.Spec.MaxUnavailable.String()).Should(Equal("50%"))
+		})
+
+		It("should update the pod disruption budget and remove it with its subcluster", func() {
+			vdb := vapi.MakeVDB()
+			vdb.Spec.KSafety = vapi.KSafety1
+			vdb.Spec.Subclusters[0].IsPrimary = true
+			vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{
+				Name: "analytics",
+				Size: 4,
+			})
+			createCrd(vdb)
+			defer deleteCrd(vdb)
+			removedPDB := names.GenPDBName(vdb, &vdb.Spec.Subclusters[1])
+
+			vdb.Spec.KSafety = vapi.KSafety0
+			vdb.Spec.Subclusters = vdb.Spec.Subclusters[:1]
+			Expect(k8sClient.Update(ctx, vdb)).Should(Succeed())
+
+			pfacts := MakePodFacts(k8sClient, &cmds.FakePodRunner{})
+			objr := MakeObjReconciler(k8sClient, scheme.Scheme, logger, vdb, &pfacts)
+			Expect(objr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+			// There is no budget for the primaries with k-safety 0, as a
+			// budget of 0 would block draining the Kubernetes nodes.
+			pdb := &policyv1beta1.PodDisruptionBudget{}
+			Expect(kerrors.IsNotFound(k8sClient.Get(ctx, names.GenPrimaryPDBName(vdb), pdb))).Should(BeTrue())
+			Expect(kerrors.IsNotFound(k8sClient.Get(ctx, removedPDB, pdb))).Should(BeTrue())
+		})
+
 		It("Increasing the size of the subcluster should cause the sts to scale out", func() {
 			vdb := vapi.MakeVDB()
 			createCrd(vdb)
@@ -0,0 +1,293 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// OfflineUpgradeReconciler will handle the process when the vertica image
+// changes and the upgrade policy is Offline.  The entire cluster is stopped,
+// every pod is moved to the new image, then the cluster is started again.
+type OfflineUpgradeReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+	Manager UpgradeManager
+}
+
+// MakeOfflineUpgradeReconciler will build an OfflineUpgradeReconciler object
+func MakeOfflineUpgradeReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &OfflineUpgradeReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts,
+		Manager: *MakeUpgradeManager(vdbrecon, log, vdb, vapi.OfflineUpgrade),
+	}
+}
+
+// Reconcile will handle the process of the vertica image changing with an
+// offline upgrade.
+func (o *OfflineUpgradeReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if !o.Manager.isUpgradeAllowed() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := o.PFacts.Collect(ctx, o.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// We continue with the upgrade even after all of the pods have the new
+	// image.  The cluster still needs to be started, and we only do that once
+	// we have verified the new version.
+	inProgress, err := o.Vdb.IsConditionSet(vapi.ImageChangeInProgress)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if ok, err := o.Manager.isUpgradeNeeded(ctx, o.PFacts); err != nil || (!ok && !inProgress) {
+		return ctrl.Result{}, err
+	}
+
+	// The upgrade is done in phases.  Each phase is idempotent, so if we
+	// requeue we start over from the first phase, and phases that are already
+	// done become no-ops.
+	funcs := []func(context.Context, *ctrl.Request) (ctrl.Result, error){
+		o.checkTargetVersion,
+		o.startUpgrade,
+		o.stopCluster,
+		o.updateImageInStatefulSets,
+		o.deletePodsWithOldImage,
+		o.checkNewVersion,
+		o.restartCluster,
+		o.finishUpgrade,
+	}
+	for _, fn := range funcs {
+		if res, err := fn(ctx, req); err != nil || res.Requeue || res.RequeueAfter > 0 {
+			return res, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// startUpgrade will set the status condition to indicate the upgrade started
+func (o *OfflineUpgradeReconciler) startUpgrade(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	return ctrl.Result{}, o.Manager.startUpgrade(ctx)
+}
+
+// finishUpgrade will clear the status condition now that the upgrade is done
+func (o *OfflineUpgradeReconciler) finishUpgrade(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	return ctrl.Result{}, o.Manager.finishUpgrade(ctx)
+}
+
+// checkTargetVersion will verify the upgrade path using the version in the
+// tag of the new image.  This is done before anything is stopped, so that an
+// invalid upgrade leaves the database running with the old image.  If the tag
+// has no version, the check is left to checkNewVersion once the pods run the
+// new image.
+func (o *OfflineUpgradeReconciler) checkTargetVersion(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	vinf, ok := version.MakeInfo(o.Vdb)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	newVer, ok := version.GetImageVersion(o.Vdb.Spec.Image)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	if ok, reason := vinf.IsValidUpgradePath(newVer); !ok {
+		o.VRec.EVRec.Eventf(o.Vdb, corev1.EventTypeWarning, events.InvalidUpgradePath,
+			"Invalid upgrade path: %s.  The upgrade will not be started.  Change the image back to one that has version %s.",
+			reason, vinf.VdbVer)
+		if err := o.Manager.setUpgradeStatus(ctx, "Invalid upgrade path"); err != nil {
+			return ctrl.Result{}, err
+		}
+		// Requeue so that none of the later actors roll out the new image
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// stopCluster will shutdown the entire cluster using 'admintools -t stop_db'.
+// This is a no-op if no vertica nodes are up, or if every pod already runs
+// the new image.  In the latter case the nodes that are up were started by
+// restartCluster and must be left alone.
+func (o *OfflineUpgradeReconciler) stopCluster(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if o.PFacts.getUpNodeCount() == 0 {
+		return ctrl.Result{}, nil
+	}
+	oldPods := o.PFacts.filterPods(func(v *PodFact) bool {
+		return v.exists && v.image != o.Vdb.Spec.Image
+	})
+	if len(oldPods) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	atPod, ok := o.PFacts.findPodToRunAdmintools()
+	if !ok || !atPod.upNode {
+		o.Log.Info("No up pod found to run admintools from. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if err := o.Manager.setUpgradeStatus(ctx, "Shutting down cluster"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	cmd := []string{
+		"-t", "stop_db",
+		"--database=" + o.Vdb.Spec.DBName,
+		"--force",
+	}
+	o.VRec.EVRec.Event(o.Vdb, corev1.EventTypeNormal, events.ClusterShutdownStarted,
+		"Calling 'admintools -t stop_db' to shutdown the cluster for the upgrade")
+	start := time.Now()
+	if _, _, err := o.PRunner.ExecAdmintools(ctx, atPod.name, ServerContainer, cmd...); err != nil {
+		o.VRec.EVRec.Event(o.Vdb, corev1.EventTypeWarning, events.ClusterShutdownFailed,
+			"Failed while calling 'admintools -t stop_db'")
+		return ctrl.Result{}, err
+	}
+	o.VRec.EVRec.Eventf(o.Vdb, corev1.EventTypeNormal, events.ClusterShutdownSucceeded,
+		"Successfully called 'admintools -t stop_db' and it took %s", time.Since(start))
+
+	// Invalidate the cached pod facts now that vertica is down in every pod.
+	o.PFacts.Invalidate()
+	return ctrl.Result{}, nil
+}
+
+// updateImageInStatefulSets will change the image in every statefulset.  This
+// includes statefulsets for subclusters that are pending removal, as the
+// entire cluster is started with the new version.
+func (o *OfflineUpgradeReconciler) updateImageInStatefulSets(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	stss, err := o.Manager.Finder.FindStatefulSets(ctx, FindAll)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range stss.Items {
+		if _, err := o.Manager.updateImageInStatefulSet(ctx, &stss.Items[i]); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// deletePodsWithOldImage will delete any pod that is running the old image.
+// The statefulset controller will recreate them with the new image.
+func (o *OfflineUpgradeReconciler) deletePodsWithOldImage(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if err := o.PFacts.Collect(ctx, o.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	oldPods := o.PFacts.filterPods(func(v *PodFact) bool {
+		return v.exists && v.image != o.Vdb.Spec.Image
+	})
+	if len(oldPods) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if err := o.Manager.setUpgradeStatus(ctx, "Rescheduling pods with new image"); err != nil {
+		return ctrl.Result{}, err
+	}
+	o.VRec.EVRec.Eventf(o.Vdb, corev1.EventTypeNormal, events.UpgradeProgress,
+		"Deleting %d pod(s) so that they are recreated with the new image", len(oldPods))
+	if err := o.Manager.deletePods(ctx, oldPods); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Refresh the pod facts since pods are being recreated
+	o.PFacts.Invalidate()
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// checkNewVersion will verify that we can upgrade to the version that is in
+// the new image.  We refuse downgrades and any upgrade that skips a required
+// version.  We wait for all of the pods to be running before doing this
+// check.  The cluster is left down if the upgrade path isn't valid.
+func (o *OfflineUpgradeReconciler) checkNewVersion(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if err := o.PFacts.Collect(ctx, o.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	notRunning := o.PFacts.filterPods(func(v *PodFact) bool {
+		return !v.isPodRunning
+	})
+	if len(notRunning) > 0 {
+		o.Log.Info("Waiting for all pods to be running with the new image", "notRunning", genPodNames(notRunning))
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// If we don't know the version we are coming from, then we cannot
+	// validate the upgrade path.
+	vinf, ok := version.MakeInfo(o.Vdb)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+
+	pod, ok := o.PFacts.findRunningPod()
+	if !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	vr := &VersionReconciler{VRec: o.VRec, Log: o.Log, Vdb: o.Vdb, PRunner: o.PRunner, PFacts: o.PFacts}
+	annotations, err := vr.buildVersionAnnotations(ctx, pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	newVer, ok := annotations[vapi.VersionAnnotation]
+	if !ok {
+		o.Log.Info("Could not determine the version in the new image", "pod", pod.name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if ok, reason := vinf.IsValidUpgradePath(newVer); !ok {
+		o.VRec.EVRec.Eventf(o.Vdb, corev1.EventTypeWarning, events.InvalidUpgradePath,
+			"Invalid upgrade path: %s.  Change the image back to one that has version %s to restart the cluster.",
+			reason, vinf.VdbVer)
+		if err := o.Manager.setUpgradeStatus(ctx, "Invalid upgrade path"); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// restartCluster will start the cluster with the new image.  It reuses the
+// restart reconciler, which will re_ip the pods then call start_db.  We
+// requeue until all of the nodes are up.
+func (o *OfflineUpgradeReconciler) restartCluster(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if err := o.PFacts.Collect(ctx, o.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	downPods := o.PFacts.filterPods(func(v *PodFact) bool {
+		return v.dbExists.IsTrue() && !v.upNode
+	})
+	if len(downPods) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if err := o.Manager.setUpgradeStatus(ctx, "Restarting cluster"); err != nil {
+		return ctrl.Result{}, err
+	}
+	r := MakeRestartReconciler(o.VRec, o.Log, o.Vdb, o.PRunner, o.PFacts)
+	if res, err := r.Reconcile(ctx, req); err != nil || res.Requeue || res.RequeueAfter > 0 {
+		return res, err
+	}
+	// Requeue so that we can check the nodes came up before finishing
+	o.PFacts.Invalidate()
+	return ctrl.Result{Requeue: true}, nil
+}
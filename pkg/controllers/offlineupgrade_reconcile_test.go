@@ -0,0 +1,208 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"yunion.io/x/pkg/tristate"
+)
+
+var _ = Describe("offlineupgrade_reconcile", func() {
+	ctx := context.Background()
+	const OldImage = "vertica-k8s:10.1.1-0"
+	const NewImage = "vertica-k8s:11.0.0-0"
+
+	It("should skip the offline upgrade if the policy is online", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.UpgradePolicy = vapi.OnlineUpgrade
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, NewImage)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("stop_db"))).Should(Equal(0))
+	})
+
+	It("should do an offline upgrade if the policy is online but kSafety is 0", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.UpgradePolicy = vapi.OnlineUpgrade
+		vdb.Spec.KSafety = vapi.KSafety0
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, NewImage)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		onr := MakeOnlineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(onr.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeFalse())
+
+		r := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(len(fpr.FindCommands("stop_db"))).Should(Equal(1))
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeTrue())
+	})
+
+	It("should stop the cluster, update the sts and delete the pods", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.UpgradePolicy = vapi.OfflineUpgrade
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, NewImage)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+
+		stopCmd := fpr.FindCommands("stop_db")
+		Expect(len(stopCmd)).Should(Equal(1))
+		Expect(stopCmd[0].Command).Should(ContainElements(
+			"/opt/vertica/bin/admintools",
+			"--database="+vdb.Spec.DBName,
+			"--force",
+		))
+
+		sc := &vdb.Spec.Subclusters[0]
+		sts := &appsv1.StatefulSet{}
+		Expect(k8sClient.Get(ctx, names.GenStsName(vdb, sc), sts)).Should(Succeed())
+		Expect(sts.Spec.Template.Spec.Containers[ServerContainerIndex].Image).Should(Equal(NewImage))
+
+		pod := &corev1.Pod{}
+		for i := int32(0); i < sc.Size; i++ {
+			Expect(kerrors.IsNotFound(k8sClient.Get(ctx, names.GenPodName(vdb, sc, i), pod))).Should(BeTrue())
+		}
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeTrue())
+	})
+
+	It("should not stop the cluster if the new image skips a major version", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.UpgradePolicy = vapi.OfflineUpgrade
+		vdb.ObjectMeta.Annotations[vapi.VersionAnnotation] = "v10.1.1"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, "vertica-k8s:12.0.0-0")
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(len(fpr.FindCommands("stop_db"))).Should(Equal(0))
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeFalse())
+		Expect(vdb.Status.UpgradeStatus).Should(Equal("Invalid upgrade path"))
+	})
+
+	It("should finish the upgrade once all pods run the new image and are up", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = NewImage
+		vdb.Spec.UpgradePolicy = vapi.OfflineUpgrade
+		vdb.ObjectMeta.Annotations[vapi.VersionAnnotation] = "v10.1.1"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		Expect(status.UpdateCondition(ctx, k8sClient, vdb,
+			vapi.VerticaDBCondition{Type: vapi.ImageChangeInProgress, Status: corev1.ConditionTrue})).Should(Succeed())
+
+		fpr := &cmds.FakePodRunner{Results: make(cmds.CmdResults)}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		for _, pf := range pfacts.Detail {
+			pf.upNode = true
+			pf.dbExists = tristate.True
+			fpr.Results[pf.name] = []cmds.CmdResult{
+				{Stdout: "Vertica Analytic Database v11.0.0-0\nvertica(v11.0.0-0) built by @re-docker2 from tag@abcdef on 'Tue Jun  1 05:04:35 2021' $BuildId$\n"},
+			}
+		}
+		r := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("stop_db"))).Should(Equal(0))
+		Expect(len(fpr.FindCommands("start_db"))).Should(Equal(0))
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeFalse())
+	})
+
+	It("should refuse to restart the cluster if the new version is a downgrade", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.UpgradePolicy = vapi.OfflineUpgrade
+		vdb.Spec.Subclusters[0].Size = 1
+		vdb.ObjectMeta.Annotations[vapi.VersionAnnotation] = "v11.0.0"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{Results: make(cmds.CmdResults)}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		fpr.Results[podName] = []cmds.CmdResult{
+			{Stdout: "Vertica Analytic Database v10.1.1-0\nvertica(v10.1.1-0) built by @re-docker2 from tag@abcdef on 'Tue Jun  1 05:04:35 2021' $BuildId$\n"},
+		}
+		act := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		r := act.(*OfflineUpgradeReconciler)
+		Expect(r.checkNewVersion(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+	})
+
+	It("should allow the restart if the new version is one major version newer", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = NewImage
+		vdb.Spec.UpgradePolicy = vapi.OfflineUpgrade
+		vdb.Spec.Subclusters[0].Size = 1
+		vdb.ObjectMeta.Annotations[vapi.VersionAnnotation] = "v10.1.1"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{Results: make(cmds.CmdResults)}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		fpr.Results[podName] = []cmds.CmdResult{
+			{Stdout: "Vertica Analytic Database v11.0.0-0\nvertica(v11.0.0-0) built by @re-docker2 from tag@abcdef on 'Tue Jun  1 05:04:35 2021' $BuildId$\n"},
+		}
+		act := MakeOfflineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		r := act.(*OfflineUpgradeReconciler)
+		Expect(r.checkNewVersion(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+	})
+})
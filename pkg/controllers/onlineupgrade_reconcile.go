@@ -0,0 +1,200 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// OnlineUpgradeReconciler will handle the process when the vertica image
+// changes and the upgrade policy is Online.  It rolls the new image out one subcluster at a time, restarting
+// the nodes as it goes, while keeping the primaries up.
+type OnlineUpgradeReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+	Manager UpgradeManager
+}
+
+// MakeOnlineUpgradeReconciler will build an OnlineUpgradeReconciler object
+func MakeOnlineUpgradeReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &OnlineUpgradeReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts,
+		Manager: *MakeUpgradeManager(vdbrecon, log, vdb, vapi.OnlineUpgrade),
+	}
+}
+
+// Reconcile will handle the process of the vertica image changing.  For
+// example, this can automate the process for an upgrade.
+func (o *OnlineUpgradeReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if !o.Manager.isUpgradeAllowed() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := o.PFacts.Collect(ctx, o.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if ok, err := o.Manager.isUpgradeNeeded(ctx, o.PFacts); err != nil || !ok {
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, o.Manager.finishUpgrade(ctx)
+	}
+
+	if err := o.Manager.startUpgrade(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	subclusters := o.getSubclustersInUpgradeOrder()
+	for i := range subclusters {
+		res, err := o.upgradeSubcluster(ctx, req, subclusters[i], i+1, len(subclusters))
+		if err != nil || res.Requeue || res.RequeueAfter > 0 {
+			return res, err
+		}
+	}
+
+	return ctrl.Result{}, o.Manager.finishUpgrade(ctx)
+}
+
+// getSubclustersInUpgradeOrder returns the subclusters in the order we will
+// upgrade them.  Primaries go first, followed by the secondaries.  Within each
+// group we keep the order they appear in the vdb.
+func (o *OnlineUpgradeReconciler) getSubclustersInUpgradeOrder() []*vapi.Subcluster {
+	subclusters := []*vapi.Subcluster{}
+	for i := range o.Vdb.Spec.Subclusters {
+		if o.Vdb.Spec.Subclusters[i].IsPrimary {
+			subclusters = append(subclusters, &o.Vdb.Spec.Subclusters[i])
+		}
+	}
+	for i := range o.Vdb.Spec.Subclusters {
+		if !o.Vdb.Spec.Subclusters[i].IsPrimary {
+			subclusters = append(subclusters, &o.Vdb.Spec.Subclusters[i])
+		}
+	}
+	return subclusters
+}
+
+// upgradeSubcluster will move a single subcluster to the new image.  It will
+// update the image in the statefulset, delete the pods that are still
+// running the old image, then restart vertica in the new pods.  A requeue is
+// returned until all of the pods in the subcluster are up with the new image.
+func (o *OnlineUpgradeReconciler) upgradeSubcluster(ctx context.Context, req *ctrl.Request, sc *vapi.Subcluster,
+	seq, total int) (ctrl.Result, error) {
+	sts := &appsv1.StatefulSet{}
+	if err := o.VRec.Client.Get(ctx, names.GenStsName(o.Vdb, sc), sts); err != nil {
+		// A missing statefulset is for a new subcluster. It will be created
+		// with the new image, so there is nothing to upgrade.
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if updated, err := o.Manager.updateImageInStatefulSet(ctx, sts); err != nil {
+		return ctrl.Result{}, err
+	} else if updated {
+		msg := fmt.Sprintf("Upgrading subcluster '%s' (%d of %d)", sc.Name, seq, total)
+		o.VRec.EVRec.Event(o.Vdb, corev1.EventTypeNormal, events.UpgradeProgress, msg)
+		if err := o.Manager.setUpgradeStatus(ctx, msg); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if res, err := o.deletePodsWithOldImage(ctx, sc); err != nil || res.Requeue {
+		return res, err
+	}
+
+	return o.restartNodesInSubcluster(ctx, req, sc)
+}
+
+// deletePodsWithOldImage will delete pods in the subcluster that are still
+// running the old image.  The statefulset controller will recreate them with
+// the new image.  For primaries we only take down one pod at a time, and only
+// if all of the other primary nodes are up, so that we don't lose quorum.
+func (o *OnlineUpgradeReconciler) deletePodsWithOldImage(ctx context.Context, sc *vapi.Subcluster) (ctrl.Result, error) {
+	oldPods := o.Manager.findPodsWithOldImage(o.PFacts, sc.Name)
+	if len(oldPods) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if sc.IsPrimary && o.Vdb.Spec.KSafety == vapi.KSafety1 && o.PFacts.doesDBExist().IsTrue() {
+		if o.anyPrimaryNodesDown() {
+			o.Log.Info("Waiting for all primary nodes to be up before continuing with upgrade")
+			return ctrl.Result{Requeue: true}, nil
+		}
+		oldPods = oldPods[:1]
+	}
+
+	if err := o.Manager.deletePods(ctx, oldPods); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Refresh the pod facts since pods are being recreated
+	o.PFacts.Invalidate()
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// restartNodesInSubcluster will ensure vertica is up in each pod of the
+// subcluster that has been added to the database.  It reuses the restart
+// reconciler, which will call restart_node (or start_db if the entire cluster
+// is down).  We requeue until all of the nodes are up.
+func (o *OnlineUpgradeReconciler) restartNodesInSubcluster(ctx context.Context, req *ctrl.Request,
+	sc *vapi.Subcluster) (ctrl.Result, error) {
+	if err := o.PFacts.Collect(ctx, o.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	downPods := o.PFacts.filterPods(func(v *PodFact) bool {
+		return v.subcluster == sc.Name && v.exists && !v.dbExists.IsFalse() && !v.upNode && !v.isShutdown
+	})
+	if len(downPods) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	r := MakeRestartReconciler(o.VRec, o.Log, o.Vdb, o.PRunner, o.PFacts)
+	if res, err := r.Reconcile(ctx, req); err != nil || res.Requeue || res.RequeueAfter > 0 {
+		return res, err
+	}
+	// Requeue so that we can check the nodes came up before moving onto the
+	// next set of pods.
+	o.PFacts.Invalidate()
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// anyPrimaryNodesDown will return true if any pod in a primary subcluster
+// that has been added to the database is not up.
+func (o *OnlineUpgradeReconciler) anyPrimaryNodesDown() bool {
+	primaries := map[string]bool{}
+	for i := range o.Vdb.Spec.Subclusters {
+		primaries[o.Vdb.Spec.Subclusters[i].Name] = o.Vdb.Spec.Subclusters[i].IsPrimary
+	}
+	downPods := o.PFacts.filterPods(func(v *PodFact) bool {
+		return primaries[v.subcluster] && v.dbExists.IsTrue() && !v.upNode
+	})
+	return len(downPods) > 0
+}
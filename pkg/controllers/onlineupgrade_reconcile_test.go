@@ -0,0 +1,165 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("onlineupgrade_reconcile", func() {
+	ctx := context.Background()
+	const OldImage = "vertica-k8s:10.1.1-0"
+	const NewImage = "vertica-k8s:11.0.0-0"
+
+	It("should not start an upgrade if the image hasn't changed", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOnlineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeFalse())
+		Expect(vdb.Status.UpgradeStatus).Should(Equal(""))
+	})
+
+	It("should skip the upgrade if autoRestartVertica is disabled", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.AutoRestartVertica = false
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, NewImage)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOnlineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+		sts := &appsv1.StatefulSet{}
+		Expect(k8sClient.Get(ctx, names.GenStsName(vdb, &vdb.Spec.Subclusters[0]), sts)).Should(Succeed())
+		Expect(sts.Spec.Template.Spec.Containers[ServerContainerIndex].Image).Should(Equal(OldImage))
+	})
+
+	It("should update the sts image and delete one primary pod at a time", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.KSafety = vapi.KSafety1
+		sc := &vdb.Spec.Subclusters[0]
+		sc.IsPrimary = true
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, NewImage)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOnlineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+
+		sts := &appsv1.StatefulSet{}
+		Expect(k8sClient.Get(ctx, names.GenStsName(vdb, sc), sts)).Should(Succeed())
+		Expect(sts.Spec.Template.Spec.Containers[ServerContainerIndex].Image).Should(Equal(NewImage))
+		Expect(sts.Spec.UpdateStrategy.Type).Should(Equal(appsv1.OnDeleteStatefulSetStrategyType))
+
+		pod := &corev1.Pod{}
+		Expect(kerrors.IsNotFound(k8sClient.Get(ctx, names.GenPodName(vdb, sc, 0), pod))).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, names.GenPodName(vdb, sc, 1), pod)).Should(Succeed())
+		Expect(k8sClient.Get(ctx, names.GenPodName(vdb, sc, 2), pod)).Should(Succeed())
+
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeTrue())
+		Expect(vdb.Status.UpgradeStatus).ShouldNot(Equal(""))
+	})
+
+	It("should delete all pods of a secondary subcluster at once", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		vdb.Spec.KSafety = vapi.KSafety1
+		vdb.Spec.Subclusters[0].IsPrimary = true
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{
+			Name: "sc2", Size: 2, IsPrimary: false, ServiceType: corev1.ServiceTypeClusterIP,
+		})
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		updateVdbImage(ctx, vdb, NewImage)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		// Mimic the primary subcluster having already been upgraded
+		for _, pf := range pfacts.Detail {
+			if pf.subcluster == vdb.Spec.Subclusters[0].Name {
+				pf.image = NewImage
+			}
+		}
+		r := MakeOnlineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+
+		pod := &corev1.Pod{}
+		for i := int32(0); i < vdb.Spec.Subclusters[0].Size; i++ {
+			Expect(k8sClient.Get(ctx, names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i), pod)).Should(Succeed())
+		}
+		for i := int32(0); i < vdb.Spec.Subclusters[1].Size; i++ {
+			Expect(kerrors.IsNotFound(k8sClient.Get(ctx, names.GenPodName(vdb, &vdb.Spec.Subclusters[1], i), pod))).Should(BeTrue())
+		}
+	})
+
+	It("should clear the status once the upgrade has finished", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Image = OldImage
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		Expect(status.UpdateCondition(ctx, k8sClient, vdb,
+			vapi.VerticaDBCondition{Type: vapi.ImageChangeInProgress, Status: corev1.ConditionTrue},
+		)).Should(Succeed())
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeOnlineUpgradeReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vdb.IsConditionSet(vapi.ImageChangeInProgress)).Should(BeFalse())
+		Expect(vdb.Status.UpgradeStatus).Should(Equal(""))
+	})
+})
+
+// updateVdbImage will change the image in the vdb to mimic a user initiated
+// upgrade
+func updateVdbImage(ctx context.Context, vdb *vapi.VerticaDB, image string) {
+	vdb.Spec.Image = image
+	ExpectWithOffset(1, k8sClient.Update(ctx, vdb)).Should(Succeed())
+}
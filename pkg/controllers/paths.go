@@ -63,8 +63,12 @@ func debugDumpAdmintoolsConf(ctx context.Context, prunner cmds.PodRunner, atPod
 // the ownership of the config, log and data directory.  This function exists to
 // handle the depot directory.
 func changeDepotPermissions(ctx context.Context, vdb *vapi.VerticaDB, prunner cmds.PodRunner, podList []*PodFact) error {
+	pvPath := paths.LocalDataPath
+	if vdb.HasSeparateDepotVolume() {
+		pvPath = paths.LocalDepotPath
+	}
 	cmd := []string{
-		"sudo", "chown", "dbadmin:verticadba", "-R", fmt.Sprintf("%s/%s", paths.LocalDataPath, paths.GetPVSubPath(vdb, "depot")),
+		"sudo", "chown", "dbadmin:verticadba", "-R", fmt.Sprintf("%s/%s", pvPath, paths.GetPVSubPath(vdb, "depot")),
 	}
 	for _, pod := range podList {
 		if _, _, err := prunner.ExecInPod(ctx, pod.name, ServerContainer, cmd...); err != nil {
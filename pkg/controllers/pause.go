@@ -0,0 +1,56 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// updatePausedCondition will keep the Paused condition in sync with the pause
+// annotation.  An event is written each time the vdb enters or leaves the
+// paused state.
+func (r *VerticaDBReconciler) updatePausedCondition(ctx context.Context, vdb *vapi.VerticaDB) error {
+	isSet, err := vdb.IsConditionSet(vapi.Paused)
+	if err != nil {
+		return err
+	}
+	paused := vdb.IsPaused()
+	if paused == isSet {
+		return nil
+	}
+
+	cond := vapi.VerticaDBCondition{Type: vapi.Paused, Status: corev1.ConditionFalse}
+	if paused {
+		cond.Status = corev1.ConditionTrue
+	}
+	if err := status.UpdateCondition(ctx, r.Client, vdb, cond); err != nil {
+		return err
+	}
+
+	if paused {
+		r.EVRec.Eventf(vdb, corev1.EventTypeNormal, events.ReconcilePaused,
+			"Reconciliation is paused by the '%s' annotation. Only the status will be updated.", vapi.PauseAnnotation)
+	} else {
+		r.EVRec.Event(vdb, corev1.EventTypeNormal, events.ReconcileResumed,
+			"Reconciliation has resumed")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("pause", func() {
+	ctx := context.Background()
+
+	It("should only check the annotation if it is set to true", func() {
+		vdb := vapi.MakeVDB()
+		Expect(vdb.IsPaused()).Should(BeFalse())
+		vdb.Annotations[vapi.PauseAnnotation] = "false"
+		Expect(vdb.IsPaused()).Should(BeFalse())
+		vdb.Annotations[vapi.PauseAnnotation] = "garbage"
+		Expect(vdb.IsPaused()).Should(BeFalse())
+		vdb.Annotations[vapi.PauseAnnotation] = "true"
+		Expect(vdb.IsPaused()).Should(BeTrue())
+	})
+
+	It("should set and clear the paused condition as the annotation changes", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Annotations[vapi.PauseAnnotation] = "true"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+
+		Expect(vrec.updatePausedCondition(ctx, vdb)).Should(Succeed())
+		Expect(vdb.IsConditionSet(vapi.Paused)).Should(BeTrue())
+
+		delete(vdb.Annotations, vapi.PauseAnnotation)
+		Expect(vrec.updatePausedCondition(ctx, vdb)).Should(Succeed())
+		Expect(vdb.IsConditionSet(vapi.Paused)).Should(BeFalse())
+		Expect(vdb.Status.Conditions[vapi.PausedIndex].Type).Should(Equal(vapi.Paused))
+	})
+
+	It("should not create any objects when paused", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Annotations[vapi.PauseAnnotation] = "true"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+
+		Expect(vrec.Reconcile(ctx, ctrl.Request{NamespacedName: vdb.ExtractNamespacedName()})).Should(Equal(ctrl.Result{}))
+		sts := &appsv1.StatefulSet{}
+		err := k8sClient.Get(ctx, names.GenStsName(vdb, &vdb.Spec.Subclusters[0]), sts)
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+
+		fetchVdb(ctx, vdb)
+		Expect(vdb.IsConditionSet(vapi.Paused)).Should(BeTrue())
+	})
+
+	It("should not take a backup when the vdb is paused", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Annotations[vapi.PauseAnnotation] = "true"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vb := vapi.MakeVBackup()
+		createVBackup(ctx, vb)
+		defer deleteVBackup(ctx, vb)
+
+		r := makeVBackupReconciler()
+		Expect(r.Reconcile(ctx, ctrl.Request{NamespacedName: vapi.MakeVBackupName()})).Should(Equal(ctrl.Result{}))
+		Expect(k8sClient.Get(ctx, vapi.MakeVBackupName(), vb)).Should(Succeed())
+		Expect(vb.Status.Phase).Should(BeEmpty())
+		Expect(r.mapVDBToBackups(vdb)).Should(HaveLen(1))
+	})
+
+	It("should not schedule a backup when the vdb is paused", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Annotations[vapi.PauseAnnotation] = "true"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vbs := vapi.MakeVBackupSchedule()
+		createVBackupSchedule(ctx, vbs, time.Now().Add(-48*time.Hour))
+		defer deleteVBackupSchedule(ctx, vbs)
+
+		r := makeVBackupScheduleReconciler()
+		Expect(r.Reconcile(ctx, ctrl.Request{NamespacedName: vapi.MakeVBackupScheduleName()})).Should(Equal(ctrl.Result{}))
+		vbList := &vapi.VerticaBackupList{}
+		Expect(k8sClient.List(ctx, vbList)).Should(Succeed())
+		Expect(vbList.Items).Should(BeEmpty())
+		Expect(r.mapVDBToSchedules(vdb)).Should(HaveLen(1))
+	})
+
+	It("should not scale the vdb when it is paused", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Annotations[vapi.PauseAnnotation] = "true"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := vapi.MakeVAS()
+		vas.Spec.TargetSize = int32Ptr(8)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := makeVerticaAutoscalerReconciler()
+		Expect(r.Reconcile(ctx, ctrl.Request{NamespacedName: vapi.MakeVASName()})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(vdb.Spec.Subclusters[0].Size).Should(Equal(vapi.MakeVDB().Spec.Subclusters[0].Size))
+	})
+})
@@ -48,6 +48,10 @@ type PodFact struct {
 	// Name of the subcluster the pod is part of
 	subcluster string
 
+	// true means the subcluster the pod is part of is shutdown.  Vertica is
+	// not restarted in these pods.
+	isShutdown bool
+
 	// true means the pod exists in k8s.  false means it hasn't been created yet.
 	exists bool
 
@@ -80,6 +84,9 @@ type PodFact struct {
 
 	// Is the agent running in this pod?
 	agentRunning bool
+
+	// The image of the server container that the pod is running
+	image string
 }
 
 type PodFactDetail map[types.NamespacedName]*PodFact
@@ -159,6 +166,7 @@ func (p *PodFacts) collectPodByStsIndex(ctx context.Context, vdb *vapi.VerticaDB
 	pf := PodFact{
 		name:       names.GenPodName(vdb, sc, podIndex),
 		subcluster: sc.Name,
+		isShutdown: sc.Shutdown,
 	}
 
 	pod := &corev1.Pod{}
@@ -170,9 +178,10 @@ func (p *PodFacts) collectPodByStsIndex(ctx context.Context, vdb *vapi.VerticaDB
 		return err
 	}
 	pf.exists = true // Success from the Get() implies pod exists in API server
-	pf.isPodRunning = pod.Status.Phase == corev1.PodRunning
+	pf.isPodRunning = pod.Status.Phase == corev1.PodRunning && isServerContainerRunning(pod)
 	pf.dnsName = pod.Spec.Hostname + "." + pod.Spec.Subdomain
 	pf.podIP = pod.Status.PodIP
+	pf.image = pod.Spec.Containers[ServerContainerIndex].Image
 
 	// set pf.isInstalled and pf.hasStaleAdmintoolsConf
 	if err := p.checkIsInstalled(ctx, vdb, &pf); err != nil {
@@ -370,6 +379,29 @@ func (p *PodFacts) findPodToRunVsql() (*PodFact, bool) {
 	return &PodFact{}, false
 }
 
+// findPodToRunVsqlOutsideSubcluster returns the name of a pod with an up
+// node that isn't part of the given subcluster.  Will return false for
+// second parameter if no pod could be found.
+func (p *PodFacts) findPodToRunVsqlOutsideSubcluster(scName string) (*PodFact, bool) {
+	for _, v := range p.Detail {
+		if v.upNode && v.subcluster != scName {
+			return v, true
+		}
+	}
+	return &PodFact{}, false
+}
+
+// hasUpNodesInSubcluster returns true if any pod in the given subcluster has
+// an up node.
+func (p *PodFacts) hasUpNodesInSubcluster(scName string) bool {
+	for _, v := range p.Detail {
+		if v.upNode && v.subcluster == scName {
+			return true
+		}
+	}
+	return false
+}
+
 // findPodToRunAdmintools returns the name of the pod we will exec into into
 // order to run admintools
 // Will return false for second parameter if no pod could be found.
@@ -404,7 +436,7 @@ func (p *PodFacts) findRunningPod() (*PodFact, bool) {
 // An empty list implies there are no pods that need to be restarted.
 func (p *PodFacts) findRestartablePods() []*PodFact {
 	return p.filterPods(func(v *PodFact) bool {
-		return !v.upNode && v.dbExists.IsTrue() && v.isPodRunning
+		return !v.upNode && v.dbExists.IsTrue() && v.isPodRunning && !v.isShutdown
 	})
 }
 
@@ -468,3 +500,16 @@ func genPodNames(pods []*PodFact) string {
 	}
 	return strings.Join(podNames, ", ")
 }
+
+// isServerContainerRunning returns true if the server container in the pod is
+// running.  A pod with sidecars is in the running phase as long as one of its
+// containers is, so we check the server container itself.  If the pod doesn't
+// have a status for it yet, we go by the pod phase.
+func isServerContainerRunning(pod *corev1.Pod) bool {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == ServerContainer {
+			return pod.Status.ContainerStatuses[i].State.Running != nil
+		}
+	}
+	return true
+}
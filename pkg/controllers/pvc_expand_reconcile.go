@@ -0,0 +1,228 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The amount of time to wait before checking again on PVCs that are being
+// expanded
+const PVCExpandPollInterval = time.Second * 30
+
+// PVCExpandReconciler will expand the PVCs of each pod when local.requestSize
+// or local.depotRequestSize is increased.  The ObjReconciler recreates the
+// statefulset so that new PVCs get the new size.  This handles the PVCs that
+// already exist.
+type PVCExpandReconciler struct {
+	VRec *VerticaDBReconciler
+	Log  logr.Logger
+	Vdb  *vapi.VerticaDB // Vdb is the CRD we are acting on.
+}
+
+// MakePVCExpandReconciler will build a PVCExpandReconciler object
+func MakePVCExpandReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger, vdb *vapi.VerticaDB) ReconcileActor {
+	return &PVCExpandReconciler{VRec: vdbrecon, Log: log, Vdb: vdb}
+}
+
+// Reconcile will patch any PVC that is smaller than the size in the spec, and
+// track the PVCs that are being expanded in the status
+func (p *PVCExpandReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	resizes := []vapi.VolumeResizeStatus{}
+	tmpls := buildVolumeClaimTemplates(p.Vdb)
+	for i := range p.Vdb.Spec.Subclusters {
+		sc := &p.Vdb.Spec.Subclusters[i]
+		// The pods of a shutdown subcluster don't have their volumes mounted,
+		// so the file system can't be resized.  They are expanded once the
+		// subcluster is started again.
+		if sc.Shutdown {
+			continue
+		}
+		for podIndex := int32(0); podIndex < sc.Size; podIndex++ {
+			for j := range tmpls {
+				resize, err := p.expandPVC(ctx, sc, podIndex, &tmpls[j])
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				if resize != nil {
+					resizes = append(resizes, *resize)
+				}
+			}
+		}
+	}
+
+	if err := p.updateResizeStatus(ctx, resizes); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for i := range resizes {
+		if resizes[i].State != vapi.VolumeResizeNotSupported {
+			p.Log.Info("Waiting for PVCs to be expanded", "resizes", resizes)
+			return ctrl.Result{Requeue: true, RequeueAfter: PVCExpandPollInterval}, nil
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// expandPVC will increase the size of a single PVC if it is smaller than the
+// volumeClaimTemplate.  It returns the progress of the resize, or nil if the
+// PVC is already at the size we want.
+func (p *PVCExpandReconciler) expandPVC(ctx context.Context, sc *vapi.Subcluster, podIndex int32,
+	tmpl *corev1.PersistentVolumeClaim) (*vapi.VolumeResizeStatus, error) {
+	nm := names.GenPVCName(p.Vdb, sc, tmpl.Name, podIndex)
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := p.VRec.Client.Get(ctx, nm, pvc); err != nil {
+		// The statefulset creates a missing PVC with the size from the spec
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	expSize := tmpl.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+	resize := &vapi.VolumeResizeStatus{
+		PodName:       names.GenPodName(p.Vdb, sc, podIndex).Name,
+		ClaimName:     nm.Name,
+		RequestedSize: expSize.String(),
+		Capacity:      capacity.String(),
+		State:         vapi.VolumeResizeExpanding,
+	}
+
+	curSize := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if curSize.Cmp(expSize) < 0 {
+		allowed, err := isVolumeExpansionAllowed(ctx, p.VRec.Client, pvc)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			p.setResizeNotSupported(resize, "the storage class doesn't allow volume expansion")
+			return resize, nil
+		}
+		if err := p.patchPVCSize(ctx, pvc, expSize); err != nil {
+			// The API server rejects the new size if the storage class
+			// doesn't allow volume expansion.
+			if errors.IsForbidden(err) || errors.IsInvalid(err) {
+				p.setResizeNotSupported(resize, err.Error())
+				return resize, nil
+			}
+			return nil, err
+		}
+		p.Log.Info("Expanding PVC", "Name", nm, "From", curSize.String(), "To", expSize.String())
+		p.VRec.EVRec.Eventf(p.Vdb, corev1.EventTypeNormal, events.PVCExpansionStarted,
+			"Expanding PVC '%s' from %s to %s", nm.Name, curSize.String(), expSize.String())
+	}
+
+	if capacity.Cmp(expSize) >= 0 {
+		return nil, nil
+	}
+	for _, cond := range pvc.Status.Conditions {
+		if cond.Type == corev1.PersistentVolumeClaimFileSystemResizePending && cond.Status == corev1.ConditionTrue {
+			resize.State = vapi.VolumeResizeFileSystemPending
+		}
+	}
+	return resize, nil
+}
+
+// setResizeNotSupported will mark the resize as one that can't be done.  The
+// event is only written the first time.
+func (p *PVCExpandReconciler) setResizeNotSupported(resize *vapi.VolumeResizeStatus, reason string) {
+	if !p.isResizeNotSupported(resize.ClaimName) {
+		p.VRec.EVRec.Eventf(p.Vdb, corev1.EventTypeWarning, events.PVCExpansionNotSupported,
+			"Cannot expand PVC '%s' to %s: %s", resize.ClaimName, resize.RequestedSize, reason)
+	}
+	resize.State = vapi.VolumeResizeNotSupported
+}
+
+// isVolumeExpansionAllowed returns true if the storage class of the PVC allows
+// it to be expanded.  If the operator isn't allowed to read the storage class,
+// we leave it to the API server to reject the new size.
+func isVolumeExpansionAllowed(ctx context.Context, c client.Client, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	// A PVC can only be expanded through its storage class
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+	sc := &storagev1.StorageClass{}
+	if err := c.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, sc); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if errors.IsForbidden(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+}
+
+// patchPVCSize will change the requested storage of the PVC
+func (p *PVCExpandReconciler) patchPVCSize(ctx context.Context, pvc *corev1.PersistentVolumeClaim, size resource.Quantity) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	return p.VRec.Client.Patch(ctx, pvc, patch)
+}
+
+// isResizeNotSupported returns true if the status already shows that the
+// given PVC cannot be expanded.  This is used to write the event only once.
+func (p *PVCExpandReconciler) isResizeNotSupported(claimName string) bool {
+	for i := range p.Vdb.Status.VolumeResizes {
+		if p.Vdb.Status.VolumeResizes[i].ClaimName == claimName {
+			return p.Vdb.Status.VolumeResizes[i].State == vapi.VolumeResizeNotSupported
+		}
+	}
+	return false
+}
+
+// updateResizeStatus will set the progress of the resizes in the status.  An
+// event is written when the last resize finishes.
+func (p *PVCExpandReconciler) updateResizeStatus(ctx context.Context, resizes []vapi.VolumeResizeStatus) error {
+	if len(resizes) == 0 {
+		resizes = nil
+	}
+	if reflect.DeepEqual(p.Vdb.Status.VolumeResizes, resizes) {
+		return nil
+	}
+	wasResizing := len(p.Vdb.Status.VolumeResizes) > 0
+	if err := status.Update(ctx, p.VRec.Client, p.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.VolumeResizes = resizes
+		return nil
+	}); err != nil {
+		return err
+	}
+	if wasResizing && resizes == nil {
+		p.VRec.EVRec.Event(p.Vdb, corev1.EventTypeNormal, events.PVCExpansionSucceeded,
+			"All PVCs have been expanded")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	ExpandableStorageClass    = "expandable"
+	NonExpandableStorageClass = "non-expandable"
+)
+
+var _ = Describe("pvc_expand_reconcile", func() {
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		createStorageClass(ctx, ExpandableStorageClass, true)
+	})
+	AfterEach(func() {
+		deleteStorageClass(ctx, ExpandableStorageClass)
+	})
+
+	// createPVCs will create the local data PVC of each pod with the given
+	// requested size and capacity
+	createPVCs := func(vdb *vapi.VerticaDB, size, capacity string, conds []corev1.PersistentVolumeClaimCondition) {
+		sc := &vdb.Spec.Subclusters[0]
+		for i := int32(0); i < sc.Size; i++ {
+			nm := names.GenPVCName(vdb, sc, LocalDataPVC, i)
+			storageClass := ExpandableStorageClass
+			if vdb.Spec.Local.StorageClass != "" {
+				storageClass = vdb.Spec.Local.StorageClass
+			}
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: nm.Name, Namespace: nm.Namespace},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClass,
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+					},
+				},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Capacity:   corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+					Conditions: conds,
+				},
+			}
+			Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+		}
+	}
+	deletePVCs := func(vdb *vapi.VerticaDB) {
+		sc := &vdb.Spec.Subclusters[0]
+		for i := int32(0); i < sc.Size; i++ {
+			nm := names.GenPVCName(vdb, sc, LocalDataPVC, i)
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, nm, pvc)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, pvc)).Should(Succeed())
+		}
+	}
+
+	It("should expand the PVCs that are smaller than the spec", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Local.RequestSize = resource.MustParse("600Gi")
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPVCs(vdb, "500Gi", "500Gi", nil)
+		defer deletePVCs(vdb)
+
+		r := MakePVCExpandReconciler(vrec, logger, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true, RequeueAfter: PVCExpandPollInterval}))
+
+		sc := &vdb.Spec.Subclusters[0]
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, names.GenPVCName(vdb, sc, LocalDataPVC, 0), pvc)).Should(Succeed())
+		size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).Should(Equal("600Gi"))
+
+		Expect(len(vdb.Status.VolumeResizes)).Should(Equal(int(sc.Size)))
+		Expect(vdb.Status.VolumeResizes[0]).Should(Equal(vapi.VolumeResizeStatus{
+			PodName:       names.GenPodName(vdb, sc, 0).Name,
+			ClaimName:     names.GenPVCName(vdb, sc, LocalDataPVC, 0).Name,
+			RequestedSize: "600Gi",
+			Capacity:      "500Gi",
+			State:         vapi.VolumeResizeExpanding,
+		}))
+	})
+
+	It("should report a pending file system resize and clear the status when done", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Local.RequestSize = resource.MustParse("600Gi")
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPVCs(vdb, "600Gi", "500Gi", []corev1.PersistentVolumeClaimCondition{
+			{Type: corev1.PersistentVolumeClaimFileSystemResizePending, Status: corev1.ConditionTrue},
+		})
+		defer deletePVCs(vdb)
+
+		r := MakePVCExpandReconciler(vrec, logger, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true, RequeueAfter: PVCExpandPollInterval}))
+		sc := &vdb.Spec.Subclusters[0]
+		Expect(len(vdb.Status.VolumeResizes)).Should(Equal(int(sc.Size)))
+		Expect(vdb.Status.VolumeResizes[0].State).Should(Equal(vapi.VolumeResizeFileSystemPending))
+
+		// Once the file system is resized the capacity of the PVC is updated
+		for i := int32(0); i < sc.Size; i++ {
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, names.GenPVCName(vdb, sc, LocalDataPVC, i), pvc)).Should(Succeed())
+			pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("600Gi")
+			pvc.Status.Conditions = nil
+			Expect(k8sClient.Status().Update(ctx, pvc)).Should(Succeed())
+		}
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vdb.Status.VolumeResizes).Should(BeNil())
+	})
+
+	It("should not patch the PVCs if the storage class doesn't allow expansion", func() {
+		createStorageClass(ctx, NonExpandableStorageClass, false)
+		defer deleteStorageClass(ctx, NonExpandableStorageClass)
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Local.StorageClass = NonExpandableStorageClass
+		vdb.Spec.Local.RequestSize = resource.MustParse("600Gi")
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPVCs(vdb, "500Gi", "500Gi", nil)
+		defer deletePVCs(vdb)
+
+		r := MakePVCExpandReconciler(vrec, logger, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		sc := &vdb.Spec.Subclusters[0]
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, names.GenPVCName(vdb, sc, LocalDataPVC, 0), pvc)).Should(Succeed())
+		size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).Should(Equal("500Gi"))
+		Expect(vdb.Status.VolumeResizes[0].State).Should(Equal(vapi.VolumeResizeNotSupported))
+	})
+
+	It("should skip the PVCs of a subcluster that is shutdown", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Local.RequestSize = resource.MustParse("600Gi")
+		vdb.Spec.Subclusters[0].Shutdown = true
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPVCs(vdb, "500Gi", "500Gi", nil)
+		defer deletePVCs(vdb)
+
+		r := MakePVCExpandReconciler(vrec, logger, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, names.GenPVCName(vdb, &vdb.Spec.Subclusters[0], LocalDataPVC, 0), pvc)).Should(Succeed())
+		size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).Should(Equal("500Gi"))
+	})
+})
+
+// createStorageClass will create a storage class that may allow volume
+// expansion
+func createStorageClass(ctx context.Context, name string, allowExpansion bool) {
+	sc := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: name},
+		Provisioner:          "kubernetes.io/no-provisioner",
+		AllowVolumeExpansion: &allowExpansion,
+	}
+	ExpectWithOffset(1, k8sClient.Create(ctx, sc)).Should(Succeed())
+}
+
+// deleteStorageClass will delete a storage class made with createStorageClass
+func deleteStorageClass(ctx context.Context, name string) {
+	sc := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	ExpectWithOffset(1, k8sClient.Delete(ctx, sc)).Should(Succeed())
+}
@@ -0,0 +1,82 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// RefreshCurrentSizeReconciler will update the status of a VerticaAutoscaler
+// with the current size and pod selector of the subclusters it targets
+type RefreshCurrentSizeReconciler struct {
+	VRec *VerticaAutoscalerReconciler
+	Log  logr.Logger
+	Vas  *vapi.VerticaAutoscaler
+	Vdb  *vapi.VerticaDB
+}
+
+// MakeRefreshCurrentSizeReconciler will build a RefreshCurrentSizeReconciler object
+func MakeRefreshCurrentSizeReconciler(r *VerticaAutoscalerReconciler, log logr.Logger,
+	vas *vapi.VerticaAutoscaler, vdb *vapi.VerticaDB) ReconcileActor {
+	return &RefreshCurrentSizeReconciler{VRec: r, Log: log, Vas: vas, Vdb: vdb}
+}
+
+// Reconcile will set the size and selector in the VerticaAutoscaler status
+func (r *RefreshCurrentSizeReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	var size int32
+	scNames := []string{}
+	for i := range r.Vdb.Spec.Subclusters {
+		sc := &r.Vdb.Spec.Subclusters[i]
+		if !r.Vas.IsSubclusterInGroup(sc.Name) {
+			continue
+		}
+		if r.Vas.Spec.ScalingGranularity == vapi.SubclusterScalingGranularity && sc.IsPrimary {
+			continue
+		}
+		size += sc.Size
+		scNames = append(scNames, sc.Name)
+	}
+
+	return ctrl.Result{}, status.UpdateVerticaAutoscaler(ctx, r.VRec.Client, r.Vas,
+		func(vas *vapi.VerticaAutoscaler) error {
+			vas.Status.Size = size
+			vas.Status.Selector = r.makeSelector(scNames)
+			return nil
+		})
+}
+
+// makeSelector returns the label selector, in string form, for the pods of the
+// given subclusters
+func (r *RefreshCurrentSizeReconciler) makeSelector(scNames []string) string {
+	// An empty set isn't a valid selector, so we fall back to the name in the
+	// spec.  It won't match any pods.
+	if len(scNames) == 0 {
+		if r.Vas.Spec.ScalingGranularity == vapi.SubclusterScalingGranularity {
+			scNames = []string{r.Vas.Spec.Template.Name}
+		} else {
+			scNames = []string{r.Vas.Spec.SubclusterName}
+		}
+	}
+	return fmt.Sprintf("app.kubernetes.io/instance=%s,%s in (%s)",
+		r.Vdb.Name, SubclusterLabel, strings.Join(scNames, ","))
+}
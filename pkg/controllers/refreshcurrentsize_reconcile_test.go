@@ -0,0 +1,60 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("refreshcurrentsize_reconcile", func() {
+	ctx := context.Background()
+
+	It("should set the size and selector of a single subcluster", func() {
+		vdb := vapi.MakeVDB()
+		vas := vapi.MakeVAS()
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeRefreshCurrentSizeReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vas.Status.Size).Should(Equal(vdb.Spec.Subclusters[0].Size))
+		Expect(vas.Status.Selector).Should(Equal("app.kubernetes.io/instance=" + vdb.Name +
+			",vertica.com/subcluster in (" + vdb.Spec.Subclusters[0].Name + ")"))
+	})
+
+	It("should sum the sizes of the subclusters in the group", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters,
+			vapi.Subcluster{Name: "as-0", Size: 4},
+			vapi.Subcluster{Name: "other", Size: 2},
+			vapi.Subcluster{Name: "as-1", Size: 3},
+		)
+		vas := makeSubclusterVAS()
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeRefreshCurrentSizeReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vas.Status.Size).Should(Equal(int32(7)))
+		Expect(vas.Status.Selector).Should(Equal("app.kubernetes.io/instance=" + vdb.Name +
+			",vertica.com/subcluster in (as-0,as-1)"))
+	})
+})
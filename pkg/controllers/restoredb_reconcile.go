@@ -0,0 +1,212 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// RestoreDBReconciler will initialize a database from a vbr restore point if
+// one doesn't exist in the vdb yet.  vbr can only restore into an existing
+// database, so an empty one is created first with create_db.  It is then
+// stopped and the restore point is restored into it with 'vbr -t restore'.
+type RestoreDBReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+	// The create_db logic is reused to create the empty database that we
+	// restore into.
+	Create *CreateDBReconciler
+	VBR    *VBRRunner
+}
+
+// MakeRestoreDBReconciler will build a RestoreDBReconciler object
+func MakeRestoreDBReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &RestoreDBReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts,
+		Create: &CreateDBReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts},
+		// vbr does not connect to the database for a restore, so it doesn't
+		// need the superuser password.
+		VBR: MakeVBRRunner(vdbrecon.Client, vdbrecon.EVRec, log, vdb, &vdb.Spec.RestorePoint.Location,
+			prunner, vdb, "", 0),
+	}
+}
+
+// Reconcile will ensure a DB exists and restore one if it doesn't
+func (r *RestoreDBReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	// Skip this reconciler entirely if the init policy is not to restore the DB.
+	if r.Vdb.Spec.InitPolicy != vapi.CommunalInitPolicyRestore {
+		return ctrl.Result{}, nil
+	}
+
+	// A prior reconcile may have created the database but failed before the
+	// restore completed.  We finish the restore before anything else.
+	if res, err := r.resumeRestore(ctx); err != nil || res.Requeue {
+		return res, err
+	}
+
+	// The remaining logic is driven from GenericDatabaseInitializer.  This
+	// exists to create an abstraction that is common with create_db and
+	// revive_db.
+	g := GenericDatabaseInitializer{
+		initializer: r,
+		VRec:        r.VRec,
+		Log:         r.Log,
+		Vdb:         r.Vdb,
+		PRunner:     r.PRunner,
+		PFacts:      r.PFacts,
+	}
+	return g.checkAndRunInit(ctx)
+}
+
+// resumeRestore will restore into a database that exists but was never
+// marked as initialized.  This is a no-op if there is no such database.
+func (r *RestoreDBReconciler) resumeRestore(ctx context.Context) (ctrl.Result, error) {
+	if isSet, err := r.Vdb.IsConditionSet(vapi.DBInitialized); err != nil || isSet {
+		return ctrl.Result{}, err
+	}
+	if err := r.PFacts.Collect(ctx, r.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !r.PFacts.doesDBExist().IsTrue() {
+		return ctrl.Result{}, nil
+	}
+
+	atPod, ok := r.PFacts.findPodToRunAdmintools()
+	if !ok {
+		r.Log.Info("No pod found to run vbr from. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	r.Log.Info("Resuming restore into the database that was previously created")
+	if res, err := r.restoreDB(ctx, atPod.name); err != nil || res.Requeue {
+		return res, err
+	}
+
+	cond := vapi.VerticaDBCondition{Type: vapi.DBInitialized, Status: corev1.ConditionTrue}
+	if err := status.UpdateCondition(ctx, r.VRec.Client, r.Vdb, cond); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.PFacts.Invalidate()
+	return ctrl.Result{}, nil
+}
+
+// execCmd will create the empty database with the given create_db command,
+// then restore into it.  This handles logging of necessary events.
+func (r *RestoreDBReconciler) execCmd(ctx context.Context, atPod types.NamespacedName, cmd []string) (ctrl.Result, error) {
+	if res, err := r.Create.execCmd(ctx, atPod, cmd); err != nil || res.Requeue {
+		return res, err
+	}
+	return r.restoreDB(ctx, atPod)
+}
+
+// restoreDB will stop the database and restore the restore point into it.
+// The database is left down; the restart reconciler will start it.
+func (r *RestoreDBReconciler) restoreDB(ctx context.Context, atPod types.NamespacedName) (ctrl.Result, error) {
+	r.stopDB(ctx, atPod)
+
+	if res, err := r.VBR.Setup(ctx, atPod); err != nil || res.Requeue {
+		return res, err
+	}
+	defer func() {
+		if err := r.VBR.Cleanup(ctx, atPod); err != nil {
+			r.Log.Info("failed to cleanup vbr files, ignoring failure", "err", err)
+		}
+	}()
+
+	archive, res, err := r.getArchive(ctx, atPod)
+	if err != nil || res.Requeue {
+		return res, err
+	}
+
+	r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeNormal, events.RestoreDBStart,
+		"Calling 'vbr -t restore' for restore point '%s'", archive)
+	start := time.Now()
+	if _, err := r.VBR.RunTask(ctx, atPod, "restore", "--archive="+archive); err != nil {
+		r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, events.RestoreDBFailed,
+			"Failed to restore the database from restore point '%s'", archive)
+		return ctrl.Result{}, err
+	}
+	r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeNormal, events.RestoreDBSucceeded,
+		"Successfully restored database from restore point '%s'. It took %s", archive, time.Since(start))
+	return ctrl.Result{}, nil
+}
+
+// stopDB will stop the database with 'admintools -t stop_db'.  vbr requires
+// the database to be down when it restores.  The database may already be
+// down from a prior attempt, so a failure is only logged.  vbr will fail if it
+// really is still up.
+func (r *RestoreDBReconciler) stopDB(ctx context.Context, atPod types.NamespacedName) {
+	cmd := []string{
+		"-t", "stop_db",
+		"--database=" + r.Vdb.Spec.DBName,
+		"--force",
+	}
+	if _, _, err := r.PRunner.ExecAdmintools(ctx, atPod, ServerContainer, cmd...); err != nil {
+		r.Log.Info("stop_db failed, continuing with restore", "err", err)
+	}
+}
+
+// getArchive returns the ID of the restore point to restore.  If one isn't
+// given in the spec, the newest restore point in the location is used.
+func (r *RestoreDBReconciler) getArchive(ctx context.Context, atPod types.NamespacedName) (string, ctrl.Result, error) {
+	if r.Vdb.Spec.RestorePoint.Archive != "" {
+		return r.Vdb.Spec.RestorePoint.Archive, ctrl.Result{}, nil
+	}
+	rps, err := r.VBR.ListRestorePoints(ctx, atPod)
+	if err != nil {
+		return "", ctrl.Result{}, err
+	}
+	if len(rps) == 0 {
+		r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, events.RestorePointNotFound,
+			"Could not find any restore points for snapshot '%s' in '%s'",
+			r.VBR.getSnapshotName(), r.Vdb.Spec.RestorePoint.Location.Path)
+		return "", ctrl.Result{Requeue: true}, nil
+	}
+	return rps[0].ID, ctrl.Result{}, nil
+}
+
+// preCmdSetup will generate the file we include with the create_db
+func (r *RestoreDBReconciler) preCmdSetup(ctx context.Context, atPod types.NamespacedName) error {
+	return r.Create.preCmdSetup(ctx, atPod)
+}
+
+// getAdditionalAuthParms returns additional auth parms that we need to set for create_db
+func (r *RestoreDBReconciler) getAdditionalAuthParms() string {
+	return r.Create.getAdditionalAuthParms()
+}
+
+// getPodList gets a list of all of the pods we are going to use with
+// create_db.  The node count of the restore point must match this list.
+func (r *RestoreDBReconciler) getPodList() ([]*PodFact, bool) {
+	return r.Create.getPodList()
+}
+
+// genCmd will return the command to run in the pod to create the empty database
+func (r *RestoreDBReconciler) genCmd(hostList []string) []string {
+	return r.Create.genCmd(hostList)
+}
@@ -0,0 +1,138 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("restoredb_reconcile", func() {
+	ctx := context.Background()
+
+	It("should skip reconciler entirely if initPolicy is not Restore", func() {
+		vdb := vapi.MakeVDB()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeRestoreDBReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(0))
+	})
+
+	It("should create the db then restore into it if db doesn't exist", func() {
+		vdb := makeRestoreVdb()
+		vdb.Spec.RestorePoint.Archive = "20210705_100012"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := createPodFactsWithNoDB(ctx, vdb, fpr, 1)
+		r := MakeRestoreDBReconciler(vrec, logger, vdb, fpr, pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("/opt/vertica/bin/admintools -t create_db"))).Should(Equal(1))
+		Expect(len(fpr.FindCommands("/opt/vertica/bin/admintools -t stop_db"))).Should(Equal(1))
+		hist := fpr.FindCommands(VbrPath, "-t", "restore")
+		Expect(len(hist)).Should(Equal(1))
+		Expect(hist[0].Command[2]).Should(ContainSubstring("--archive=20210705_100012"))
+		Expect(vdb.IsConditionSet(vapi.DBInitialized)).Should(BeTrue())
+	})
+
+	It("should resume the restore if the db exists but was never initialized", func() {
+		vdb := makeRestoreVdb()
+		vdb.Spec.RestorePoint.Archive = "20210705_100012"
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeRestoreDBReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("/opt/vertica/bin/admintools -t create_db"))).Should(Equal(0))
+		Expect(len(fpr.FindCommands(VbrPath, "-t", "restore"))).Should(Equal(1))
+		Expect(vdb.IsConditionSet(vapi.DBInitialized)).Should(BeTrue())
+
+		// Once initialized, nothing more is done
+		fpr.Histories = []cmds.CmdHistory{}
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+
+	It("should not restore if the db was already initialized", func() {
+		vdb := makeRestoreVdb()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		cond := vapi.VerticaDBCondition{Type: vapi.DBInitialized, Status: corev1.ConditionTrue}
+		Expect(status.UpdateCondition(ctx, k8sClient, vdb, cond)).Should(Succeed())
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeRestoreDBReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands(VbrPath))).Should(Equal(0))
+	})
+
+	It("should pick the newest restore point if no archive is given", func() {
+		vdb := makeRestoreVdb()
+		atPod := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+
+		fpr := &cmds.FakePodRunner{Results: cmds.CmdResults{
+			atPod: []cmds.CmdResult{
+				{Stdout: "backup  backup_type   epoch\n" +
+					"backup_20210705_090012  full  120\n" +
+					"backup_20210705_100012  full  130\n"},
+				{Stdout: "backup  backup_type   epoch\n"},
+			},
+		}}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		act := MakeRestoreDBReconciler(vrec, logger, vdb, fpr, &pfacts)
+		r := act.(*RestoreDBReconciler)
+		Expect(r.getArchive(ctx, atPod)).Should(Equal("20210705_100012"))
+
+		// No restore points, so we requeue
+		_, res, err := r.getArchive(ctx, atPod)
+		Expect(err).Should(Succeed())
+		Expect(res).Should(Equal(ctrl.Result{Requeue: true}))
+	})
+})
+
+// makeRestoreVdb will make a vdb that initializes from a restore point.  It
+// has a single pod so that the pod that commands run in is deterministic.
+func makeRestoreVdb() *vapi.VerticaDB {
+	vdb := vapi.MakeVDB()
+	vdb.Spec.InitPolicy = vapi.CommunalInitPolicyRestore
+	vdb.Spec.RestorePoint.Location.Path = "s3://nimbusdb/backups"
+	vdb.Spec.Subclusters[0].Size = 1
+	return vdb
+}
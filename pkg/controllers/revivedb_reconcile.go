@@ -84,13 +84,19 @@ func (r *ReviveDBReconciler) execCmd(ctx context.Context, atPod types.Namespaced
 			return ctrl.Result{Requeue: true}, nil
 
 		case isBucketNotExistError(stdout):
-			r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, events.S3BucketDoesNotExist,
-				"The bucket in the S3 path '%s' does not exist", paths.GetCommunalPath(r.Vdb))
+			r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, getBucketNotExistReason(r.Vdb),
+				"The bucket in the communal path '%s' does not exist", paths.GetCommunalPath(r.Vdb))
 			return ctrl.Result{Requeue: true}, nil
 
 		case isEndpointBadError(stdout):
-			r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, events.S3EndpointIssue,
-				"Unable to connect to S3 endpoint '%s'", r.Vdb.Spec.Communal.Endpoint)
+			r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, getEndpointIssueReason(r.Vdb),
+				"Unable to connect to the communal endpoint '%s'", r.Vdb.Spec.Communal.Endpoint)
+			return ctrl.Result{Requeue: true}, nil
+
+		case isGCSAuthError(stdout):
+			r.VRec.EVRec.Eventf(r.Vdb, corev1.EventTypeWarning, events.GCSAuthFailed,
+				"The HMAC key in the communal credential secret '%s' was rejected by Google Cloud Storage",
+				r.Vdb.Spec.Communal.CredentialSecret)
 			return ctrl.Result{Requeue: true}, nil
 
 		case isDatabaseNotFound(stdout):
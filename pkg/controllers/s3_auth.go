@@ -33,3 +33,9 @@ func isEndpointBadError(op string) bool {
 func isBucketNotExistError(op string) bool {
 	return strings.Contains(op, "The specified bucket does not exist")
 }
+
+// isGCSAuthError returns true if the given message text has the message
+// about Google Cloud Storage rejecting the HMAC key
+func isGCSAuthError(op string) bool {
+	return strings.Contains(op, "Check your Google secret key")
+}
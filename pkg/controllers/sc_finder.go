@@ -22,6 +22,7 @@ import (
 	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -74,6 +75,17 @@ func (m *SubclusterFinder) FindServices(ctx context.Context, flags FindFlags) (*
 	return svcs, nil
 }
 
+// FindPDBs returns the pod disruption budgets that were created by the
+// operator.  The flags limit it to those for subclusters in the vdb, those
+// that aren't or all of them.
+func (m *SubclusterFinder) FindPDBs(ctx context.Context, flags FindFlags) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	pdbs := &policyv1beta1.PodDisruptionBudgetList{}
+	if err := m.buildObjList(ctx, pdbs, flags); err != nil {
+		return nil, err
+	}
+	return pdbs, nil
+}
+
 // FindSubclusters will return a list of subclusters.
 // It accepts a flags field to indicate whether to return subclusters in the vdb,
 // not in the vdb or both.
@@ -168,6 +180,8 @@ func getLabelsFromObject(obj runtime.Object) (map[string]string, bool) {
 		return sts.Labels, true
 	} else if svc, ok := obj.(*corev1.Service); ok {
 		return svc.Labels, true
+	} else if pdb, ok := obj.(*policyv1beta1.PodDisruptionBudget); ok {
+		return pdb.Labels, true
 	}
 	return nil, false
 }
@@ -0,0 +1,221 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// The restore point limit we give vbr for backups created by a schedule
+	// that doesn't keep a fixed number of restore points.  vbr would
+	// otherwise remove restore points before our retention does.
+	ScheduledRestorePointLimit = 1000
+	// The most scheduled times we will walk through when catching up on
+	// missed backups.  This bounds the work if the operator was down for a
+	// long time with a frequent schedule.
+	maxMissedRuns = 10000
+)
+
+// ScheduledBackupReconciler will create a VerticaBackup each time the cron
+// schedule of a VerticaBackupSchedule comes due.
+type ScheduledBackupReconciler struct {
+	VRec *VerticaBackupScheduleReconciler
+	Log  logr.Logger
+	Vbs  *vapi.VerticaBackupSchedule // Vbs is the CRD we are acting on.
+	// The current time.  Stored so that all decisions in a reconcile use the
+	// same time.
+	Now time.Time
+}
+
+// MakeScheduledBackupReconciler will build a ScheduledBackupReconciler object
+func MakeScheduledBackupReconciler(vbsrecon *VerticaBackupScheduleReconciler, log logr.Logger,
+	vbs *vapi.VerticaBackupSchedule) ReconcileActor {
+	return &ScheduledBackupReconciler{VRec: vbsrecon, Log: log, Vbs: vbs, Now: time.Now().UTC()}
+}
+
+// Reconcile will create a backup if one is due.  It returns a result that
+// requeues at the next scheduled time.
+func (s *ScheduledBackupReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if s.Vbs.Spec.Suspend {
+		return ctrl.Result{}, nil
+	}
+
+	sched, err := cron.ParseStandard(s.Vbs.Spec.Schedule)
+	if err != nil {
+		s.VRec.EVRec.Eventf(s.Vbs, corev1.EventTypeWarning, events.InvalidBackupSchedule,
+			"The schedule '%s' is not valid: %s", s.Vbs.Spec.Schedule, err)
+		// No need to requeue.  We will be called again when the schedule is fixed.
+		return ctrl.Result{}, nil
+	}
+
+	latest, missed := s.findScheduledTimes(sched)
+	if !latest.IsZero() {
+		if err := s.runScheduledBackup(ctx, latest, missed); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	next := sched.Next(s.Now)
+	if next.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	s.Log.Info("Next backup is scheduled", "time", next)
+	return ctrl.Result{RequeueAfter: next.Sub(s.Now)}, nil
+}
+
+// findScheduledTimes will find the scheduled times that have passed since
+// the last one we acted on.  It returns the most recent of them, and a count
+// of the older ones, which were missed.  The zero time is returned if no
+// backup is due.
+func (s *ScheduledBackupReconciler) findScheduledTimes(sched cron.Schedule) (latest time.Time, missed int) {
+	earliest := s.Vbs.CreationTimestamp.Time
+	if s.Vbs.Status.LastScheduleTime != nil {
+		earliest = s.Vbs.Status.LastScheduleTime.Time
+	}
+	if earliest.IsZero() {
+		return time.Time{}, 0
+	}
+	// The schedule is evaluated in the location of the time passed to it
+	earliest = earliest.UTC()
+
+	for t := sched.Next(earliest); !t.IsZero() && !t.After(s.Now); t = sched.Next(t) {
+		if !latest.IsZero() {
+			missed++
+		}
+		latest = t
+		if missed >= maxMissedRuns {
+			s.Log.Info("Too many missed backups to count. Skipping ahead.", "missed", missed)
+			break
+		}
+	}
+	return latest, missed
+}
+
+// runScheduledBackup will create the VerticaBackup for the given scheduled
+// time.  The backup is skipped if it is too late to start it, or if the prior
+// backup is still running.  In either case it is counted as missed.  A single
+// warning is written with the count of all of the backups that were missed.
+func (s *ScheduledBackupReconciler) runScheduledBackup(ctx context.Context, scheduled time.Time, missed int) error {
+	reason, err := s.getReasonToSkip(ctx, scheduled)
+	if err != nil {
+		return err
+	}
+
+	backupName := ""
+	if reason != "" {
+		missed++
+		s.VRec.EVRec.Eventf(s.Vbs, corev1.EventTypeWarning, events.BackupScheduleMissed,
+			"Missed %d scheduled backup(s) up to %s. The last one was missed because %s",
+			missed, scheduled.Format(time.RFC3339), reason)
+	} else {
+		if missed > 0 {
+			s.VRec.EVRec.Eventf(s.Vbs, corev1.EventTypeWarning, events.BackupScheduleMissed,
+				"Missed %d scheduled backup(s) prior to %s", missed, scheduled.Format(time.RFC3339))
+		}
+		if backupName, err = s.createBackup(ctx, scheduled); err != nil {
+			return err
+		}
+	}
+
+	return status.UpdateBackupSchedule(ctx, s.VRec.Client, s.Vbs, func(vbs *vapi.VerticaBackupSchedule) error {
+		vbs.Status.LastScheduleTime = &metav1.Time{Time: scheduled}
+		vbs.Status.MissedCount += missed
+		if backupName != "" {
+			vbs.Status.LastBackupName = backupName
+			vbs.Status.LastBackupPhase = ""
+		}
+		return nil
+	})
+}
+
+// getReasonToSkip will return a non-empty reason if the backup for the given
+// scheduled time cannot be started.
+func (s *ScheduledBackupReconciler) getReasonToSkip(ctx context.Context, scheduled time.Time) (string, error) {
+	deadline := time.Duration(s.Vbs.Spec.StartingDeadlineSeconds) * time.Second
+	if deadline > 0 && s.Now.Sub(scheduled) > deadline {
+		return fmt.Sprintf("it could not be started within %d seconds of its scheduled time",
+			s.Vbs.Spec.StartingDeadlineSeconds), nil
+	}
+
+	if s.Vbs.Status.LastBackupName == "" {
+		return "", nil
+	}
+	vb := &vapi.VerticaBackup{}
+	nm := types.NamespacedName{Namespace: s.Vbs.Namespace, Name: s.Vbs.Status.LastBackupName}
+	if err := s.VRec.Client.Get(ctx, nm, vb); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if !vb.IsComplete() {
+		return fmt.Sprintf("the prior backup '%s' is still running", vb.Name), nil
+	}
+	return "", nil
+}
+
+// createBackup will create the VerticaBackup for the scheduled time.  The
+// name is derived from the scheduled time, so this is idempotent.
+func (s *ScheduledBackupReconciler) createBackup(ctx context.Context, scheduled time.Time) (string, error) {
+	vb := &vapi.VerticaBackup{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vapi.VerticaDBAPIVersion,
+			Kind:       vapi.VerticaBackupKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", s.Vbs.Name, scheduled.Unix()),
+			Namespace: s.Vbs.Namespace,
+			Labels:    map[string]string{BackupScheduleLabel: s.Vbs.Name},
+		},
+		Spec: vapi.VerticaBackupSpec{
+			VerticaDBName:     s.Vbs.Spec.VerticaDBName,
+			Location:          s.Vbs.Spec.Location,
+			RestorePointLimit: s.getRestorePointLimit(),
+		},
+	}
+	if err := ctrl.SetControllerReference(s.Vbs, vb, s.VRec.Scheme); err != nil {
+		return "", err
+	}
+	if err := s.VRec.Client.Create(ctx, vb); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	s.Log.Info("Created scheduled backup", "name", vb.Name, "scheduled", scheduled)
+	return vb.Name, nil
+}
+
+// getRestorePointLimit returns the restore point limit to give vbr.  If the
+// retention has a count we let vbr enforce it too.  Otherwise the limit is set
+// high so that the retention of the schedule decides what is removed.
+func (s *ScheduledBackupReconciler) getRestorePointLimit() int {
+	if s.Vbs.Spec.Retention.KeepLast > 0 {
+		return s.Vbs.Spec.Retention.KeepLast
+	}
+	return ScheduledRestorePointLimit
+}
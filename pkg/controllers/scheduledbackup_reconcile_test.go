@@ -0,0 +1,172 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("scheduledbackup_reconcile", func() {
+	ctx := context.Background()
+	lastSchedule := time.Date(2021, 7, 5, 10, 0, 0, 0, time.UTC)
+
+	It("should create a backup when the schedule comes due", func() {
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Schedule = "@hourly"
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+
+		r := makeScheduledBackupReconciler(vbs, lastSchedule.Add(65*time.Minute))
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{RequeueAfter: 55 * time.Minute}))
+
+		expName := fmt.Sprintf("%s-%d", vbs.Name, lastSchedule.Add(time.Hour).Unix())
+		Expect(vbs.Status.LastBackupName).Should(Equal(expName))
+		Expect(vbs.Status.LastScheduleTime.Time.Equal(lastSchedule.Add(time.Hour))).Should(BeTrue())
+		Expect(vbs.Status.MissedCount).Should(Equal(0))
+
+		vb := &vapi.VerticaBackup{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: vbs.Namespace, Name: expName}, vb)).Should(Succeed())
+		defer deleteVBackup(ctx, vb)
+		Expect(vb.Labels[BackupScheduleLabel]).Should(Equal(vbs.Name))
+		Expect(vb.Spec.Location).Should(Equal(vbs.Spec.Location))
+		Expect(vb.Spec.RestorePointLimit).Should(Equal(ScheduledRestorePointLimit))
+	})
+
+	It("should not create a backup before the schedule comes due", func() {
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Schedule = "@hourly"
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+
+		r := makeScheduledBackupReconciler(vbs, lastSchedule.Add(30*time.Minute))
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{RequeueAfter: 30 * time.Minute}))
+		Expect(vbs.Status.LastBackupName).Should(Equal(""))
+	})
+
+	It("should count a backup as missed if the deadline has passed", func() {
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Schedule = "@hourly"
+		vbs.Spec.StartingDeadlineSeconds = 600
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+
+		// Three hours later, so two runs were missed before we even look at
+		// the deadline of the most recent one.
+		r := makeScheduledBackupReconciler(vbs, lastSchedule.Add(3*time.Hour+15*time.Minute))
+		evrec := record.NewFakeRecorder(10)
+		r.VRec.EVRec = evrec
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{RequeueAfter: 45 * time.Minute}))
+		Expect(vbs.Status.LastBackupName).Should(Equal(""))
+		Expect(vbs.Status.MissedCount).Should(Equal(3))
+		Expect(vbs.Status.LastScheduleTime.Time.Equal(lastSchedule.Add(3 * time.Hour))).Should(BeTrue())
+		// All of the missed backups are reported in one warning
+		Expect(evrec.Events).Should(HaveLen(1))
+		Expect(<-evrec.Events).Should(ContainSubstring("Missed 3 scheduled backup(s)"))
+	})
+
+	It("should count a backup as missed if the prior backup is still running", func() {
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Schedule = "@hourly"
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+		vb := vapi.MakeVBackup()
+		createVBackup(ctx, vb)
+		defer deleteVBackup(ctx, vb)
+		Expect(status.UpdateBackupSchedule(ctx, k8sClient, vbs, func(v *vapi.VerticaBackupSchedule) error {
+			v.Status.LastBackupName = vb.Name
+			return nil
+		})).Should(Succeed())
+
+		r := makeScheduledBackupReconciler(vbs, lastSchedule.Add(61*time.Minute))
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{RequeueAfter: 59 * time.Minute}))
+		Expect(vbs.Status.LastBackupName).Should(Equal(vb.Name))
+		Expect(vbs.Status.MissedCount).Should(Equal(1))
+	})
+
+	It("should do nothing if the schedule is suspended or invalid", func() {
+		vbs := vapi.MakeVBackupSchedule()
+		vbs.Spec.Schedule = "@hourly"
+		vbs.Spec.Suspend = true
+		createVBackupSchedule(ctx, vbs, lastSchedule)
+		defer deleteVBackupSchedule(ctx, vbs)
+
+		r := makeScheduledBackupReconciler(vbs, lastSchedule.Add(61*time.Minute))
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vbs.Status.LastBackupName).Should(Equal(""))
+
+		vbs.Spec.Suspend = false
+		vbs.Spec.Schedule = "61 * * * *"
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vbs.Status.LastBackupName).Should(Equal(""))
+	})
+})
+
+// makeVBackupScheduleReconciler will build a VerticaBackupScheduleReconciler
+// that shares the client and event recorder of the VerticaDB reconciler used
+// in the tests.
+func makeVBackupScheduleReconciler() *VerticaBackupScheduleReconciler {
+	return &VerticaBackupScheduleReconciler{
+		Client: vrec.Client,
+		Log:    vrec.Log,
+		Scheme: vrec.Scheme,
+		Cfg:    vrec.Cfg,
+		EVRec:  vrec.EVRec,
+	}
+}
+
+// makeScheduledBackupReconciler will build the actor with a fixed current time
+func makeScheduledBackupReconciler(vbs *vapi.VerticaBackupSchedule, now time.Time) *ScheduledBackupReconciler {
+	act := MakeScheduledBackupReconciler(makeVBackupScheduleReconciler(), logger, vbs)
+	r := act.(*ScheduledBackupReconciler)
+	r.Now = now
+	return r
+}
+
+// createVBackupSchedule will create the schedule and set the last schedule
+// time in its status.
+func createVBackupSchedule(ctx context.Context, vbs *vapi.VerticaBackupSchedule, lastSchedule time.Time) {
+	ExpectWithOffset(1, k8sClient.Create(ctx, vbs)).Should(Succeed())
+	ExpectWithOffset(1, status.UpdateBackupSchedule(ctx, k8sClient, vbs, func(v *vapi.VerticaBackupSchedule) error {
+		v.Status.LastScheduleTime = &metav1.Time{Time: lastSchedule}
+		return nil
+	})).Should(Succeed())
+}
+
+func deleteVBackupSchedule(ctx context.Context, vbs *vapi.VerticaBackupSchedule) {
+	ExpectWithOffset(1, k8sClient.Delete(ctx, vbs)).Should(Succeed())
+}
+
+// deleteVBackupIfExists will delete a backup, ignoring it if it is missing
+func deleteVBackupIfExists(ctx context.Context, nm types.NamespacedName) {
+	vb := &vapi.VerticaBackup{}
+	if err := k8sClient.Get(ctx, nm, vb); err != nil {
+		ExpectWithOffset(1, kerrors.IsNotFound(err)).Should(BeTrue())
+		return
+	}
+	ExpectWithOffset(1, k8sClient.Delete(ctx, vb)).Should(Succeed())
+}
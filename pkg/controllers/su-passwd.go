@@ -15,7 +15,98 @@
 
 package controllers
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
 const (
 	// The name of the key in the superuser password secret that holds the password
 	SuperuserPasswordKey = "password"
+	// The name of the key in the secret with the applied superuser password
+	// that holds a password we are about to set in the database
+	SuperuserPasswordPendingKey = "pending-password"
 )
+
+// getSuperuserPassword returns the superuser password if it has been provided.
+// This is shared by all of the controllers that need to run commands against
+// the database of a vdb.
+func getSuperuserPassword(ctx context.Context, clnt client.Client, evrec record.EventRecorder, log logr.Logger,
+	vdb *vapi.VerticaDB) (string, error) {
+	secret := &corev1.Secret{}
+	passwd := ""
+	secretName := names.GenSUPasswdSecretName(vdb)
+	if secretName.Name == "" {
+		return passwd, nil
+	}
+	err := clnt.Get(ctx, secretName, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			evrec.Eventf(vdb, corev1.EventTypeWarning, events.SuperuserPasswordSecretNotFound,
+				"Secret for superuser password '%s' was not found", secretName.Name)
+		}
+		return passwd, err
+	}
+	pwd, ok := secret.Data[SuperuserPasswordKey]
+	if ok {
+		passwd = string(pwd)
+	} else {
+		log.Error(err, fmt.Sprintf("password not found, secret must have a key with name '%s'", SuperuserPasswordKey))
+	}
+	return passwd, nil
+}
+
+// getAppliedSuperuserPassword returns the superuser password that was last
+// set in the database.  The operator keeps a copy of it in its own secret so
+// that it can still connect after the user's secret has changed.  The bool
+// return is false if no copy has been made yet.
+func getAppliedSuperuserPassword(ctx context.Context, clnt client.Client, vdb *vapi.VerticaDB) (string, bool, error) {
+	if vdb.Spec.SuperuserPasswordSecret == "" {
+		return "", false, nil
+	}
+	secret := &corev1.Secret{}
+	err := clnt.Get(ctx, names.GenSUPasswdAppliedSecretName(vdb), secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return string(secret.Data[SuperuserPasswordKey]), true, nil
+}
+
+// getPendingSuperuserPassword returns the superuser password that the
+// operator was in the middle of setting in the database.  An empty string is
+// returned if no change is in progress.
+func getPendingSuperuserPassword(ctx context.Context, clnt client.Client, vdb *vapi.VerticaDB) (string, error) {
+	secret := &corev1.Secret{}
+	err := clnt.Get(ctx, names.GenSUPasswdAppliedSecretName(vdb), secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(secret.Data[SuperuserPasswordPendingKey]), nil
+}
+
+// getDBSuperuserPassword returns the password to use when connecting to the
+// database.  This is the applied copy if we have one, otherwise it is the
+// password from the user's secret.
+func getDBSuperuserPassword(ctx context.Context, clnt client.Client, evrec record.EventRecorder, log logr.Logger,
+	vdb *vapi.VerticaDB) (string, error) {
+	passwd, ok, err := getAppliedSuperuserPassword(ctx, clnt, vdb)
+	if err != nil || ok {
+		return passwd, err
+	}
+	return getSuperuserPassword(ctx, clnt, evrec, log, vdb)
+}
@@ -0,0 +1,194 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"yunion.io/x/pkg/tristate"
+)
+
+// SuperuserPasswordReconciler will rotate the superuser password in the
+// database when the content of the superuserPasswordSecret changes.  The
+// password that is currently set in the database is kept in a secret owned by
+// the operator, so we can still connect with it after the user's secret has
+// been updated.
+type SuperuserPasswordReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeSuperuserPasswordReconciler will build a SuperuserPasswordReconciler object
+func MakeSuperuserPasswordReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &SuperuserPasswordReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will change the superuser password in the database if it doesn't
+// match the one in the superuserPasswordSecret.  The new password is saved as
+// pending before it is set in the database, and only then promoted to the
+// applied one.  If we are interrupted in between, the next attempt finds out
+// which of the two passwords the database has.
+func (s *SuperuserPasswordReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if s.Vdb.Spec.SuperuserPasswordSecret == "" {
+		return ctrl.Result{}, nil
+	}
+
+	newPasswd, err := s.VRec.GetSuperuserPassword(ctx, s.Vdb, s.Log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	curPasswd, ok, err := getAppliedSuperuserPassword(ctx, s.VRec.Client, s.Vdb)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// Without a copy, we assume the password in the secret is the one the
+	// database was set up with.
+	if !ok {
+		return ctrl.Result{}, s.saveAppliedPassword(ctx, newPasswd, "")
+	}
+	pendingPasswd, err := getPendingSuperuserPassword(ctx, s.VRec.Client, s.Vdb)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if curPasswd == newPasswd && pendingPasswd == "" {
+		return ctrl.Result{}, nil
+	}
+
+	if err := s.PFacts.Collect(ctx, s.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	switch s.PFacts.doesDBExist() {
+	case tristate.False:
+		// The database will be created with whatever password is in the
+		// copy, so we only have to update it.
+		return ctrl.Result{}, s.switchPassword(ctx, newPasswd)
+	case tristate.None:
+		s.Log.Info("Could not determine if the database exists. Requeue to rotate the superuser password.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	atPod, ok := s.PFacts.findPodToRunVsql()
+	if !ok {
+		s.Log.Info("No up pod found to rotate the superuser password. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if pendingPasswd != "" {
+		if curPasswd, err = s.resolvePendingPassword(ctx, atPod.name, curPasswd, pendingPasswd); err != nil {
+			return ctrl.Result{}, err
+		}
+		if curPasswd == newPasswd {
+			return ctrl.Result{}, nil
+		}
+	}
+	return ctrl.Result{}, s.rotatePassword(ctx, atPod.name, curPasswd, newPasswd)
+}
+
+// resolvePendingPassword is called when a prior attempt to change the password
+// didn't finish.  The pending password is tried in the database.  If it works,
+// the change went through and it becomes the applied password.  The password
+// that the database has is returned.
+func (s *SuperuserPasswordReconciler) resolvePendingPassword(ctx context.Context, atPod types.NamespacedName,
+	curPasswd, pendingPasswd string) (string, error) {
+	s.PRunner.SetSUPassword(pendingPasswd)
+	if _, _, err := s.PRunner.ExecVSQL(ctx, atPod, ServerContainer, "-tAc", "select 1"); err != nil {
+		s.Log.Info("Pending superuser password was not set in the database. Using the applied one.")
+		s.PRunner.SetSUPassword(curPasswd)
+		return curPasswd, nil
+	}
+	s.Log.Info("Pending superuser password was set in the database. Promoting it.")
+	return pendingPasswd, s.switchPassword(ctx, pendingPasswd)
+}
+
+// rotatePassword will change the superuser password in the database from
+// curPasswd to newPasswd
+func (s *SuperuserPasswordReconciler) rotatePassword(ctx context.Context, atPod types.NamespacedName,
+	curPasswd, newPasswd string) error {
+	if err := s.saveAppliedPassword(ctx, curPasswd, newPasswd); err != nil {
+		return err
+	}
+	cmd := []string{
+		"-c", fmt.Sprintf("alter user dbadmin identified by '%s'", strings.ReplaceAll(newPasswd, "'", "''")),
+	}
+	if _, _, err := s.PRunner.ExecVSQL(ctx, atPod, ServerContainer, cmd...); err != nil {
+		s.VRec.EVRec.Eventf(s.Vdb, corev1.EventTypeWarning, events.SuperuserPasswordRotateFailed,
+			"Failed to change the superuser password to the one in secret '%s'", s.Vdb.Spec.SuperuserPasswordSecret)
+		return err
+	}
+	if err := s.switchPassword(ctx, newPasswd); err != nil {
+		return err
+	}
+	s.VRec.EVRec.Eventf(s.Vdb, corev1.EventTypeNormal, events.SuperuserPasswordRotated,
+		"Changed the superuser password to the one in secret '%s'", s.Vdb.Spec.SuperuserPasswordSecret)
+	return nil
+}
+
+// switchPassword will record the new password as the applied one, clearing
+// any pending one, and have the pod runner use it for any later command
+func (s *SuperuserPasswordReconciler) switchPassword(ctx context.Context, passwd string) error {
+	if err := s.saveAppliedPassword(ctx, passwd, ""); err != nil {
+		return err
+	}
+	s.PRunner.SetSUPassword(passwd)
+	return nil
+}
+
+// saveAppliedPassword will create or update the secret that has the copy of
+// the password set in the database.  The pending password is only stored if
+// it isn't empty.
+func (s *SuperuserPasswordReconciler) saveAppliedPassword(ctx context.Context, passwd, pending string) error {
+	nm := names.GenSUPasswdAppliedSecretName(s.Vdb)
+	data := map[string][]byte{SuperuserPasswordKey: []byte(passwd)}
+	if pending != "" {
+		data[SuperuserPasswordPendingKey] = []byte(pending)
+	}
+	secret := &corev1.Secret{}
+	err := s.VRec.Client.Get(ctx, nm, secret)
+	if err != nil && errors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nm.Name,
+				Namespace: nm.Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}
+		if err = ctrl.SetControllerReference(s.Vdb, secret, s.VRec.Scheme); err != nil {
+			return err
+		}
+		s.Log.Info("Creating secret for the applied superuser password", "Name", nm)
+		return s.VRec.Client.Create(ctx, secret)
+	} else if err != nil {
+		return err
+	}
+	secret.Data = data
+	return s.VRec.Client.Update(ctx, secret)
+}
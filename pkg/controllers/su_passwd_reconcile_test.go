@@ -0,0 +1,197 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("su_passwd_reconcile", func() {
+	ctx := context.Background()
+	const SUPasswdSecretName = "su-passwd"
+
+	createSUPasswdSecret := func(passwd string) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SUPasswdSecretName, Namespace: vapi.MakeVDBName().Namespace},
+			Data:       map[string][]byte{SuperuserPasswordKey: []byte(passwd)},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+	}
+	deleteSUPasswdSecrets := func(vdb *vapi.VerticaDB) {
+		for _, nm := range []string{SUPasswdSecretName, names.GenSUPasswdAppliedSecretName(vdb).Name} {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: nm, Namespace: vdb.Namespace},
+			}
+			Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+		}
+	}
+	getAppliedPasswd := func(vdb *vapi.VerticaDB) string {
+		passwd, ok, err := getAppliedSuperuserPassword(ctx, k8sClient, vdb)
+		Expect(err).Should(Succeed())
+		Expect(ok).Should(BeTrue())
+		return passwd
+	}
+
+	getPendingPasswd := func(vdb *vapi.VerticaDB) string {
+		passwd, err := getPendingSuperuserPassword(ctx, k8sClient, vdb)
+		Expect(err).Should(Succeed())
+		return passwd
+	}
+	// setResults will have every pod return the given results in order
+	setResults := func(vdb *vapi.VerticaDB, fpr *cmds.FakePodRunner, results ...cmds.CmdResult) {
+		fpr.Results = cmds.CmdResults{}
+		for i := int32(0); i < vdb.Spec.Subclusters[0].Size; i++ {
+			fpr.Results[names.GenPodName(vdb, &vdb.Spec.Subclusters[0], i)] = append([]cmds.CmdResult{}, results...)
+		}
+	}
+	// startFailedRotation will have a rotation from 'first' to 'second' fail
+	// after the new password was saved as pending
+	startFailedRotation := func(vdb *vapi.VerticaDB) (*cmds.FakePodRunner, ReconcileActor) {
+		fpr := &cmds.FakePodRunner{SUPassword: "first"}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeSuperuserPasswordReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, names.GenSUPasswdSecretName(vdb), secret)).Should(Succeed())
+		secret.Data[SuperuserPasswordKey] = []byte("second")
+		Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		setResults(vdb, fpr, cmds.CmdResult{Err: fmt.Errorf("connection lost")})
+		_, err := r.Reconcile(ctx, &ctrl.Request{})
+		Expect(err).ShouldNot(Succeed())
+		Expect(getAppliedPasswd(vdb)).Should(Equal("first"))
+		Expect(getPendingPasswd(vdb)).Should(Equal("second"))
+		fpr.Histories = []cmds.CmdHistory{}
+		return fpr, r
+	}
+
+	It("should do nothing if there is no superuser password secret", func() {
+		vdb := vapi.MakeVDB()
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeSuperuserPasswordReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		_, ok, err := getAppliedSuperuserPassword(ctx, k8sClient, vdb)
+		Expect(err).Should(Succeed())
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("should save a copy of the password without running any vsql", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.SuperuserPasswordSecret = SUPasswdSecretName
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createSUPasswdSecret("first")
+		defer deleteSUPasswdSecrets(vdb)
+
+		fpr := &cmds.FakePodRunner{SUPassword: "first"}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeSuperuserPasswordReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(0))
+		Expect(getAppliedPasswd(vdb)).Should(Equal("first"))
+	})
+
+	It("should rotate the password with the old one when the secret changes", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.SuperuserPasswordSecret = SUPasswdSecretName
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createSUPasswdSecret("first")
+		defer deleteSUPasswdSecrets(vdb)
+
+		fpr := &cmds.FakePodRunner{SUPassword: "first"}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeSuperuserPasswordReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, names.GenSUPasswdSecretName(vdb), secret)).Should(Succeed())
+		secret.Data[SuperuserPasswordKey] = []byte("it's new")
+		Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		h := fpr.FindCommands("alter user dbadmin identified by")
+		Expect(len(h)).Should(Equal(1))
+		Expect(h[0].Command).Should(ContainElements("-w", "first", "alter user dbadmin identified by 'it''s new'"))
+		Expect(fpr.SUPassword).Should(Equal("it's new"))
+		Expect(getAppliedPasswd(vdb)).Should(Equal("it's new"))
+		Expect(getPendingPasswd(vdb)).Should(Equal(""))
+
+		// Nothing to do once the password has been rotated
+		fpr.Histories = []cmds.CmdHistory{}
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(0))
+	})
+
+	It("should promote the pending password if the database already has it", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.SuperuserPasswordSecret = SUPasswdSecretName
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createSUPasswdSecret("first")
+		defer deleteSUPasswdSecrets(vdb)
+
+		fpr, r := startFailedRotation(vdb)
+		setResults(vdb, fpr)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		h := fpr.FindCommands("select 1")
+		Expect(len(h)).Should(Equal(1))
+		Expect(h[0].Command).Should(ContainElements("-w", "second"))
+		Expect(len(fpr.FindCommands("alter user dbadmin identified by"))).Should(Equal(0))
+		Expect(fpr.SUPassword).Should(Equal("second"))
+		Expect(getAppliedPasswd(vdb)).Should(Equal("second"))
+		Expect(getPendingPasswd(vdb)).Should(Equal(""))
+	})
+
+	It("should retry the rotation with the applied password if the pending one doesn't work", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.SuperuserPasswordSecret = SUPasswdSecretName
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+		createSUPasswdSecret("first")
+		defer deleteSUPasswdSecrets(vdb)
+
+		fpr, r := startFailedRotation(vdb)
+		setResults(vdb, fpr, cmds.CmdResult{Err: fmt.Errorf("authentication failed")})
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		h := fpr.FindCommands("alter user dbadmin identified by")
+		Expect(len(h)).Should(Equal(1))
+		Expect(h[0].Command).Should(ContainElements("-w", "first", "alter user dbadmin identified by 'second'"))
+		Expect(fpr.SUPassword).Should(Equal("second"))
+		Expect(getAppliedPasswd(vdb)).Should(Equal("second"))
+		Expect(getPendingPasswd(vdb)).Should(Equal(""))
+	})
+})
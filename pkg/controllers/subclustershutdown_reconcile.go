@@ -0,0 +1,100 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// SubclusterShutdownReconciler will stop the vertica nodes of any subcluster
+// that is marked for shutdown.  This must run before the ObjReconciler scales
+// the subcluster's statefulset to zero, so that the nodes are stopped cleanly
+// before their pods are deleted.
+type SubclusterShutdownReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeSubclusterShutdownReconciler will build a SubclusterShutdownReconciler object
+func MakeSubclusterShutdownReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &SubclusterShutdownReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will call shutdown_subcluster for each subcluster that is marked
+// for shutdown but still has up nodes.
+func (s *SubclusterShutdownReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	for i := range s.Vdb.Spec.Subclusters {
+		sc := &s.Vdb.Spec.Subclusters[i]
+		if !sc.Shutdown {
+			continue
+		}
+		if res, err := s.shutdownSubcluster(ctx, sc); err != nil || res.Requeue {
+			return res, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// shutdownSubcluster will stop the vertica nodes in a single subcluster.  This
+// is a no-op if none of its nodes are up.
+func (s *SubclusterShutdownReconciler) shutdownSubcluster(ctx context.Context, sc *vapi.Subcluster) (ctrl.Result, error) {
+	if err := s.PFacts.Collect(ctx, s.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !s.PFacts.hasUpNodesInSubcluster(sc.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	// We cannot shutdown the subcluster from one of its own nodes, as that
+	// would kill the session that is running the shutdown.
+	atPod, ok := s.PFacts.findPodToRunVsqlOutsideSubcluster(sc.Name)
+	if !ok {
+		s.Log.Info("No up pod found outside of the subcluster to shut it down from. Requeue reconciliation.",
+			"subcluster", sc.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	s.VRec.EVRec.Eventf(s.Vdb, corev1.EventTypeNormal, events.SubclusterShutdownStarted,
+		"Calling shutdown_subcluster for subcluster '%s'", sc.Name)
+	start := time.Now()
+	cmd := []string{
+		"-tAc", fmt.Sprintf("select shutdown_subcluster('%s')", sc.Name),
+	}
+	if _, _, err := s.PRunner.ExecVSQL(ctx, atPod.name, ServerContainer, cmd...); err != nil {
+		s.VRec.EVRec.Eventf(s.Vdb, corev1.EventTypeWarning, events.SubclusterShutdownFailed,
+			"Failed when calling shutdown_subcluster for subcluster '%s'", sc.Name)
+		return ctrl.Result{}, err
+	}
+	s.VRec.EVRec.Eventf(s.Vdb, corev1.EventTypeNormal, events.SubclusterShutdownSucceeded,
+		"Successfully shutdown subcluster '%s'. It took %s", sc.Name, time.Since(start))
+
+	// The nodes are now down, so the pod facts are stale.
+	s.PFacts.Invalidate()
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,112 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("subclustershutdown_reconcile", func() {
+	ctx := context.Background()
+
+	It("should do nothing if no subcluster is marked for shutdown", func() {
+		vdb := makeVdbForShutdown(false)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeSubclusterShutdownReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("shutdown_subcluster"))).Should(Equal(0))
+	})
+
+	It("should call shutdown_subcluster from a pod in another subcluster", func() {
+		vdb := makeVdbForShutdown(true)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		r := MakeSubclusterShutdownReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		hist := fpr.FindCommands("select shutdown_subcluster('sc2')")
+		Expect(len(hist)).Should(Equal(1))
+		Expect(hist[0].Pod).Should(Equal(names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)))
+	})
+
+	It("should not call shutdown_subcluster if the subcluster is already down", func() {
+		vdb := makeVdbForShutdown(true)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		pfacts.Detail[names.GenPodName(vdb, &vdb.Spec.Subclusters[1], 0)].upNode = false
+		r := MakeSubclusterShutdownReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.FindCommands("shutdown_subcluster"))).Should(Equal(0))
+	})
+
+	It("should requeue if no up pod outside of the subcluster is found", func() {
+		vdb := makeVdbForShutdown(true)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		pfacts.Detail[names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)].upNode = false
+		r := MakeSubclusterShutdownReconciler(vrec, logger, vdb, fpr, &pfacts)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{Requeue: true}))
+		Expect(len(fpr.FindCommands("shutdown_subcluster"))).Should(Equal(0))
+	})
+
+	It("should not restart nodes in a subcluster that is shutdown", func() {
+		vdb := makeVdbForShutdown(true)
+		createPods(ctx, vdb, AllPodsRunning)
+		defer deletePods(ctx, vdb)
+
+		fpr := &cmds.FakePodRunner{}
+		pfacts := MakePodFacts(k8sClient, fpr)
+		Expect(pfacts.Collect(ctx, vdb)).Should(Succeed())
+		pfacts.Detail[names.GenPodName(vdb, &vdb.Spec.Subclusters[1], 0)].upNode = false
+		Expect(len(pfacts.findRestartablePods())).Should(Equal(0))
+	})
+})
+
+// makeVdbForShutdown will make a vdb with a primary subcluster and a
+// secondary one, each with a single pod.  The secondary is optionally marked
+// for shutdown.
+func makeVdbForShutdown(shutdown bool) *vapi.VerticaDB {
+	vdb := vapi.MakeVDB()
+	vdb.Spec.Subclusters[0].Name = "sc1"
+	vdb.Spec.Subclusters[0].Size = 1
+	vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{
+		Name:     "sc2",
+		Size:     1,
+		Shutdown: shutdown,
+	})
+	return vdb
+}
@@ -0,0 +1,301 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/paths"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	"github.com/vertica/vertica-kubernetes/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// The first version that configures the server certificate through the
+	// TLS CONFIGURATION objects.  Older versions use the SSL parameters,
+	// which only take effect after a restart.
+	TLSConfigurationVersion = "v11.0.0"
+
+	// The SQL file we generate in the pod to install the server certificate
+	InstallServerCertSQLFile = "/home/dbadmin/install-server-cert.sql"
+)
+
+// TLSReconciler will install the server certificate from the tls.serverSecret
+// in the database.  It is installed again whenever the secret changes.
+type TLSReconciler struct {
+	VRec    *VerticaDBReconciler
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	PRunner cmds.PodRunner
+	PFacts  *PodFacts
+}
+
+// MakeTLSReconciler will build a TLSReconciler object
+func MakeTLSReconciler(vdbrecon *VerticaDBReconciler, log logr.Logger,
+	vdb *vapi.VerticaDB, prunner cmds.PodRunner, pfacts *PodFacts) ReconcileActor {
+	return &TLSReconciler{VRec: vdbrecon, Log: log, Vdb: vdb, PRunner: prunner, PFacts: pfacts}
+}
+
+// Reconcile will install the server certificate if it differs from the one
+// last installed
+func (t *TLSReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	if !t.Vdb.IsTLSEnabled() {
+		return ctrl.Result{}, nil
+	}
+
+	certHash, res, err := t.getCertHash(ctx)
+	if err != nil || res.Requeue {
+		return res, err
+	}
+	if certHash == t.Vdb.Status.TLSCertHash && !t.Vdb.Status.TLSRestartPending {
+		return ctrl.Result{}, nil
+	}
+
+	if err := t.PFacts.Collect(ctx, t.Vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Nothing to do until the database has been initialized
+	if !t.PFacts.doesDBExist().IsTrue() {
+		return ctrl.Result{}, nil
+	}
+	atPod, ok := t.PFacts.findPodToRunVsql()
+	if !ok {
+		t.Log.Info("No up pod found to install the server certificate. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	vinf, ok := version.MakeInfo(t.Vdb)
+	if !ok {
+		t.Log.Info("Vertica version is not known yet. Requeue reconciliation.")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	useTLSConfig := vinf.IsEqualOrNewer(TLSConfigurationVersion)
+	if certHash != t.Vdb.Status.TLSCertHash {
+		if res, err := t.installMountedCert(ctx, atPod.name, certHash, useTLSConfig); err != nil || res.Requeue {
+			return res, err
+		}
+	}
+
+	// The TLS configuration takes effect right away.  The SSL parameters of
+	// older versions need a restart of the cluster.
+	if useTLSConfig {
+		return ctrl.Result{}, t.setCertHash(ctx, certHash, false)
+	}
+	return t.restartCluster(ctx, atPod.name, certHash)
+}
+
+// installMountedCert will install the certificate that is mounted in the pod.
+// The secret is mounted in the pod, and we read the certificate from the
+// mount so that the key never shows up in a command line.  The kubelet
+// updates the mount some time after the secret changes, so we wait until the
+// pod has the certificate we expect.
+func (t *TLSReconciler) installMountedCert(ctx context.Context, atPod types.NamespacedName, certHash string,
+	useTLSConfig bool) (ctrl.Result, error) {
+	mounted, err := t.isCertMounted(ctx, atPod, certHash)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !mounted {
+		t.Log.Info("Server certificate in the pod doesn't match the secret yet. Requeue reconciliation.",
+			"pod", atPod, "mountPath", paths.ServerCertDir)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if err := t.installCert(ctx, atPod, certHash, useTLSConfig); err != nil {
+		t.VRec.EVRec.Eventf(t.Vdb, corev1.EventTypeWarning, events.ServerCertInstallFailed,
+			"Failed to install the server certificate from the secret '%s'", t.Vdb.Spec.TLS.ServerSecret)
+		return ctrl.Result{}, err
+	}
+	t.VRec.EVRec.Eventf(t.Vdb, corev1.EventTypeNormal, events.ServerCertInstalled,
+		"Installed the server certificate from the secret '%s'", t.Vdb.Spec.TLS.ServerSecret)
+	return ctrl.Result{}, nil
+}
+
+// setCertHash will record the hash of the installed certificate in the status,
+// along with whether a restart is still needed for it to take effect
+func (t *TLSReconciler) setCertHash(ctx context.Context, certHash string, restartPending bool) error {
+	if t.Vdb.Status.TLSCertHash == certHash && t.Vdb.Status.TLSRestartPending == restartPending {
+		return nil
+	}
+	return status.Update(ctx, t.VRec.Client, t.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.TLSCertHash = certHash
+		vdb.Status.TLSRestartPending = restartPending
+		return nil
+	})
+}
+
+// getCertHash returns a hash of the server certificate and key in the secret
+func (t *TLSReconciler) getCertHash(ctx context.Context) (string, ctrl.Result, error) {
+	secret := &corev1.Secret{}
+	nm := types.NamespacedName{Namespace: t.Vdb.Namespace, Name: t.Vdb.Spec.TLS.ServerSecret}
+	if err := t.VRec.Client.Get(ctx, nm, secret); err != nil {
+		if errors.IsNotFound(err) {
+			t.VRec.EVRec.Eventf(t.Vdb, corev1.EventTypeWarning, events.ServerCertSecretNotFound,
+				"Could not find the server certificate secret '%s'", t.Vdb.Spec.TLS.ServerSecret)
+			return "", ctrl.Result{Requeue: true}, nil
+		}
+		return "", ctrl.Result{}, fmt.Errorf("could not read the server certificate secret %s: %w", nm.Name, err)
+	}
+
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if _, ok := secret.Data[key]; !ok {
+			t.VRec.EVRec.Eventf(t.Vdb, corev1.EventTypeWarning, events.ServerCertSecretWrongKey,
+				"The server certificate secret '%s' does not have a key named '%s'", nm.Name, key)
+			return "", ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	// We hash the two files in the same order that isCertMounted does, so
+	// that we can compare the hashes.
+	h := sha256.New()
+	h.Write(secret.Data[corev1.TLSCertKey])
+	h.Write(secret.Data[corev1.TLSPrivateKeyKey])
+	return hex.EncodeToString(h.Sum(nil)), ctrl.Result{}, nil
+}
+
+// isCertMounted returns true if the certificate and key mounted in the pod
+// match the given hash
+func (t *TLSReconciler) isCertMounted(ctx context.Context, atPod types.NamespacedName, certHash string) (bool, error) {
+	cmd := []string{
+		"bash", "-c", fmt.Sprintf("cat %s %s | sha256sum", getServerCertPath(), getServerKeyPath()),
+	}
+	stdout, _, err := t.PRunner.ExecInPod(ctx, atPod, ServerContainer, cmd...)
+	if err != nil {
+		return false, err
+	}
+	// The output of sha256sum is the hash followed by the file name
+	return strings.HasPrefix(stdout, certHash), nil
+}
+
+// installCert will install the mounted certificate in the database
+func (t *TLSReconciler) installCert(ctx context.Context, atPod types.NamespacedName, certHash string,
+	useTLSConfig bool) error {
+	// vsql reads the certificate and key from the mount with backquotes, so the
+	// SQL file itself doesn't have anything sensitive.
+	sql := fmt.Sprintf("\\set cert '''`cat %s`'''\n\\set key '''`cat %s`'''\n", getServerCertPath(), getServerKeyPath())
+	if useTLSConfig {
+		// Certificates can't be changed, so each rotation creates a new one
+		// that is named after the hash.  We drop any left from an earlier
+		// attempt that failed part way.
+		keyName, certName := genServerCertNames(certHash)
+		sql += fmt.Sprintf("drop certificate if exists %s cascade;\n", certName) +
+			fmt.Sprintf("drop key if exists %s cascade;\n", keyName) +
+			fmt.Sprintf("create key %s type 'RSA' as :key;\n", keyName) +
+			fmt.Sprintf("create certificate %s as :cert key %s;\n", certName, keyName) +
+			fmt.Sprintf("alter tls configuration server certificate %s tlsmode 'ENABLE';\n", certName)
+	} else {
+		sql += "alter database default set SSLCertificate = :cert, SSLPrivateKey = :key, EnableSSL = 1;\n"
+	}
+
+	// The heredoc delimiter is quoted so that bash doesn't expand the
+	// backquotes.  That is left for vsql.
+	if _, _, err := t.PRunner.ExecInPod(ctx, atPod, ServerContainer,
+		"bash", "-c", "cat > "+InstallServerCertSQLFile+" <<'EOF'\n"+sql+"EOF\n",
+	); err != nil {
+		return err
+	}
+	if _, _, err := t.PRunner.ExecVSQL(ctx, atPod, ServerContainer,
+		"-v", "ON_ERROR_STOP=1", "-f", InstallServerCertSQLFile); err != nil {
+		return err
+	}
+
+	if useTLSConfig && t.Vdb.Status.TLSCertHash != "" {
+		t.dropOldCert(ctx, atPod)
+	}
+	return nil
+}
+
+// dropOldCert will drop the certificate and key that were installed before
+// the rotation.  This is a best effort, as the new certificate is already in
+// use.
+func (t *TLSReconciler) dropOldCert(ctx context.Context, atPod types.NamespacedName) {
+	keyName, certName := genServerCertNames(t.Vdb.Status.TLSCertHash)
+	cmd := []string{
+		"-c", fmt.Sprintf("drop certificate if exists %s; drop key if exists %s;", certName, keyName),
+	}
+	if _, _, err := t.PRunner.ExecVSQL(ctx, atPod, ServerContainer, cmd...); err != nil {
+		t.Log.Info("failed to drop the old server certificate, ignoring failure", "certificate", certName, "err", err)
+	}
+}
+
+// restartCluster will stop the cluster so that the SSL parameters take
+// effect.  The RestartReconciler starts it again.  The hash of the certificate
+// is only recorded without a pending restart once stop_db has been called.
+func (t *TLSReconciler) restartCluster(ctx context.Context, atPod types.NamespacedName, certHash string) (ctrl.Result, error) {
+	if !t.Vdb.Spec.AutoRestartVertica {
+		// The event is only written when the certificate is first installed
+		if t.Vdb.Status.TLSCertHash != certHash {
+			t.VRec.EVRec.Event(t.Vdb, corev1.EventTypeWarning, events.ServerCertRestartNeeded,
+				"The cluster must be restarted for the new server certificate to take effect")
+		}
+		return ctrl.Result{}, t.setCertHash(ctx, certHash, true)
+	}
+
+	cmd := []string{
+		"-t", "stop_db",
+		"--database=" + t.Vdb.Spec.DBName,
+		"--force",
+	}
+	t.VRec.EVRec.Event(t.Vdb, corev1.EventTypeNormal, events.ClusterShutdownStarted,
+		"Calling 'admintools -t stop_db' to restart the cluster with the new server certificate")
+	start := time.Now()
+	if _, _, err := t.PRunner.ExecAdmintools(ctx, atPod, ServerContainer, cmd...); err != nil {
+		t.VRec.EVRec.Event(t.Vdb, corev1.EventTypeWarning, events.ClusterShutdownFailed,
+			"Failed while calling 'admintools -t stop_db'")
+		return ctrl.Result{}, err
+	}
+	t.VRec.EVRec.Eventf(t.Vdb, corev1.EventTypeNormal, events.ClusterShutdownSucceeded,
+		"Successfully called 'admintools -t stop_db' and it took %s", time.Since(start))
+	if err := t.setCertHash(ctx, certHash, false); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Invalidate the cached pod facts now that vertica is down in every pod.
+	// The requeue will get the RestartReconciler to start the cluster.
+	t.PFacts.Invalidate()
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// genServerCertNames returns the names of the key and certificate in the
+// database for the given certificate hash
+func genServerCertNames(certHash string) (keyName, certName string) {
+	const HashPrefixLen = 12
+	suffix := certHash[:HashPrefixLen]
+	return "k8s_server_key_" + suffix, "k8s_server_cert_" + suffix
+}
+
+// getServerCertPath returns the path in the pod to the server certificate
+func getServerCertPath() string {
+	return fmt.Sprintf("%s/%s", paths.ServerCertDir, corev1.TLSCertKey)
+}
+
+// getServerKeyPath returns the path in the pod to the server's private key
+func getServerKeyPath() string {
+	return fmt.Sprintf("%s/%s", paths.ServerCertDir, corev1.TLSPrivateKeyKey)
+}
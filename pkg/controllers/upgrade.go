@@ -0,0 +1,209 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UpgradeManager has the common logic shared by the online and offline
+// upgrade reconcilers.
+type UpgradeManager struct {
+	VRec   *VerticaDBReconciler
+	Log    logr.Logger
+	Vdb    *vapi.VerticaDB // Vdb is the CRD we are acting on.
+	Finder SubclusterFinder
+	// The upgrade policy that the reconciler that owns this manager handles
+	Policy vapi.UpgradePolicyType
+}
+
+// MakeUpgradeManager will construct a UpgradeManager object
+func MakeUpgradeManager(vdbrecon *VerticaDBReconciler, log logr.Logger, vdb *vapi.VerticaDB,
+	policy vapi.UpgradePolicyType) *UpgradeManager {
+	return &UpgradeManager{
+		VRec:   vdbrecon,
+		Log:    log,
+		Vdb:    vdb,
+		Finder: MakeSubclusterFinder(vdbrecon.Client, vdb),
+		Policy: policy,
+	}
+}
+
+// isUpgradeAllowed returns true if the reconciler that owns this manager can
+// act on an image change.  We can only drive the upgrade if the operator is
+// allowed to restart vertica, and only one of the reconcilers will handle the
+// upgrade depending on the policy in effect for the vdb.
+func (u *UpgradeManager) isUpgradeAllowed() bool {
+	if !u.Vdb.Spec.AutoRestartVertica {
+		return false
+	}
+	return u.Policy == u.getEffectivePolicy()
+}
+
+// getEffectivePolicy returns the upgrade policy that is used for the vdb.  An
+// online upgrade needs a second copy of the data to keep the database up while
+// the primaries are restarted, so it falls back to offline with kSafety 0.
+func (u *UpgradeManager) getEffectivePolicy() vapi.UpgradePolicyType {
+	if u.Vdb.Spec.UpgradePolicy == vapi.OfflineUpgrade || u.Vdb.Spec.KSafety == vapi.KSafety0 {
+		return vapi.OfflineUpgrade
+	}
+	return vapi.OnlineUpgrade
+}
+
+// isUpgradeNeeded will return true if any statefulset or pod is running with
+// an image that differs from the one in the vdb.
+func (u *UpgradeManager) isUpgradeNeeded(ctx context.Context, pfacts *PodFacts) (bool, error) {
+	stss, err := u.Finder.FindStatefulSets(ctx, FindInVdb)
+	if err != nil {
+		return false, err
+	}
+	for i := range stss.Items {
+		if stss.Items[i].Spec.Template.Spec.Containers[ServerContainerIndex].Image != u.Vdb.Spec.Image {
+			return true, nil
+		}
+	}
+	for i := range u.Vdb.Spec.Subclusters {
+		if len(u.findPodsWithOldImage(pfacts, u.Vdb.Spec.Subclusters[i].Name)) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// startUpgrade will set the status condition and emit an event to indicate
+// that the upgrade has begun.  This is a no-op if the upgrade was already
+// started in a prior reconcile iteration.
+func (u *UpgradeManager) startUpgrade(ctx context.Context) error {
+	inProgress, err := u.Vdb.IsConditionSet(vapi.ImageChangeInProgress)
+	if err != nil || inProgress {
+		return err
+	}
+	u.Log.Info("Starting upgrade", "image", u.Vdb.Spec.Image, "policy", u.Policy)
+	if u.Policy == vapi.OfflineUpgrade && u.Vdb.Spec.UpgradePolicy != vapi.OfflineUpgrade {
+		u.VRec.EVRec.Event(u.Vdb, corev1.EventTypeWarning, events.OnlineUpgradeNotSupported,
+			"An online upgrade cannot be done with kSafety 0, so an offline upgrade is done instead")
+	}
+	u.VRec.EVRec.Eventf(u.Vdb, corev1.EventTypeNormal, events.UpgradeStart,
+		"Vertica server %s upgrade has started.  New image is '%s'", u.policyName(), u.Vdb.Spec.Image)
+	if err := status.UpdateCondition(ctx, u.VRec.Client, u.Vdb,
+		vapi.VerticaDBCondition{Type: vapi.ImageChangeInProgress, Status: corev1.ConditionTrue},
+	); err != nil {
+		return err
+	}
+	return u.setUpgradeStatus(ctx, "Upgrade has started")
+}
+
+// finishUpgrade will clear the status condition and emit an event to
+// indicate that the upgrade is done.  This is a no-op if no upgrade was in
+// progress.
+func (u *UpgradeManager) finishUpgrade(ctx context.Context) error {
+	inProgress, err := u.Vdb.IsConditionSet(vapi.ImageChangeInProgress)
+	if err != nil || !inProgress {
+		return err
+	}
+	start := u.Vdb.Status.Conditions[vapi.ImageChangeInProgressIndex].LastTransitionTime
+	if err := status.UpdateCondition(ctx, u.VRec.Client, u.Vdb,
+		vapi.VerticaDBCondition{Type: vapi.ImageChangeInProgress, Status: corev1.ConditionFalse},
+	); err != nil {
+		return err
+	}
+	if err := u.setUpgradeStatus(ctx, ""); err != nil {
+		return err
+	}
+	u.VRec.EVRec.Eventf(u.Vdb, corev1.EventTypeNormal, events.UpgradeSucceeded,
+		"Vertica server %s upgrade has completed successfully and it took %s", u.policyName(),
+		time.Since(start.Time).Truncate(time.Second))
+	return nil
+}
+
+// setUpgradeStatus will set the upgrade status message in the vdb
+func (u *UpgradeManager) setUpgradeStatus(ctx context.Context, msg string) error {
+	return status.Update(ctx, u.VRec.Client, u.Vdb, func(vdb *vapi.VerticaDB) error {
+		vdb.Status.UpgradeStatus = msg
+		return nil
+	})
+}
+
+// updateImageInStatefulSet will patch the image in the statefulset if it
+// differs from the vdb.  The statefulset is switched to the OnDelete update
+// strategy in the same patch, so this does not restart any pods.  Outside of
+// an image change, the statefulsets use the RollingUpdate strategy.  The
+// ObjReconciler switches them back once the upgrade is finished.  This returns
+// true if the statefulset was patched.
+func (u *UpgradeManager) updateImageInStatefulSet(ctx context.Context, sts *appsv1.StatefulSet) (bool, error) {
+	if sts.Spec.Template.Spec.Containers[ServerContainerIndex].Image == u.Vdb.Spec.Image {
+		return false, nil
+	}
+	patch := client.MergeFrom(sts.DeepCopy())
+	sts.Spec.Template.Spec.Containers[ServerContainerIndex].Image = u.Vdb.Spec.Image
+	sts.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	if err := u.VRec.Client.Patch(ctx, sts, patch); err != nil {
+		return false, err
+	}
+	u.Log.Info("Updated image in statefulset", "sts", sts.Name, "image", u.Vdb.Spec.Image)
+	return true, nil
+}
+
+// deletePods will delete the given pods.  The statefulset controller will
+// recreate them with the image that is in the statefulset.
+func (u *UpgradeManager) deletePods(ctx context.Context, pods []*PodFact) error {
+	for _, pf := range pods {
+		u.Log.Info("Deleting pod that is running the old image", "pod", pf.name, "image", pf.image)
+		pod := &corev1.Pod{}
+		if err := u.VRec.Client.Get(ctx, pf.name, pod); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if err := u.VRec.Client.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPodsWithOldImage will return the pods in the given subcluster that
+// exist but are running an image that differs from the vdb.  The list is
+// ordered by pod name.
+func (u *UpgradeManager) findPodsWithOldImage(pfacts *PodFacts, scName string) []*PodFact {
+	pods := pfacts.filterPods(func(v *PodFact) bool {
+		return v.subcluster == scName && v.exists && v.image != u.Vdb.Spec.Image
+	})
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].name.Name < pods[j].name.Name
+	})
+	return pods
+}
+
+// policyName returns a lower case name of the policy for use in messages
+func (u *UpgradeManager) policyName() string {
+	if u.Policy == vapi.OfflineUpgrade {
+		return "offline"
+	}
+	return "online"
+}
@@ -0,0 +1,316 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/paths"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	VbrPath = "/opt/vertica/bin/vbr"
+	// The default snapshot name if one isn't set in the backup location
+	DefaultSnapshotName = "backup"
+	// The layout of the timestamp that vbr appends to the snapshot name to
+	// form the restore point name.
+	vbrArchiveLayout = "20060102_150405"
+)
+
+// RestorePoint is a single restore point as reported by 'vbr -t listbackup'
+type RestorePoint struct {
+	// The archive ID of the restore point.  This is what is passed to vbr with
+	// the --archive option.
+	ID string
+	// The time the restore point was created.  This is parsed from the ID.
+	Time time.Time
+}
+
+// VBRRunner has the logic to run vbr in a pod.  It generates the vbr config
+// file for a backup location, sets up the s3 credentials, then calls vbr for
+// a specific task.
+type VBRRunner struct {
+	Client  client.Client
+	EVRec   record.EventRecorder
+	Log     logr.Logger
+	Vdb     *vapi.VerticaDB
+	Loc     *vapi.BackupLocation
+	PRunner cmds.PodRunner
+	// The object that any events are written against
+	EvObj runtime.Object
+	// The superuser password.  This is written to the vbr password file.
+	Passwd string
+	// The number of restore points that vbr will keep for the snapshot
+	RestorePointLimit int
+}
+
+// MakeVBRRunner will build a VBRRunner object
+func MakeVBRRunner(clnt client.Client, evrec record.EventRecorder, log logr.Logger, vdb *vapi.VerticaDB,
+	loc *vapi.BackupLocation, prunner cmds.PodRunner, evObj runtime.Object, passwd string, limit int) *VBRRunner {
+	return &VBRRunner{
+		Client:            clnt,
+		EVRec:             evrec,
+		Log:               log,
+		Vdb:               vdb,
+		Loc:               loc,
+		PRunner:           prunner,
+		EvObj:             evObj,
+		Passwd:            passwd,
+		RestorePointLimit: limit,
+	}
+}
+
+// Setup will generate the vbr config file and the files that hold the
+// credentials in the given pod.  A requeue is returned if the credentials
+// could not be found.
+func (v *VBRRunner) Setup(ctx context.Context, pod types.NamespacedName) (ctrl.Result, error) {
+	backupAuth, res, err := v.getS3Auth(ctx, v.getCredentialSecret())
+	if err != nil || res.Requeue {
+		return res, err
+	}
+	communalAuth, res, err := v.getS3Auth(ctx, v.Vdb.Spec.Communal.CredentialSecret)
+	if err != nil || res.Requeue {
+		return res, err
+	}
+
+	script := fmt.Sprintf("mkdir -p %s && %s && %s",
+		paths.VbrConfigPath,
+		genWriteFileCmd(v.getConfigFileName(), v.genConfig()),
+		genWriteFileCmd(v.getEnvFileName(), v.genEnv(backupAuth, communalAuth)),
+	)
+	if v.Passwd != "" {
+		script += " && " + genWriteFileCmd(v.getPasswordFileName(), v.genPasswordFile())
+	}
+	_, _, err = v.PRunner.ExecInPod(ctx, pod, ServerContainer, "bash", "-c", script)
+	return ctrl.Result{}, err
+}
+
+// Cleanup will remove the files that have the credentials in them.  The vbr
+// config file is left behind as it has no secrets in it and is useful for
+// debugging.
+func (v *VBRRunner) Cleanup(ctx context.Context, pod types.NamespacedName) error {
+	_, _, err := v.PRunner.ExecInPod(ctx, pod, ServerContainer,
+		"rm", "-f", v.getEnvFileName(), v.getPasswordFileName(),
+	)
+	return err
+}
+
+// RunTask will call vbr with the given task.  Setup must have been called for
+// the pod prior to this.  The stdout of vbr is returned.
+func (v *VBRRunner) RunTask(ctx context.Context, pod types.NamespacedName, task string, opts ...string) (string, error) {
+	cmd := []string{VbrPath, "-t", task, "--config-file", v.getConfigFileName()}
+	cmd = append(cmd, opts...)
+	stdout, _, err := v.PRunner.ExecInPod(ctx, pod, ServerContainer,
+		"bash", "-c", fmt.Sprintf("source %s && %s", v.getEnvFileName(), strings.Join(cmd, " ")),
+	)
+	return stdout, err
+}
+
+// ListRestorePoints will return the restore points for the snapshot.  They
+// are sorted so that the newest restore point is first.
+func (v *VBRRunner) ListRestorePoints(ctx context.Context, pod types.NamespacedName) ([]RestorePoint, error) {
+	stdout, err := v.RunTask(ctx, pod, "listbackup")
+	if err != nil {
+		return nil, err
+	}
+	return parseRestorePoints(stdout, v.getSnapshotName()), nil
+}
+
+// parseRestorePoints will parse the output of 'vbr -t listbackup'.  The
+// output is a table with a header row.  The first column has the name of the
+// restore point, which is the snapshot name followed by a timestamp.  Any rows
+// for other snapshots are ignored.
+func parseRestorePoints(op, snapshotName string) []RestorePoint {
+	rps := []RestorePoint{}
+	prefix := snapshotName + "_"
+	lines := strings.Split(op, "\n")
+	for i := range lines {
+		cols := strings.Fields(lines[i])
+		if len(cols) == 0 || !strings.HasPrefix(cols[0], prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(cols[0], prefix)
+		ts, err := time.Parse(vbrArchiveLayout, id)
+		if err != nil {
+			continue
+		}
+		rps = append(rps, RestorePoint{ID: id, Time: ts})
+	}
+	sort.Slice(rps, func(i, j int) bool {
+		return rps[i].Time.After(rps[j].Time)
+	})
+	return rps
+}
+
+// genConfig will generate the contents of the vbr config file
+func (v *VBRRunner) genConfig() string {
+	var sb strings.Builder
+	sb.WriteString("[CloudStorage]\n")
+	fmt.Fprintf(&sb, "cloud_storage_backup_path = %s/\n", strings.TrimSuffix(v.Loc.Path, "/"))
+	fmt.Fprintf(&sb, "cloud_storage_backup_file_system_path = []:%s/locks/\n", paths.VbrConfigPath)
+	sb.WriteString("\n[Misc]\n")
+	fmt.Fprintf(&sb, "snapshotName = %s\n", v.getSnapshotName())
+	sb.WriteString("tempDir = /tmp/vbr\n")
+	fmt.Fprintf(&sb, "restorePointLimit = %d\n", v.getRestorePointLimit())
+	if v.Passwd != "" {
+		fmt.Fprintf(&sb, "passwordFile = %s\n", v.getPasswordFileName())
+	}
+	sb.WriteString("\n[Database]\n")
+	fmt.Fprintf(&sb, "dbName = %s\n", v.Vdb.Spec.DBName)
+	sb.WriteString("dbUser = dbadmin\n")
+	sb.WriteString("dbPromptForPassword = False\n")
+	return sb.String()
+}
+
+// genEnv will generate the environment variables that vbr uses to get the
+// credentials for the backup location and communal storage.
+func (v *VBRRunner) genEnv(backupAuth, communalAuth []string) string {
+	// The keys are omitted if there is no credential secret.  vbr will use
+	// the IAM role of the pod in that case.
+	env := ""
+	if backupAuth != nil {
+		env += fmt.Sprintf("export VBR_BACKUP_STORAGE_ACCESS_KEY_ID=%s\n"+
+			"export VBR_BACKUP_STORAGE_SECRET_ACCESS_KEY=%s\n",
+			shellQuote(backupAuth[0]), shellQuote(backupAuth[1]))
+	}
+	env += fmt.Sprintf("export VBR_BACKUP_STORAGE_ENDPOINT_URL=%s\n", shellQuote(v.getEndpoint()))
+	// The CA bundle of the communal endpoint applies to the backup location
+	// only when it defaults to the communal endpoint.
+	if v.Vdb.Spec.Communal.CaFile != "" && v.Loc.Endpoint == "" {
+		env += fmt.Sprintf("export VBR_BACKUP_STORAGE_CA_FILE=%s\n", paths.CommunalCAFile)
+	}
+	if communalAuth != nil {
+		env += fmt.Sprintf("export VBR_COMMUNAL_STORAGE_ACCESS_KEY_ID=%s\n"+
+			"export VBR_COMMUNAL_STORAGE_SECRET_ACCESS_KEY=%s\n",
+			shellQuote(communalAuth[0]), shellQuote(communalAuth[1]))
+	}
+	env += fmt.Sprintf("export VBR_COMMUNAL_STORAGE_ENDPOINT_URL=%s\n", shellQuote(v.Vdb.Spec.Communal.Endpoint))
+	if v.Vdb.Spec.Communal.CaFile != "" {
+		env += fmt.Sprintf("export VBR_COMMUNAL_STORAGE_CA_FILE=%s\n", paths.CommunalCAFile)
+	}
+	return env
+}
+
+// genPasswordFile will generate the contents of the vbr password file
+func (v *VBRRunner) genPasswordFile() string {
+	return fmt.Sprintf("[Passwords]\ndbPassword = %s\n", v.Passwd)
+}
+
+// genWriteFileCmd returns a bash command that writes the contents to a file.
+// The contents are base64 encoded so that nothing in them is interpreted by
+// bash, and so that the credentials in them are hidden when the command is
+// logged.
+func genWriteFileCmd(fileName, contents string) string {
+	return fmt.Sprintf("echo '%s' | base64 -d > %s",
+		base64.StdEncoding.EncodeToString([]byte(contents)), fileName)
+}
+
+// shellQuote will put the value in single quotes so that bash takes it
+// literally when the env file is sourced
+func shellQuote(val string) string {
+	return "'" + strings.ReplaceAll(val, "'", `'\''`) + "'"
+}
+
+// getS3Auth will return the access key and secret key that are stored in the
+// given secret.  A requeue is returned if the secret or its keys are missing.
+// Nil is returned if no secret was given.
+func (v *VBRRunner) getS3Auth(ctx context.Context, secretName string) ([]string, ctrl.Result, error) {
+	if secretName == "" {
+		return nil, ctrl.Result{}, nil
+	}
+	secret := &corev1.Secret{}
+	nm := types.NamespacedName{Namespace: v.Vdb.Namespace, Name: secretName}
+	if err := v.Client.Get(ctx, nm, secret); err != nil {
+		if errors.IsNotFound(err) {
+			v.EVRec.Eventf(v.EvObj, corev1.EventTypeWarning, events.S3CredsNotFound,
+				"Could not find the credential secret '%s'", secretName)
+			return nil, ctrl.Result{Requeue: true}, nil
+		}
+		return nil, ctrl.Result{}, fmt.Errorf("could not read the credential secret %s: %w", secretName, err)
+	}
+
+	auth := []string{}
+	for _, key := range []string{S3AccessKeyName, S3SecretKeyName} {
+		val, ok := secret.Data[key]
+		if !ok {
+			v.EVRec.Eventf(v.EvObj, corev1.EventTypeWarning, events.S3CredsWrongKey,
+				"The credential secret '%s' does not have a key named '%s'", secretName, key)
+			return nil, ctrl.Result{Requeue: true}, nil
+		}
+		auth = append(auth, strings.TrimSuffix(string(val), "\n"))
+	}
+	return auth, ctrl.Result{}, nil
+}
+
+// getCredentialSecret returns the name of the secret that has the credentials
+// for the backup location.  We default to the communal credentials.
+func (v *VBRRunner) getCredentialSecret() string {
+	if v.Loc.CredentialSecret != "" {
+		return v.Loc.CredentialSecret
+	}
+	return v.Vdb.Spec.Communal.CredentialSecret
+}
+
+// getEndpoint returns the s3 endpoint of the backup location.  We default to
+// the communal endpoint.
+func (v *VBRRunner) getEndpoint() string {
+	if v.Loc.Endpoint != "" {
+		return v.Loc.Endpoint
+	}
+	return v.Vdb.Spec.Communal.Endpoint
+}
+
+func (v *VBRRunner) getSnapshotName() string {
+	if v.Loc.SnapshotName != "" {
+		return v.Loc.SnapshotName
+	}
+	return DefaultSnapshotName
+}
+
+func (v *VBRRunner) getRestorePointLimit() int {
+	if v.RestorePointLimit > 0 {
+		return v.RestorePointLimit
+	}
+	return 1
+}
+
+func (v *VBRRunner) getConfigFileName() string {
+	return fmt.Sprintf("%s/%s.ini", paths.VbrConfigPath, v.getSnapshotName())
+}
+
+func (v *VBRRunner) getEnvFileName() string {
+	return fmt.Sprintf("%s/%s.env", paths.VbrConfigPath, v.getSnapshotName())
+}
+
+func (v *VBRRunner) getPasswordFileName() string {
+	return fmt.Sprintf("%s/%s.passwd", paths.VbrConfigPath, v.getSnapshotName())
+}
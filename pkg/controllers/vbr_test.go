@@ -0,0 +1,121 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/names"
+	"github.com/vertica/vertica-kubernetes/pkg/paths"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// decodeWrittenFiles returns the contents of all of the files that a script
+// writes with base64
+func decodeWrittenFiles(script string) string {
+	re := regexp.MustCompile(`echo '([A-Za-z0-9+/=]*)' \| base64 -d`)
+	contents := ""
+	for _, m := range re.FindAllStringSubmatch(script, -1) {
+		b, err := base64.StdEncoding.DecodeString(m[1])
+		Expect(err).Should(Succeed())
+		contents += string(b)
+	}
+	return contents
+}
+
+var _ = Describe("vbr", func() {
+	ctx := context.Background()
+
+	It("should parse the restore points from listbackup", func() {
+		op := `backup                  backup_type   epoch   objects   include_patterns   exclude_patterns   nodes(hosts)
+backup_20210801_093012  full          100
+other_20210803_093012   full          130
+backup_20210802_093012  full          120
+`
+		rps := parseRestorePoints(op, "backup")
+		Expect(len(rps)).Should(Equal(2))
+		Expect(rps[0].ID).Should(Equal("20210802_093012"))
+		Expect(rps[1].ID).Should(Equal("20210801_093012"))
+		Expect(len(parseRestorePoints("", "backup"))).Should(Equal(0))
+	})
+
+	It("should generate a config file for the backup location", func() {
+		vdb := vapi.MakeVDB()
+		vb := vapi.MakeVBackup()
+		vb.Spec.Location.Path = "s3://bucket/backups/"
+		vb.Spec.RestorePointLimit = 3
+		v := MakeVBRRunner(k8sClient, vrec.EVRec, logger, vdb, &vb.Spec.Location, &cmds.FakePodRunner{}, vb, "pwd", 3)
+		cfg := v.genConfig()
+		Expect(cfg).Should(ContainSubstring("cloud_storage_backup_path = s3://bucket/backups/\n"))
+		Expect(cfg).Should(ContainSubstring("snapshotName = backup\n"))
+		Expect(cfg).Should(ContainSubstring("restorePointLimit = 3\n"))
+		Expect(cfg).Should(ContainSubstring("dbName = " + vdb.Spec.DBName + "\n"))
+		Expect(cfg).Should(ContainSubstring("passwordFile = " + paths.VbrConfigPath + "/backup.passwd\n"))
+	})
+
+	It("should default the endpoint and credentials to communal storage", func() {
+		vdb := vapi.MakeVDB()
+		createCommunalCredSecret(ctx, vdb)
+		defer deleteCommunalCredSecret(ctx, vdb)
+		vb := vapi.MakeVBackup()
+		fpr := &cmds.FakePodRunner{}
+		v := MakeVBRRunner(k8sClient, vrec.EVRec, logger, vdb, &vb.Spec.Location, fpr, vb, "", 1)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		Expect(v.Setup(ctx, podName)).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(1))
+		script := fpr.Histories[0].Command[2]
+		Expect(script).ShouldNot(ContainSubstring(testAccessKey))
+		files := decodeWrittenFiles(script)
+		Expect(files).Should(ContainSubstring("VBR_BACKUP_STORAGE_ENDPOINT_URL='" + vdb.Spec.Communal.Endpoint + "'"))
+		Expect(files).Should(ContainSubstring("VBR_BACKUP_STORAGE_ACCESS_KEY_ID='" + testAccessKey + "'"))
+		Expect(files).ShouldNot(ContainSubstring("passwordFile"))
+	})
+
+	It("should omit the access keys if there is no credential secret", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.CredentialSecret = ""
+		vb := vapi.MakeVBackup()
+		fpr := &cmds.FakePodRunner{}
+		v := MakeVBRRunner(k8sClient, vrec.EVRec, logger, vdb, &vb.Spec.Location, fpr, vb, "", 1)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		Expect(v.Setup(ctx, podName)).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(1))
+		files := decodeWrittenFiles(fpr.Histories[0].Command[2])
+		Expect(files).Should(ContainSubstring("VBR_COMMUNAL_STORAGE_ENDPOINT_URL='" + vdb.Spec.Communal.Endpoint + "'"))
+		Expect(files).ShouldNot(ContainSubstring("ACCESS_KEY_ID"))
+	})
+
+	It("should not put the password in the script", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Communal.CredentialSecret = ""
+		vb := vapi.MakeVBackup()
+		fpr := &cmds.FakePodRunner{}
+		const passwd = "pa'ss && rm -rf /"
+		v := MakeVBRRunner(k8sClient, vrec.EVRec, logger, vdb, &vb.Spec.Location, fpr, vb, passwd, 1)
+		podName := names.GenPodName(vdb, &vdb.Spec.Subclusters[0], 0)
+		Expect(v.Setup(ctx, podName)).Should(Equal(ctrl.Result{}))
+		Expect(len(fpr.Histories)).Should(Equal(1))
+		script := fpr.Histories[0].Command[2]
+		Expect(script).ShouldNot(ContainSubstring(passwd))
+		Expect(decodeWrittenFiles(script)).Should(ContainSubstring("dbPassword = " + passwd + "\n"))
+	})
+})
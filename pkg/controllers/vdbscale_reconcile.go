@@ -0,0 +1,220 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+	"github.com/vertica/vertica-kubernetes/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// VDBScaleReconciler will change the VerticaDB so that the subclusters
+// targeted by a VerticaAutoscaler match its target size.  Only the spec is
+// changed.  The VerticaDB controller's add and remove node/subcluster actors
+// carry out the change.
+type VDBScaleReconciler struct {
+	VRec *VerticaAutoscalerReconciler
+	Log  logr.Logger
+	Vas  *vapi.VerticaAutoscaler
+	Vdb  *vapi.VerticaDB
+	// Events for the changes made to the VerticaDB.  They are only emitted
+	// once the update has gone through, as the update can be retried.
+	pendingEvents []pendingEvent
+}
+
+// pendingEvent is an event that will be emitted for the VerticaAutoscaler
+type pendingEvent struct {
+	eventType string
+	reason    string
+	message   string
+}
+
+// MakeVDBScaleReconciler will build a VDBScaleReconciler object
+func MakeVDBScaleReconciler(r *VerticaAutoscalerReconciler, log logr.Logger,
+	vas *vapi.VerticaAutoscaler, vdb *vapi.VerticaDB) ReconcileActor {
+	return &VDBScaleReconciler{VRec: r, Log: log, Vas: vas, Vdb: vdb}
+}
+
+// Reconcile will update the VerticaDB if the targeted subclusters are not at
+// the target size.
+func (v *VDBScaleReconciler) Reconcile(ctx context.Context, req *ctrl.Request) (ctrl.Result, error) {
+	// No target size means the autoscaler has not picked a size yet.
+	targetSize, ok := v.Vas.GetTargetSize()
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+
+	if v.Vas.Spec.ScalingGranularity == vapi.SubclusterScalingGranularity &&
+		(v.Vas.Spec.Template.Name == "" || v.Vas.Spec.Template.Size == 0) {
+		v.VRec.EVRec.Event(v.Vas, corev1.EventTypeWarning, events.InvalidAutoscalerTemplate,
+			"The template must have a name and a non-zero size when scaling by subcluster")
+		return ctrl.Result{}, nil
+	}
+
+	scaled := false
+	nm := types.NamespacedName{Namespace: v.Vdb.Namespace, Name: v.Vdb.Name}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Always fetch the latest copy so that we don't wipe out changes that
+		// were made to the VerticaDB since it was read.
+		if err := v.VRec.Get(ctx, nm, v.Vdb); err != nil {
+			return err
+		}
+		v.pendingEvents = nil
+
+		var changed bool
+		if v.Vas.Spec.ScalingGranularity == vapi.SubclusterScalingGranularity {
+			changed = v.scaleSubclusters(targetSize)
+		} else {
+			changed = v.resizeSubcluster(targetSize)
+		}
+		if !changed {
+			return nil
+		}
+		scaled = true
+		return v.VRec.Update(ctx, v.Vdb)
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, e := range v.pendingEvents {
+		v.VRec.EVRec.Event(v.Vas, e.eventType, e.reason, e.message)
+	}
+	if !scaled {
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, status.UpdateVerticaAutoscaler(ctx, v.VRec.Client, v.Vas,
+		func(vas *vapi.VerticaAutoscaler) error {
+			vas.Status.ScalingCount++
+			return nil
+		})
+}
+
+// resizeSubcluster will set the size of the targeted subcluster to the target
+// size.  It returns true if the VerticaDB was changed.
+func (v *VDBScaleReconciler) resizeSubcluster(targetSize int32) bool {
+	for i := range v.Vdb.Spec.Subclusters {
+		sc := &v.Vdb.Spec.Subclusters[i]
+		if sc.Name != v.Vas.Spec.SubclusterName {
+			continue
+		}
+		if sc.IsPrimary {
+			v.addEvent(corev1.EventTypeWarning, events.PrimarySubclusterNotScalable,
+				"The subcluster '%s' in the VerticaDB '%s' is a primary.  Only secondary subclusters can be scaled.",
+				sc.Name, v.Vdb.Name)
+			return false
+		}
+		if sc.Size == targetSize {
+			return false
+		}
+		v.Log.Info("Resizing subcluster", "subcluster", sc.Name, "oldSize", sc.Size, "newSize", targetSize)
+		sc.Size = targetSize
+		return true
+	}
+	v.addEvent(corev1.EventTypeWarning, events.SubclusterNotFound,
+		"The subcluster '%s' was not found in the VerticaDB '%s'", v.Vas.Spec.SubclusterName, v.Vdb.Name)
+	return false
+}
+
+// scaleSubclusters will add or remove whole subclusters so that the number
+// of subclusters in the group matches the target size.  It returns true if the
+// VerticaDB was changed.
+func (v *VDBScaleReconciler) scaleSubclusters(targetSize int32) bool {
+	tmpl := &v.Vas.Spec.Template
+	want := int(targetSize / tmpl.Size)
+
+	// Only secondary subclusters are ever added or removed.  A primary that
+	// happens to match the name prefix is left alone.
+	group := []int{}
+	inUse := map[int]bool{}
+	for i := range v.Vdb.Spec.Subclusters {
+		sc := &v.Vdb.Spec.Subclusters[i]
+		if sc.IsPrimary || !v.Vas.IsSubclusterInGroup(sc.Name) {
+			continue
+		}
+		group = append(group, i)
+		inUse[v.getSubclusterIndex(sc.Name)] = true
+	}
+
+	switch {
+	case len(group) < want:
+		for n := 0; len(group) < want; n++ {
+			if inUse[n] {
+				continue
+			}
+			sc := tmpl.DeepCopy()
+			sc.Name = fmt.Sprintf("%s-%d", tmpl.Name, n)
+			sc.IsPrimary = false
+			v.Vdb.Spec.Subclusters = append(v.Vdb.Spec.Subclusters, *sc)
+			group = append(group, len(v.Vdb.Spec.Subclusters)-1)
+			v.addEvent(corev1.EventTypeNormal, events.SubclusterAdded,
+				"Added subcluster '%s' to the VerticaDB '%s'", sc.Name, v.Vdb.Name)
+		}
+		return true
+	case len(group) > want:
+		// Remove the subclusters with the highest index first, so that the
+		// names are reused in order when we scale out again.
+		sort.Slice(group, func(i, j int) bool {
+			return v.getSubclusterIndex(v.Vdb.Spec.Subclusters[group[i]].Name) >
+				v.getSubclusterIndex(v.Vdb.Spec.Subclusters[group[j]].Name)
+		})
+		toRemove := map[int]bool{}
+		for _, i := range group[:len(group)-want] {
+			toRemove[i] = true
+		}
+		kept := []vapi.Subcluster{}
+		for i := range v.Vdb.Spec.Subclusters {
+			if !toRemove[i] {
+				kept = append(kept, v.Vdb.Spec.Subclusters[i])
+				continue
+			}
+			v.addEvent(corev1.EventTypeNormal, events.SubclusterRemoved,
+				"Removed subcluster '%s' from the VerticaDB '%s'", v.Vdb.Spec.Subclusters[i].Name, v.Vdb.Name)
+		}
+		v.Vdb.Spec.Subclusters = kept
+		return true
+	}
+	return false
+}
+
+// addEvent will save an event to emit once the VerticaDB has been updated
+func (v *VDBScaleReconciler) addEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	v.pendingEvents = append(v.pendingEvents, pendingEvent{
+		eventType: eventType,
+		reason:    reason,
+		message:   fmt.Sprintf(messageFmt, args...),
+	})
+}
+
+// getSubclusterIndex returns the numeric suffix of a subcluster in the group.
+// Subclusters whose suffix isn't a number return -1.
+func (v *VDBScaleReconciler) getSubclusterIndex(scName string) int {
+	n, err := strconv.Atoi(scName[len(v.Vas.Spec.Template.Name)+1:])
+	if err != nil {
+		return -1
+	}
+	return n
+}
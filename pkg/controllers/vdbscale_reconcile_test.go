@@ -0,0 +1,272 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// conflictOnceClient is a client whose first update fails with a conflict
+type conflictOnceClient struct {
+	client.Client
+	conflicted bool
+}
+
+func (c *conflictOnceClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if !c.conflicted {
+		c.conflicted = true
+		return kerrors.NewConflict(schema.GroupResource{Group: "vertica.com", Resource: "verticadbs"},
+			obj.GetName(), nil)
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+var _ = Describe("vdbscale_reconcile", func() {
+	ctx := context.Background()
+
+	It("should do nothing if the target size is not set", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := vapi.MakeVAS()
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeVDBScaleReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(vdb.Spec.Subclusters[0].Size).Should(Equal(vapi.MakeVDB().Spec.Subclusters[0].Size))
+		Expect(vas.Status.ScalingCount).Should(Equal(0))
+	})
+
+	It("should change the size of the subcluster when scaling by pod", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := vapi.MakeVAS()
+		vas.Spec.TargetSize = int32Ptr(8)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeVDBScaleReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(vdb.Spec.Subclusters[0].Size).Should(Equal(int32(8)))
+		Expect(vas.Status.ScalingCount).Should(Equal(1))
+
+		// Already at the target, so nothing changes
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(vas.Status.ScalingCount).Should(Equal(1))
+	})
+
+	It("should leave the vdb alone if the subcluster doesn't exist", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := vapi.MakeVAS()
+		vas.Spec.SubclusterName = "notthere"
+		vas.Spec.TargetSize = int32Ptr(8)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeVDBScaleReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(vdb.Spec.Subclusters[0].Size).Should(Equal(vapi.MakeVDB().Spec.Subclusters[0].Size))
+		Expect(vas.Status.ScalingCount).Should(Equal(0))
+	})
+
+	It("should add and remove subclusters from the template when scaling by subcluster", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := makeSubclusterVAS()
+		vas.Spec.TargetSize = int32Ptr(9) // Rounded down to 2 subclusters of size 4
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeVDBScaleReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(getSubclusterNames(vdb)).Should(Equal([]string{vdb.Spec.Subclusters[0].Name, "as-0", "as-1"}))
+		Expect(vdb.Spec.Subclusters[1].Size).Should(Equal(int32(4)))
+		Expect(vdb.Spec.Subclusters[1].IsPrimary).Should(BeFalse())
+
+		// Scale in removes the highest numbered subcluster first
+		vas.Spec.TargetSize = int32Ptr(4)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(getSubclusterNames(vdb)).Should(Equal([]string{vdb.Spec.Subclusters[0].Name, "as-0"}))
+		Expect(vas.Status.ScalingCount).Should(Equal(2))
+
+		// A target size of zero removes every subcluster in the group
+		vas.Spec.TargetSize = int32Ptr(0)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(getSubclusterNames(vdb)).Should(Equal([]string{vdb.Spec.Subclusters[0].Name}))
+		Expect(vas.Status.ScalingCount).Should(Equal(3))
+	})
+
+	It("should not scale a primary subcluster by pod", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters[0].IsPrimary = true
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := vapi.MakeVAS()
+		vas.Spec.TargetSize = int32Ptr(8)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		evrec := record.NewFakeRecorder(10)
+		vasRec := makeVerticaAutoscalerReconciler()
+		vasRec.EVRec = evrec
+		r := MakeVDBScaleReconciler(vasRec, logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(vdb.Spec.Subclusters[0].Size).Should(Equal(vapi.MakeVDB().Spec.Subclusters[0].Size))
+		Expect(vas.Status.ScalingCount).Should(Equal(0))
+		Expect(len(evrec.Events)).Should(Equal(1))
+		Expect(<-evrec.Events).Should(ContainSubstring("PrimarySubclusterNotScalable"))
+	})
+
+	It("should only emit events once the vdb update goes through", func() {
+		vdb := vapi.MakeVDB()
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := makeSubclusterVAS()
+		vas.Spec.TargetSize = int32Ptr(8)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		evrec := record.NewFakeRecorder(10)
+		vasRec := makeVerticaAutoscalerReconciler()
+		vasRec.EVRec = evrec
+		cli := &conflictOnceClient{Client: vasRec.Client}
+		vasRec.Client = cli
+		r := MakeVDBScaleReconciler(vasRec, logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		Expect(cli.conflicted).Should(BeTrue())
+		fetchVdb(ctx, vdb)
+		Expect(getSubclusterNames(vdb)).Should(Equal([]string{vdb.Spec.Subclusters[0].Name, "as-0", "as-1"}))
+		Expect(len(evrec.Events)).Should(Equal(2))
+	})
+
+	It("should scale a subcluster down to zero when scaling by pod", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{Name: "sec", Size: 2})
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := vapi.MakeVAS()
+		vas.Spec.SubclusterName = "sec"
+		vas.Spec.TargetSize = int32Ptr(0)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeVDBScaleReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(vdb.Spec.Subclusters[1].Size).Should(Equal(int32(0)))
+		Expect(vas.Status.ScalingCount).Should(Equal(1))
+	})
+
+	It("should reuse the lowest free name when adding a subcluster", func() {
+		vdb := vapi.MakeVDB()
+		vdb.Spec.Subclusters = append(vdb.Spec.Subclusters, vapi.Subcluster{Name: "as-1", Size: 4})
+		createVdb(ctx, vdb)
+		defer deleteVdb(ctx, vdb)
+		vas := makeSubclusterVAS()
+		vas.Spec.TargetSize = int32Ptr(12)
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+
+		r := MakeVDBScaleReconciler(makeVerticaAutoscalerReconciler(), logger, vas, vdb)
+		Expect(r.Reconcile(ctx, &ctrl.Request{})).Should(Equal(ctrl.Result{}))
+		fetchVdb(ctx, vdb)
+		Expect(getSubclusterNames(vdb)).Should(Equal([]string{vdb.Spec.Subclusters[0].Name, "as-1", "as-0", "as-2"}))
+	})
+
+	It("should map a VerticaDB to the VerticaAutoscalers that scale it", func() {
+		vdb := vapi.MakeVDB()
+		vas := vapi.MakeVAS()
+		createVAS(ctx, vas)
+		defer deleteVAS(ctx, vas)
+		otherVas := vapi.MakeVAS()
+		otherVas.Name = "other-vas"
+		otherVas.Spec.VerticaDBName = "other-vdb"
+		createVAS(ctx, otherVas)
+		defer deleteVAS(ctx, otherVas)
+
+		r := makeVerticaAutoscalerReconciler()
+		Expect(r.mapVDBToVASs(vdb)).Should(Equal([]reconcile.Request{
+			{NamespacedName: vapi.MakeVASName()},
+		}))
+	})
+})
+
+// makeVerticaAutoscalerReconciler will build a VerticaAutoscalerReconciler
+// from the same parts as the VerticaDB reconciler used in the tests
+func makeVerticaAutoscalerReconciler() *VerticaAutoscalerReconciler {
+	return &VerticaAutoscalerReconciler{
+		Client: vrec.Client,
+		Log:    vrec.Log,
+		Scheme: vrec.Scheme,
+		EVRec:  vrec.EVRec,
+	}
+}
+
+// makeSubclusterVAS will make a VerticaAutoscaler that scales by adding or
+// removing subclusters of size 4, named as-<n>
+func makeSubclusterVAS() *vapi.VerticaAutoscaler {
+	vas := vapi.MakeVAS()
+	vas.Spec.ScalingGranularity = vapi.SubclusterScalingGranularity
+	vas.Spec.SubclusterName = ""
+	vas.Spec.Template = vapi.Subcluster{Name: "as", Size: 4}
+	return vas
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func createVAS(ctx context.Context, vas *vapi.VerticaAutoscaler) {
+	ExpectWithOffset(1, k8sClient.Create(ctx, vas)).Should(Succeed())
+}
+
+func deleteVAS(ctx context.Context, vas *vapi.VerticaAutoscaler) {
+	ExpectWithOffset(1, k8sClient.Delete(ctx, vas)).Should(Succeed())
+}
+
+func fetchVdb(ctx context.Context, vdb *vapi.VerticaDB) {
+	ExpectWithOffset(1, k8sClient.Get(ctx, vdb.ExtractNamespacedName(), vdb)).Should(Succeed())
+}
+
+func getSubclusterNames(vdb *vapi.VerticaDB) []string {
+	scNames := []string{}
+	for i := range vdb.Spec.Subclusters {
+		scNames = append(scNames, vdb.Spec.Subclusters[i].Name)
+	}
+	return scNames
+}
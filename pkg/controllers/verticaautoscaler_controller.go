@@ -0,0 +1,132 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+)
+
+// VerticaAutoscalerReconciler reconciles a VerticaAutoscaler object
+type VerticaAutoscalerReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	EVRec  record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticaautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticaautoscalers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticaautoscalers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticadbs,verbs=get;list;watch;update
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VerticaAutoscalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vapi.VerticaAutoscaler{}).
+		// The size and selector in the status come from the VerticaDB, so a
+		// change to it needs a reconcile of the VerticaAutoscalers that
+		// target it.
+		Watches(&source.Kind{Type: &vapi.VerticaDB{}}, handler.EnqueueRequestsFromMapFunc(r.mapVDBToVASs)).
+		Complete(r)
+}
+
+// mapVDBToVASs returns a request for each VerticaAutoscaler that scales the
+// given VerticaDB
+func (r *VerticaAutoscalerReconciler) mapVDBToVASs(obj client.Object) []reconcile.Request {
+	vasList := &vapi.VerticaAutoscalerList{}
+	if err := r.List(context.Background(), vasList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list VerticaAutoscalers for VerticaDB", "verticadb", obj.GetName())
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for i := range vasList.Items {
+		vas := &vasList.Items[i]
+		if vas.Spec.VerticaDBName == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: vas.Namespace, Name: vas.Name},
+			})
+		}
+	}
+	return reqs
+}
+
+// Reconcile will scale the targeted subclusters of a VerticaDB to the size
+// found in the VerticaAutoscaler.  It only ever changes the VerticaDB spec.
+// The VerticaDB controller does the work of adding and removing the pods,
+// nodes and subclusters.
+func (r *VerticaAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("verticaautoscaler", req.NamespacedName)
+	log.Info("starting reconcile of VerticaAutoscaler")
+
+	vas := &vapi.VerticaAutoscaler{}
+	err := r.Get(ctx, req.NamespacedName, vas)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("VerticaAutoscaler resource not found.  Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get VerticaAutoscaler")
+		return ctrl.Result{}, err
+	}
+
+	vdb, res, err := fetchVDB(ctx, r.Client, r.EVRec, vas,
+		types.NamespacedName{Namespace: vas.Namespace, Name: vas.Spec.VerticaDBName})
+	if err != nil || res.Requeue {
+		return res, err
+	}
+
+	actors := []ReconcileActor{
+		// Changes the VerticaDB so that the targeted subclusters match the
+		// target size.
+		MakeVDBScaleReconciler(r, log, vas, vdb),
+		// Sets the current size and the pod selector in the status.  These are
+		// read through the scale subresource.
+		MakeRefreshCurrentSizeReconciler(r, log, vas, vdb),
+	}
+	// The VerticaDB isn't scaled while it is paused.  Only the status is
+	// refreshed.
+	if vdb.IsPaused() {
+		log.Info("VerticaDB is paused. Not scaling it", "annotation", vapi.PauseAnnotation)
+		actors = []ReconcileActor{MakeRefreshCurrentSizeReconciler(r, log, vas, vdb)}
+	}
+
+	for _, act := range actors {
+		log.Info("starting actor", "name", fmt.Sprintf("%T", act))
+		res, err = act.Reconcile(ctx, &req)
+		// Error or a request to requeue will stop the reconciliation.
+		if err != nil || res.Requeue || res.RequeueAfter > 0 {
+			log.Info("aborting reconcile of VerticaAutoscaler", "result", res, "err", err)
+			return res, err
+		}
+	}
+
+	log.Info("ending reconcile of VerticaAutoscaler", "result", res, "err", err)
+	return res, err
+}
@@ -0,0 +1,161 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+	"github.com/vertica/vertica-kubernetes/pkg/events"
+)
+
+// VerticaBackupReconciler reconciles a VerticaBackup object
+type VerticaBackupReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Cfg    *rest.Config
+	EVRec  record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticabackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticabackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticabackups/finalizers,verbs=update
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VerticaBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vapi.VerticaBackup{}).
+		// A backup waits while its VerticaDB is paused.  It is reconciled
+		// again when the pause annotation changes.
+		Watches(&source.Kind{Type: &vapi.VerticaDB{}}, handler.EnqueueRequestsFromMapFunc(r.mapVDBToBackups),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Complete(r)
+}
+
+// mapVDBToBackups returns a request for each VerticaBackup of the given
+// VerticaDB that hasn't completed yet
+func (r *VerticaBackupReconciler) mapVDBToBackups(obj client.Object) []reconcile.Request {
+	vbs := &vapi.VerticaBackupList{}
+	if err := r.List(context.Background(), vbs, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list VerticaBackups for VerticaDB", "verticadb", obj.GetName())
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for i := range vbs.Items {
+		vb := &vbs.Items[i]
+		if vb.Spec.VerticaDBName == obj.GetName() && !vb.IsComplete() {
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: vb.Namespace, Name: vb.Name},
+			})
+		}
+	}
+	return reqs
+}
+
+// Reconcile will take a backup of a VerticaDB with vbr.  A VerticaBackup is
+// a one shot request.  Once it has succeeded or failed we will not act on it
+// again.
+func (r *VerticaBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("verticabackup", req.NamespacedName)
+	log.Info("starting reconcile of VerticaBackup")
+
+	vb := &vapi.VerticaBackup{}
+	err := r.Get(ctx, req.NamespacedName, vb)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("VerticaBackup resource not found.  Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get VerticaBackup")
+		return ctrl.Result{}, err
+	}
+
+	if vb.IsComplete() {
+		log.Info("VerticaBackup has already completed", "phase", vb.Status.Phase)
+		return ctrl.Result{}, nil
+	}
+
+	vdb, res, err := fetchVDB(ctx, r.Client, r.EVRec, vb,
+		types.NamespacedName{Namespace: vb.Namespace, Name: vb.Spec.VerticaDBName})
+	if err != nil || res.Requeue {
+		return res, err
+	}
+	// The backup is held back while the VerticaDB is paused
+	if vdb.IsPaused() {
+		log.Info("VerticaDB is paused. Waiting to take the backup", "annotation", vapi.PauseAnnotation)
+		return ctrl.Result{}, nil
+	}
+
+	passwd, err := getDBSuperuserPassword(ctx, r.Client, r.EVRec, log, vdb)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	prunner := cmds.MakeClusterPodRunner(log, r.Cfg, passwd)
+	pfacts := MakePodFacts(r.Client, prunner)
+
+	actors := []ReconcileActor{
+		// Handles calls to vbr -t backup
+		MakeBackupReconciler(r, log, vb, vdb, prunner, &pfacts, passwd),
+	}
+
+	for _, act := range actors {
+		log.Info("starting actor", "name", fmt.Sprintf("%T", act))
+		res, err = act.Reconcile(ctx, &req)
+		// Error or a request to requeue will stop the reconciliation.
+		if err != nil || res.Requeue || res.RequeueAfter > 0 {
+			log.Info("aborting reconcile of VerticaBackup", "result", res, "err", err)
+			return res, err
+		}
+	}
+
+	log.Info("ending reconcile of VerticaBackup", "result", res, "err", err)
+	return res, err
+}
+
+// fetchVDB will fetch the VerticaDB that another custom resource refers to.
+// If it isn't found, a warning event is written against obj and a requeue is
+// returned.
+func fetchVDB(ctx context.Context, clnt client.Client, evrec record.EventRecorder, obj runtime.Object,
+	nm types.NamespacedName) (*vapi.VerticaDB, ctrl.Result, error) {
+	vdb := &vapi.VerticaDB{}
+	if err := clnt.Get(ctx, nm, vdb); err != nil {
+		if errors.IsNotFound(err) {
+			evrec.Eventf(obj, corev1.EventTypeWarning, events.VerticaDBNotFound,
+				"The VerticaDB named '%s' was not found", nm.Name)
+			return nil, ctrl.Result{Requeue: true}, nil
+		}
+		return nil, ctrl.Result{}, err
+	}
+	return vdb, ctrl.Result{}, nil
+}
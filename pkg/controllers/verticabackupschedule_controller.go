@@ -0,0 +1,141 @@
+/*
+ (c) Copyright [2021] Micro Focus or one of its affiliates.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ You may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
+	"github.com/vertica/vertica-kubernetes/pkg/cmds"
+)
+
+// VerticaBackupScheduleReconciler reconciles a VerticaBackupSchedule object
+type VerticaBackupScheduleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Cfg    *rest.Config
+	EVRec  record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticabackupschedules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticabackupschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticabackupschedules/finalizers,verbs=update
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VerticaBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vapi.VerticaBackupSchedule{}).
+		Owns(&vapi.VerticaBackup{}).
+		// Nothing is scheduled while the VerticaDB is paused.  The schedule
+		// is reconciled again when the pause annotation changes.
+		Watches(&source.Kind{Type: &vapi.VerticaDB{}}, handler.EnqueueRequestsFromMapFunc(r.mapVDBToSchedules),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Complete(r)
+}
+
+// mapVDBToSchedules returns a request for each VerticaBackupSchedule of the
+// given VerticaDB
+func (r *VerticaBackupScheduleReconciler) mapVDBToSchedules(obj client.Object) []reconcile.Request {
+	vbsList := &vapi.VerticaBackupScheduleList{}
+	if err := r.List(context.Background(), vbsList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list VerticaBackupSchedules for VerticaDB", "verticadb", obj.GetName())
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for i := range vbsList.Items {
+		vbs := &vbsList.Items[i]
+		if vbs.Spec.VerticaDBName == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: vbs.Namespace, Name: vbs.Name},
+			})
+		}
+	}
+	return reqs
+}
+
+// Reconcile will create VerticaBackup objects according to the schedule and
+// prune restore points that fall outside of the retention.
+func (r *VerticaBackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("verticabackupschedule", req.NamespacedName)
+	log.Info("starting reconcile of VerticaBackupSchedule")
+
+	vbs := &vapi.VerticaBackupSchedule{}
+	err := r.Get(ctx, req.NamespacedName, vbs)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("VerticaBackupSchedule resource not found.  Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get VerticaBackupSchedule")
+		return ctrl.Result{}, err
+	}
+
+	vdb, res, err := fetchVDB(ctx, r.Client, r.EVRec, vbs,
+		types.NamespacedName{Namespace: vbs.Namespace, Name: vbs.Spec.VerticaDBName})
+	if err != nil || res.Requeue {
+		return res, err
+	}
+	// No backups are created or pruned while the VerticaDB is paused
+	if vdb.IsPaused() {
+		log.Info("VerticaDB is paused. Skipping the backup schedule", "annotation", vapi.PauseAnnotation)
+		return ctrl.Result{}, nil
+	}
+
+	passwd, err := getDBSuperuserPassword(ctx, r.Client, r.EVRec, log, vdb)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	prunner := cmds.MakeClusterPodRunner(log, r.Cfg, passwd)
+	pfacts := MakePodFacts(r.Client, prunner)
+
+	actors := []ReconcileActor{
+		// Handles the outcome of the last scheduled backup.  This is where
+		// restore points outside of the retention are removed with vbr.
+		MakeBackupRetentionReconciler(r, log, vbs, vdb, prunner, &pfacts, passwd),
+		// Creates a VerticaBackup when the schedule says a backup is due.
+		// This must be last as it always requeues for the next scheduled time.
+		MakeScheduledBackupReconciler(r, log, vbs),
+	}
+
+	for _, act := range actors {
+		log.Info("starting actor", "name", fmt.Sprintf("%T", act))
+		res, err = act.Reconcile(ctx, &req)
+		// Error or a request to requeue will stop the reconciliation.
+		if err != nil || res.Requeue || res.RequeueAfter > 0 {
+			log.Info("aborting reconcile of VerticaBackupSchedule", "result", res, "err", err)
+			return res, err
+		}
+	}
+
+	log.Info("ending reconcile of VerticaBackupSchedule", "result", res, "err", err)
+	return res, err
+}
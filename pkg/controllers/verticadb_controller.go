@@ -23,17 +23,20 @@ import (
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	vapi "github.com/vertica/vertica-kubernetes/api/v1beta1"
 	"github.com/vertica/vertica-kubernetes/pkg/cmds"
-	"github.com/vertica/vertica-kubernetes/pkg/events"
-	"github.com/vertica/vertica-kubernetes/pkg/names"
 )
 
 // VerticaDBReconciler reconciles a VerticaDB object
@@ -50,9 +53,13 @@ type VerticaDBReconciler struct {
 //+kubebuilder:rbac:groups=vertica.com,namespace=WATCH_NAMESPACE,resources=verticadbs/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,namespace=WATCH_NAMESPACE,resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,namespace=WATCH_NAMESPACE,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",namespace=WATCH_NAMESPACE,resources=pods,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=policy,namespace=WATCH_NAMESPACE,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",namespace=WATCH_NAMESPACE,resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",namespace=WATCH_NAMESPACE,resources=pods/exec,verbs=create
-// +kubebuilder:rbac:groups=core,namespace=WATCH_NAMESPACE,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,namespace=WATCH_NAMESPACE,resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=core,namespace=WATCH_NAMESPACE,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,namespace=WATCH_NAMESPACE,resources=persistentvolumeclaims,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *VerticaDBReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -60,9 +67,35 @@ func (r *VerticaDBReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		For(&vapi.VerticaDB{}).
 		Owns(&corev1.Service{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&policyv1beta1.PodDisruptionBudget{}).
+		// Secrets aren't owned by the VerticaDB, but some of their contents
+		// are applied to the database.  A change to one of them needs a
+		// reconcile of the VerticaDBs that refer to it.
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.mapSecretToVDBs)).
 		Complete(r)
 }
 
+// mapSecretToVDBs returns a request for each VerticaDB that applies the
+// contents of the given secret to its database
+func (r *VerticaDBReconciler) mapSecretToVDBs(obj client.Object) []reconcile.Request {
+	vdbs := &vapi.VerticaDBList{}
+	if err := r.List(context.Background(), vdbs, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list VerticaDBs for secret", "secret", obj.GetName())
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for i := range vdbs.Items {
+		vdb := &vdbs.Items[i]
+		if vdb.Spec.TLS.ServerSecret == obj.GetName() || vdb.Spec.SuperuserPasswordSecret == obj.GetName() ||
+			vdb.Spec.LicenseSecret == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: vdb.Namespace, Name: vdb.Name},
+			})
+		}
+	}
+	return reqs
+}
+
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 // TODO(user): Modify the Reconcile function to compare the state specified by
@@ -88,7 +121,10 @@ func (r *VerticaDBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
-	passwd, err := r.GetSuperuserPassword(ctx, vdb, log)
+	// The pod runner uses the password that is currently set in the database.
+	// If the secret has changed, the new password is switched to once it has
+	// been rotated by the SuperuserPasswordReconciler.
+	passwd, err := getDBSuperuserPassword(ctx, r.Client, r.EVRec, log, vdb)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -105,9 +141,17 @@ func (r *VerticaDBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	actors := []ReconcileActor{
 		// Always start with a status reconcile in case the prior reconcile failed.
 		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
+		// Handles an image change when the upgrade policy is offline.  This
+		// must come before the restart reconciler, as it stops the cluster
+		// and only starts it again once the new version has been validated.
+		MakeOfflineUpgradeReconciler(r, log, vdb, prunner, &pfacts),
 		// Handles restart + re_ip of vertica
 		MakeRestartReconciler(r, log, vdb, prunner, &pfacts),
 		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
+		// Handles an image change when the upgrade policy is online.  The new
+		// image is rolled out to each subcluster and the nodes are restarted.
+		MakeOnlineUpgradeReconciler(r, log, vdb, prunner, &pfacts),
+		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
 		// Handles calls to admintools -t db_remove_subcluster
 		MakeDBRemoveSubclusterReconciler(r, log, vdb, prunner, &pfacts),
 		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
@@ -117,6 +161,10 @@ func (r *VerticaDBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		// Handle calls to update_vertica --remove-hosts
 		MakeUninstallReconciler(r, log, vdb, prunner, &pfacts),
 		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
+		// Stops the vertica nodes of subclusters that are marked for shutdown.
+		// This must come before the statefulsets are scaled to zero.
+		MakeSubclusterShutdownReconciler(r, log, vdb, prunner, &pfacts),
+		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
 		// Creates or updates any k8s objects the CRD creates. This includes any
 		// statefulsets and service objects.
 		MakeObjReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
@@ -129,9 +177,15 @@ func (r *VerticaDBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		MakeCreateDBReconciler(r, log, vdb, prunner, &pfacts),
 		// Handle calls to admintools -t revive_db
 		MakeReviveDBReconciler(r, log, vdb, prunner, &pfacts),
-		// Create and revive are mutually exclusive exclusive, so this handles
-		// status updates after both of them.
+		// Handle restore of a vbr restore point via vbr -t restore
+		MakeRestoreDBReconciler(r, log, vdb, prunner, &pfacts),
+		// Create, revive and restore are mutually exclusive exclusive, so
+		// this handles status updates after all of them.
 		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
+		// Change the superuser password in the database if its secret
+		// changed.  This comes right after the database is initialized, so
+		// that actors further down that requeue don't hold up the rotation.
+		MakeSuperuserPasswordReconciler(r, log, vdb, prunner, &pfacts),
 		// Ensure the vertica agent is running on each pod
 		MakeAgentReconciler(r, log, vdb, prunner, &pfacts),
 		// Handle calls to admintools -t db_add_subcluster
@@ -140,8 +194,40 @@ func (r *VerticaDBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		// Handle calls to admintools -t db_add_node
 		MakeDBAddNodeReconciler(r, log, vdb, prunner, &pfacts),
 		MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts),
+		// Run the SQL from spec.initScripts.  This comes after the database
+		// is initialized and any new subclusters have their nodes added.
+		MakeInitScriptsReconciler(r, log, vdb, prunner, &pfacts),
+		// Set the Kerberos parameters in the database.  This waits for the
+		// database to be up, so it comes after it has been initialized.
+		MakeKerberosReconciler(r, log, vdb, prunner, &pfacts),
+		// Set the configuration parameters from the spec in the database
+		MakeConfigParamsReconciler(r, log, vdb, prunner, &pfacts),
+		// Install the licenses from the license secret and report the
+		// limits of the license in the status
+		MakeLicenseReconciler(r, log, vdb, prunner, &pfacts),
+		// Install the server certificate in the database.  This is done last
+		// as rotating it can restart the cluster with older versions.
+		MakeTLSReconciler(r, log, vdb, prunner, &pfacts),
+		// Expand the PVCs after the size in the spec was increased.  This
+		// comes last because it waits on the storage provider to resize the
+		// volumes, which shouldn't hold up any of the other actors.
+		MakePVCExpandReconciler(r, log, vdb),
+	}
+
+	if err = r.updatePausedCondition(ctx, vdb); err != nil {
+		return ctrl.Result{}, err
+	}
+	// When paused, we only refresh the status.  None of the actors that change
+	// the database or its k8s objects are run.
+	if vdb.IsPaused() {
+		log.Info("reconcile of VerticaDB is paused", "annotation", vapi.PauseAnnotation)
+		actors = []ReconcileActor{MakeStatusReconciler(r.Client, r.Scheme, log, vdb, &pfacts)}
 	}
 
+	// Actors that only need to be run again after some time, such as to
+	// refresh part of the status, don't stop the reconciliation.  We requeue
+	// at the end with the smallest delay that any of them asked for.
+	var requeueAfter time.Duration
 	for _, act := range actors {
 		log.Info("starting actor", "name", fmt.Sprintf("%T", act))
 		res, err = act.Reconcile(ctx, &req)
@@ -154,33 +240,17 @@ func (r *VerticaDBReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			log.Info("aborting reconcile of VerticaDB", "result", res, "err", err)
 			return res, err
 		}
+		if res.RequeueAfter > 0 && (requeueAfter == 0 || res.RequeueAfter < requeueAfter) {
+			requeueAfter = res.RequeueAfter
+		}
 	}
 
+	res = ctrl.Result{RequeueAfter: requeueAfter}
 	log.Info("ending reconcile of VerticaDB", "result", res, "err", err)
 	return res, err
 }
 
 // GetSuperuserPassword returns the superuser password if it has been provided
 func (r *VerticaDBReconciler) GetSuperuserPassword(ctx context.Context, vdb *vapi.VerticaDB, log logr.Logger) (string, error) {
-	secret := &corev1.Secret{}
-	passwd := ""
-	secretName := names.GenSUPasswdSecretName(vdb)
-	if secretName.Name == "" {
-		return passwd, nil
-	}
-	err := r.Get(ctx, secretName, secret)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			r.EVRec.Eventf(vdb, corev1.EventTypeWarning, events.SuperuserPasswordSecretNotFound,
-				"Secret for superuser password '%s' was not found", secretName.Name)
-		}
-		return passwd, err
-	}
-	pwd, ok := secret.Data[SuperuserPasswordKey]
-	if ok {
-		passwd = string(pwd)
-	} else {
-		log.Error(err, fmt.Sprintf("password not found, secret must have a key with name '%s'", SuperuserPasswordKey))
-	}
-	return passwd, nil
+	return getSuperuserPassword(ctx, r.Client, r.EVRec, log, vdb)
 }
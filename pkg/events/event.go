@@ -37,14 +37,27 @@ const (
 	ReviveDBPermissionDenied        = "ReviveDBPermissionDenied"
 	ReviveDBNodeCountMismatch       = "ReviveDBNodeCountMismatch"
 	ReviveOrderBad                  = "ReviveOrderBad"
+	RestoreDBStart                  = "RestoreDBStart"
+	RestoreDBSucceeded              = "RestoreDBSucceeded"
+	RestoreDBFailed                 = "RestoreDBFailed"
+	RestorePointNotFound            = "RestorePointNotFound"
 	S3CredsNotFound                 = "S3CredsNotFound"
 	S3CredsWrongKey                 = "S3CredsWrongKey"
 	S3EndpointIssue                 = "S3EndpointIssue"
 	S3BucketDoesNotExist            = "S3BucketDoesNotExist"
+	GCSEndpointIssue                = "GCSEndpointIssue"
+	GCSBucketDoesNotExist           = "GCSBucketDoesNotExist"
+	GCSAuthFailed                   = "GCSAuthFailed"
 	CommunalPathIsNotEmpty          = "CommunalPathIsNotEmpty"
 	RemoveNodesStart                = "RemoveNodesStart"
 	RemoveNodesSucceeded            = "RemoveNodesSucceeded"
 	RemoveNodesFailed               = "RemoveNodesFailed"
+	DrainStart                      = "DrainStart"
+	DrainSucceeded                  = "DrainSucceeded"
+	DrainTimedOut                   = "DrainTimedOut"
+	SubclusterShutdownStarted       = "SubclusterShutdownStarted"
+	SubclusterShutdownSucceeded     = "SubclusterShutdownSucceeded"
+	SubclusterShutdownFailed        = "SubclusterShutdownFailed"
 	NodeRestartStarted              = "NodeRestartStarted"
 	NodeRestartFailed               = "NodeRestartFailed"
 	NodeRestartSucceeded            = "NodeRestartSucceeded"
@@ -53,6 +66,48 @@ const (
 	ClusterRestartSucceeded         = "ClusterRestartSucceeded"
 	SubclusterAdded                 = "SubclusterAdded"
 	SubclusterRemoved               = "SubclusterRemoved"
+	SubclusterNotFound              = "SubclusterNotFound"
+	InvalidAutoscalerTemplate       = "InvalidAutoscalerTemplate"
+	PrimarySubclusterNotScalable    = "PrimarySubclusterNotScalable"
 	SuperuserPasswordSecretNotFound = "SuperuserPasswordSecretNotFound"
+	SuperuserPasswordRotated        = "SuperuserPasswordRotated"
+	SuperuserPasswordRotateFailed   = "SuperuserPasswordRotateFailed"
+	LicenseSecretNotFound           = "LicenseSecretNotFound"
+	LicenseInstalled                = "LicenseInstalled"
+	LicenseInstallFailed            = "LicenseInstallFailed"
+	LicenseExpiring                 = "LicenseExpiring"
+	LicenseExpired                  = "LicenseExpired"
+	InitScriptConfigMapNotFound     = "InitScriptConfigMapNotFound"
+	InitScriptSucceeded             = "InitScriptSucceeded"
+	InitScriptFailed                = "InitScriptFailed"
+	KerberosAuthConfigured          = "KerberosAuthConfigured"
+	PVCExpansionStarted             = "PVCExpansionStarted"
+	PVCExpansionSucceeded           = "PVCExpansionSucceeded"
+	PVCExpansionNotSupported        = "PVCExpansionNotSupported"
+	ConfigParametersApplied         = "ConfigParametersApplied"
+	ConfigParametersFailed          = "ConfigParametersFailed"
+	ServerCertSecretNotFound        = "ServerCertSecretNotFound"
+	ServerCertSecretWrongKey        = "ServerCertSecretWrongKey"
+	ServerCertInstalled             = "ServerCertInstalled"
+	ServerCertInstallFailed         = "ServerCertInstallFailed"
+	ServerCertRestartNeeded         = "ServerCertRestartNeeded"
 	UnsupportedVerticaVersion       = "UnsupportedVerticaVersion"
+	UpgradeStart                    = "UpgradeStart"
+	UpgradeProgress                 = "UpgradeProgress"
+	UpgradeSucceeded                = "UpgradeSucceeded"
+	InvalidUpgradePath              = "InvalidUpgradePath"
+	OnlineUpgradeNotSupported       = "OnlineUpgradeNotSupported"
+	ClusterShutdownStarted          = "ClusterShutdownStarted"
+	ClusterShutdownFailed           = "ClusterShutdownFailed"
+	ClusterShutdownSucceeded        = "ClusterShutdownSucceeded"
+	VerticaDBNotFound               = "VerticaDBNotFound"
+	ReconcilePaused                 = "ReconcilePaused"
+	ReconcileResumed                = "ReconcileResumed"
+	BackupStart                     = "BackupStart"
+	BackupSucceeded                 = "BackupSucceeded"
+	BackupFailed                    = "BackupFailed"
+	InvalidBackupSchedule           = "InvalidBackupSchedule"
+	BackupScheduleMissed            = "BackupScheduleMissed"
+	ScheduledBackupFailed           = "ScheduledBackupFailed"
+	RestorePointsPruned             = "RestorePointsPruned"
 )
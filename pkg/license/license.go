@@ -49,12 +49,24 @@ func GetPath(ctx context.Context, clnt client.Client, vdb *vapi.VerticaDB) (stri
 
 	// This function only returns a single license -- to be used with
 	// update_vertica.  In case the secret has multiple licenses, we will pick
-	// the one that comes first alphabetically.  The rest of the licenses will
-	// be mounted in the container that the customer can then install.
+	// the one that comes first alphabetically.  The rest of the licenses are
+	// installed once the database is up.
+	return GetMountedPath(GetSortedNames(secret)[0]), nil
+}
+
+// GetSortedNames returns the name of each license in the secret in
+// alphabetical order
+func GetSortedNames(secret *corev1.Secret) []string {
 	licenseNames := make([]string, 0, len(secret.Data))
 	for k := range secret.Data {
 		licenseNames = append(licenseNames, k)
 	}
 	sort.Strings(licenseNames)
-	return fmt.Sprintf("%s/%s", paths.MountedLicensePath, licenseNames[0]), nil
+	return licenseNames
+}
+
+// GetMountedPath returns the path in the pod of the license with the given
+// name from the license secret
+func GetMountedPath(licenseName string) string {
+	return fmt.Sprintf("%s/%s", paths.MountedLicensePath, licenseName)
 }
@@ -46,6 +46,24 @@ func GenStsName(vdb *vapi.VerticaDB, sc *vapi.Subcluster) types.NamespacedName {
 	}
 }
 
+// GenPDBName returns the name of the pod disruption budget for a subcluster
+func GenPDBName(vdb *vapi.VerticaDB, sc *vapi.Subcluster) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      vdb.Name + "-" + sc.Name,
+		Namespace: vdb.Namespace,
+	}
+}
+
+// GenPrimaryPDBName returns the name of the pod disruption budget that covers
+// the pods of all of the primary subclusters.  It is named after the vdb
+// alone, so it can't clash with the one for a subcluster.
+func GenPrimaryPDBName(vdb *vapi.VerticaDB) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      vdb.Name,
+		Namespace: vdb.Namespace,
+	}
+}
+
 // GenCommunalCredSecretName returns the name of the secret that has the credentials to access s3
 func GenCommunalCredSecretName(vdb *vapi.VerticaDB) types.NamespacedName {
 	return types.NamespacedName{
@@ -62,6 +80,15 @@ func GenSUPasswdSecretName(vdb *vapi.VerticaDB) types.NamespacedName {
 	}
 }
 
+// GenSUPasswdAppliedSecretName returns the name of the secret the operator
+// keeps with the superuser password that is currently set in the database
+func GenSUPasswdAppliedSecretName(vdb *vapi.VerticaDB) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      vdb.Name + "-su-passwd-applied",
+		Namespace: vdb.Namespace,
+	}
+}
+
 // GenPodName returns the name of a specific pod in a subcluster
 // The name of the pod is generated, this function is just a helper for when we need
 // to lookup a pod by its generated name.
@@ -71,3 +98,13 @@ func GenPodName(vdb *vapi.VerticaDB, sc *vapi.Subcluster, podIndex int32) types.
 		Namespace: vdb.Namespace,
 	}
 }
+
+// GenPVCName returns the name of the PVC that the statefulset creates for a
+// pod from the given volumeClaimTemplate
+func GenPVCName(vdb *vapi.VerticaDB, sc *vapi.Subcluster, claim string, podIndex int32) types.NamespacedName {
+	pod := GenPodName(vdb, sc, podIndex)
+	return types.NamespacedName{
+		Name:      claim + "-" + pod.Name,
+		Namespace: pod.Namespace,
+	}
+}
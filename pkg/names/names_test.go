@@ -44,4 +44,14 @@ var _ = Describe("k8s/names", func() {
 			types.NamespacedName{Namespace: "my-ns", Name: "name-test-my-sc-9"},
 		))
 	})
+
+	It("pvc name should be the claim template followed by the pod name", func() {
+		vdb := vapi.MakeVDB()
+		vdb.ObjectMeta.Name = "name-test"
+		vdb.ObjectMeta.Namespace = "my-ns"
+		vdb.Spec.Subclusters[0].Name = "my-sc"
+		Ω(GenPVCName(vdb, &vdb.Spec.Subclusters[0], "local-data", 2)).Should(Equal(
+			types.NamespacedName{Namespace: "my-ns", Name: "local-data-name-test-my-sc-2"},
+		))
+	})
 })
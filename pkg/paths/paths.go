@@ -24,6 +24,7 @@ import (
 const (
 	InstallerIndicatorFile = "/opt/vertica/config/update_vertica.called.for.uid."
 	LocalDataPath          = "/home/dbadmin/local-data"
+	LocalDepotPath         = "/home/dbadmin/local-depot"
 	CELicensePath          = "/home/dbadmin/licensing/ce/vertica_community_edition.license.key"
 	MountedLicensePath     = "/home/dbadmin/licensing/mnt"
 	ConfigPath             = "/opt/vertica/config"
@@ -31,6 +32,14 @@ const (
 	PodInfoPath            = "/etc/podinfo"
 	AdminToolsConf         = "/opt/vertica/config/admintools.conf"
 	AuthParmsFile          = "/home/dbadmin/auth_parms.conf"
+	VbrConfigPath          = "/home/dbadmin/vbr"
+	Krb5Conf               = "/etc/krb5.conf"
+	Krb5KeytabDir          = "/etc/krb5"
+	Krb5Keytab             = "/etc/krb5/krb5.keytab"
+	ServerCertDir          = "/certs/server"
+	HadoopConfPath         = "/etc/hadoop"
+	CommunalCADir          = "/certs/communal"
+	CommunalCAFile         = "/certs/communal/ca.crt"
 )
 
 // GenInstallerIndicatorFileName returns the name of the installer indicator file.
@@ -82,3 +82,76 @@ func UpdateCondition(ctx context.Context, clnt client.Client, vdb *vapi.VerticaD
 
 	return Update(ctx, clnt, vdb, refreshConditionInPlace)
 }
+
+// UpdateBackup will update the status of a VerticaBackup.  It follows the same
+// pattern as Update: the latest copy is fetched, the user provided function
+// changes the status in place, and we only write it back if it changed.
+func UpdateBackup(ctx context.Context, clnt client.Client, vb *vapi.VerticaBackup,
+	updateFunc func(*vapi.VerticaBackup) error) error {
+	nm := types.NamespacedName{Namespace: vb.Namespace, Name: vb.Name}
+	if err := clnt.Get(ctx, nm, vb); err != nil {
+		return err
+	}
+
+	vbChg := vb.DeepCopy()
+	if err := updateFunc(vbChg); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(vb.Status, vbChg.Status) {
+		vbChg.Status.DeepCopyInto(&vb.Status)
+		if err := clnt.Status().Update(ctx, vb); err != nil {
+			return fmt.Errorf("failed to update status of verticabackup %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateBackupSchedule will update the status of a VerticaBackupSchedule.  It
+// follows the same pattern as Update.
+func UpdateBackupSchedule(ctx context.Context, clnt client.Client, vbs *vapi.VerticaBackupSchedule,
+	updateFunc func(*vapi.VerticaBackupSchedule) error) error {
+	nm := types.NamespacedName{Namespace: vbs.Namespace, Name: vbs.Name}
+	if err := clnt.Get(ctx, nm, vbs); err != nil {
+		return err
+	}
+
+	vbsChg := vbs.DeepCopy()
+	if err := updateFunc(vbsChg); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(vbs.Status, vbsChg.Status) {
+		vbsChg.Status.DeepCopyInto(&vbs.Status)
+		if err := clnt.Status().Update(ctx, vbs); err != nil {
+			return fmt.Errorf("failed to update status of verticabackupschedule %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateVerticaAutoscaler will update the status of a VerticaAutoscaler.  It
+// follows the same pattern as Update.
+func UpdateVerticaAutoscaler(ctx context.Context, clnt client.Client, vas *vapi.VerticaAutoscaler,
+	updateFunc func(*vapi.VerticaAutoscaler) error) error {
+	nm := types.NamespacedName{Namespace: vas.Namespace, Name: vas.Name}
+	if err := clnt.Get(ctx, nm, vas); err != nil {
+		return err
+	}
+
+	vasChg := vas.DeepCopy()
+	if err := updateFunc(vasChg); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(vas.Status, vasChg.Status) {
+		vasChg.Status.DeepCopyInto(&vas.Status)
+		if err := clnt.Status().Update(ctx, vas); err != nil {
+			return fmt.Errorf("failed to update status of verticaautoscaler %w", err)
+		}
+	}
+
+	return nil
+}
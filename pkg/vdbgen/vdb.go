@@ -18,6 +18,7 @@ package vdbgen
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -160,7 +161,9 @@ func (d *DBGenerator) setCommunalEndpoint(ctx context.Context) error {
 	const HTTPSKey = "AWSEnableHttps"
 	const EndpointKey = "AWSEndpoint"
 	const AWSAuth = "AWSAuth"
-	var protocol, endpoint string
+	const AzureCredsKey = "AzureStorageCredentials"
+	const AzureEndpointKey = "AzureStorageEndpointConfig"
+	var protocol, endpoint, azureCreds, azureEndpoint string
 	var auth []string
 
 	for rows.Next() {
@@ -187,8 +190,21 @@ func (d *DBGenerator) setCommunalEndpoint(ctx context.Context) error {
 			authRE := regexp.MustCompile(`:`)
 			const NumAuthComponents = 2
 			auth = authRE.Split(value, NumAuthComponents)
+
+		case AzureCredsKey:
+			azureCreds = value
+
+		case AzureEndpointKey:
+			azureEndpoint = value
 		}
 	}
+
+	// A database that is backed by Azure Blob Storage has the credentials
+	// in its own parameter.  The AWS parameters are ignored in that case.
+	if azureCreds != "" {
+		return d.setAzureCommunalEndpoint(azureCreds, azureEndpoint)
+	}
+
 	if protocol == "" {
 		return fmt.Errorf("missing '%s' in query '%s'", HTTPSKey, q)
 	}
@@ -208,6 +224,45 @@ func (d *DBGenerator) setCommunalEndpoint(ctx context.Context) error {
 	return nil
 }
 
+// setAzureCommunalEndpoint will set the communal endpoint and credentials
+// from the Azure parameters.  Both parameters are JSON arrays; we only
+// support databases that use a single storage account.
+func (d *DBGenerator) setAzureCommunalEndpoint(azureCreds, azureEndpoint string) error {
+	creds := []controllers.AzureCredential{}
+	if err := json.Unmarshal([]byte(azureCreds), &creds); err != nil {
+		return fmt.Errorf("failed to parse AzureStorageCredentials: %w", err)
+	}
+	if len(creds) != 1 {
+		return fmt.Errorf("expected exactly one entry in AzureStorageCredentials but found %d", len(creds))
+	}
+
+	if azureEndpoint != "" {
+		cfgs := []controllers.AzureEndpointConfig{}
+		if err := json.Unmarshal([]byte(azureEndpoint), &cfgs); err != nil {
+			return fmt.Errorf("failed to parse AzureStorageEndpointConfig: %w", err)
+		}
+		for i := range cfgs {
+			if cfgs[i].AccountName != creds[0].AccountName {
+				continue
+			}
+			protocol := cfgs[i].Protocol
+			if protocol == "" {
+				protocol = "https"
+			}
+			d.Objs.Vdb.Spec.Communal.Endpoint = fmt.Sprintf("%s://%s", protocol, cfgs[i].BlobEndpoint)
+		}
+	}
+
+	d.Objs.CredSecret.Data = map[string][]byte{}
+	if creds[0].AccountKey != "" {
+		d.Objs.CredSecret.Data[controllers.AzureAccountKeyName] = []byte(creds[0].AccountKey)
+	}
+	if creds[0].SharedAccessSignature != "" {
+		d.Objs.CredSecret.Data[controllers.AzureSASName] = []byte(creds[0].SharedAccessSignature)
+	}
+	return nil
+}
+
 // setLocalPaths will fetch the local paths (data and depot) and set it in v.vdb
 func (d *DBGenerator) setLocalPaths(ctx context.Context) error {
 	dataPath, err := d.queryLocalPath(ctx, "DATA,TEMP")
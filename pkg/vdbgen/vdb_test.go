@@ -116,6 +116,25 @@ var _ = Describe("vdb", func() {
 		Expect(mock.ExpectationsWereMet()).Should(Succeed())
 	})
 
+	It("should get the Azure credentials and endpoint from show database", func() {
+		createMock()
+		defer deleteMock()
+
+		dbGen := DBGenerator{Conn: db}
+
+		mock.ExpectQuery(Queries[CommunalEndpointKey]).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value"}).
+				AddRow("AWSEnableHttps", "1").
+				AddRow("AzureStorageCredentials", `[{"accountName":"account","accountKey":"azkey"}]`).
+				AddRow("AzureStorageEndpointConfig", `[{"accountName":"account","blobEndpoint":"azurite:10000","protocol":"http"}]`))
+		Expect(dbGen.setCommunalEndpoint(ctx)).Should(Succeed())
+		Expect(dbGen.Objs.Vdb.Spec.Communal.Endpoint).Should(Equal("http://azurite:10000"))
+		Expect(dbGen.Objs.CredSecret.Data[controllers.AzureAccountKeyName]).Should(Equal([]byte("azkey")))
+		Expect(dbGen.Objs.CredSecret.Data).ShouldNot(HaveKey(controllers.S3AccessKeyName))
+
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
 	It("should extract common prefix for local and depot path", func() {
 		createMock()
 		defer deleteMock()
@@ -45,8 +45,30 @@ func MakeInfo(vdb *vapi.VerticaDB) (*Info, bool) {
 	if !ok {
 		return nil, false
 	}
-	ma, mi, pa, ok := parseVersion(vdbVer)
-	return &Info{vdbVer, ma, mi, pa}, ok
+	return MakeInfoFromStr(vdbVer)
+}
+
+// MakeInfoFromStr will construct an Info struct by parsing the given version
+// string.  This returns false if the version could not be parsed.
+func MakeInfoFromStr(ver string) (*Info, bool) {
+	ma, mi, pa, ok := parseVersion(ver)
+	return &Info{ver, ma, mi, pa}, ok
+}
+
+// The version in the tag of an image, such as 11.0.1 in
+// vertica/vertica-k8s:11.0.1-0.  The tag is everything after the last ':' that
+// isn't followed by a '/', which skips a port in the registry host.
+var imageTagVersionRegexp = regexp.MustCompile(`:v?(\d+)\.(\d+)\.(\d+)[^:/]*$`)
+
+// GetImageVersion returns the version found in the tag of the given image,
+// in the same form as the version annotation (vX.Y.Z).  This returns false if
+// the tag doesn't have a version in it, such as for the latest tag.
+func GetImageVersion(image string) (string, bool) {
+	m := imageTagVersionRegexp.FindStringSubmatch(image)
+	if m == nil {
+		return "", false
+	}
+	return fmt.Sprintf("v%s.%s.%s", m[1], m[2], m[3]), true
 }
 
 // IsUnsupported returns true if the version in the vdb is unsupported by the operator.
@@ -116,3 +138,22 @@ func parseVersion(v string) (major, minor, patch int, ok bool) {
 	ok = true
 	return
 }
+
+// IsValidUpgradePath will return true if the version in the vdb can be
+// upgraded to the given version.  Downgrades are not allowed, and Vertica
+// requires that we move up one major version at a time.  If the upgrade path
+// isn't valid, a reason suitable for an event message is also returned.
+func (i *Info) IsValidUpgradePath(targetVer string) (ok bool, failureReason string) {
+	t, ok := MakeInfoFromStr(targetVer)
+	if !ok {
+		return false, fmt.Sprintf("could not parse the target version %s", targetVer)
+	}
+	if !t.IsEqualOrNewer(i.VdbVer) {
+		return false, fmt.Sprintf("downgrading from %s to %s is not supported", i.VdbVer, t.VdbVer)
+	}
+	if t.VdbMajor > i.VdbMajor+1 {
+		return false, fmt.Sprintf("upgrading from %s to %s skips a major version.  "+
+			"You must first upgrade to a v%d.x.x release", i.VdbVer, t.VdbVer, i.VdbMajor+1)
+	}
+	return true, ""
+}
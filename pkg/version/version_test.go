@@ -75,4 +75,51 @@ var _ = Describe("version", func() {
 		Expect(vinf.IsUnsupported()).Should(BeFalse())
 		Expect(vinf.IsSupported()).Should(BeTrue())
 	})
+
+	It("should allow upgrade paths that move up at most one major version", func() {
+		vdb := vapi.MakeVDB()
+		vdb.ObjectMeta.Annotations[vapi.VersionAnnotation] = "v10.1.1-0"
+		vinf, ok := MakeInfo(vdb)
+		Expect(ok).Should(BeTrue())
+
+		ok, _ = vinf.IsValidUpgradePath("v10.1.1-0")
+		Expect(ok).Should(BeTrue())
+		ok, _ = vinf.IsValidUpgradePath("v10.1.1-5")
+		Expect(ok).Should(BeTrue())
+		ok, _ = vinf.IsValidUpgradePath("v11.0.0-0")
+		Expect(ok).Should(BeTrue())
+		ok, _ = vinf.IsValidUpgradePath("v11.1.0-0")
+		Expect(ok).Should(BeTrue())
+	})
+
+	It("should fail upgrade paths that downgrade or skip a major version", func() {
+		vdb := vapi.MakeVDB()
+		vdb.ObjectMeta.Annotations[vapi.VersionAnnotation] = "v11.0.1-0"
+		vinf, ok := MakeInfo(vdb)
+		Expect(ok).Should(BeTrue())
+
+		ok, reason := vinf.IsValidUpgradePath("v11.0.0-0")
+		Expect(ok).Should(BeFalse())
+		Expect(reason).Should(ContainSubstring("downgrading"))
+		ok, _ = vinf.IsValidUpgradePath("v10.1.1-0")
+		Expect(ok).Should(BeFalse())
+		ok, reason = vinf.IsValidUpgradePath("v13.0.0-0")
+		Expect(ok).Should(BeFalse())
+		Expect(reason).Should(ContainSubstring("v12.x.x"))
+		ok, _ = vinf.IsValidUpgradePath("not-a-version")
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("should get the version from the tag of an image", func() {
+		ver, ok := GetImageVersion("vertica/vertica-k8s:11.0.1-0")
+		Expect(ok).Should(BeTrue())
+		Expect(ver).Should(Equal("v11.0.1"))
+		ver, ok = GetImageVersion("myregistry:5000/vertica-k8s:v10.1.1")
+		Expect(ok).Should(BeTrue())
+		Expect(ver).Should(Equal("v10.1.1"))
+		_, ok = GetImageVersion("vertica/vertica-k8s:latest")
+		Expect(ok).Should(BeFalse())
+		_, ok = GetImageVersion("myregistry:5000/vertica-k8s")
+		Expect(ok).Should(BeFalse())
+	})
 })